@@ -1,24 +1,54 @@
 package api
 
+import (
+	"backend-avanzada/models"
+	"strings"
+)
+
 type AlchemistRequestDto struct {
-	Name      string `json:"name"`
-	Age       int32  `json:"age"`
-	Specialty string `json:"specialty"`
-	Rank      string `json:"rank"`
+	Name                string  `json:"name" validate:"required,max=100"`
+	Age                 int32   `json:"age" validate:"min=0,max=150"`
+	Specialty           string  `json:"specialty" validate:"max=255"`
+	Rank                string  `json:"rank" validate:"max=100"`
+	DivisionID          *uint   `json:"division_id,omitempty" validate:"min=1"`
+	WeeklyCapacityHours float64 `json:"weekly_capacity_hours" validate:"min=0,max=168"`
 }
 
 type AlchemistEditRequestDto struct {
-	Name      *string `json:"name,omitempty"`
-	Age       *int32  `json:"age,omitempty"`
-	Specialty *string `json:"specialty,omitempty"`
-	Rank      *string `json:"rank,omitempty"`
+	Name                *string  `json:"name,omitempty" validate:"notblank,max=100"`
+	Age                 *int32   `json:"age,omitempty" validate:"min=0,max=150"`
+	Specialty           *string  `json:"specialty,omitempty" validate:"max=255"`
+	Rank                *string  `json:"rank,omitempty"` // Solo lectura: usar /alchemists/{id}/promotions
+	WeeklyCapacityHours *float64 `json:"weekly_capacity_hours,omitempty" validate:"min=0,max=168"`
+}
+
+type AlchemistUserLinkRequestDto struct {
+	Email string `json:"email" validate:"required,email"`
 }
 
 type AlchemistResponseDto struct {
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	Age       int    `json:"age"`
-	Specialty string `json:"specialty"`
-	Rank      string `json:"rank"`
-	CreatedAt string `json:"created_at"`
+	ID                  int     `json:"id"`
+	Name                string  `json:"name"`
+	Age                 int     `json:"age"`
+	Specialty           string  `json:"specialty"`
+	Rank                string  `json:"rank"`
+	DivisionID          *uint   `json:"division_id,omitempty"`
+	WeeklyCapacityHours float64 `json:"weekly_capacity_hours,omitempty"`
+	CreatedAt           string  `json:"created_at"`
+}
+
+// validRank acepta la cadena vacía (rango por defecto) o un peldaño de la escala.
+func validRank(field, rank string) []FieldError {
+	if rank == "" || models.RankIndex(rank) >= 0 {
+		return nil
+	}
+	names := make([]string, 0, len(models.RankLadder))
+	for _, r := range models.RankLadder {
+		names = append(names, r.Name)
+	}
+	return []FieldError{{Field: field, Message: "must be one of: " + strings.Join(names, ", ")}}
+}
+
+func (d *AlchemistRequestDto) Validate() []FieldError {
+	return validRank("rank", d.Rank)
 }
@@ -0,0 +1,30 @@
+package api
+
+import "time"
+
+type APIKeyRequestDto struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	Permissions []string `json:"permissions" validate:"min=1"`
+	ExpiresAt   string   `json:"expires_at" validate:"omitempty,rfc3339"` // Vacío = no caduca
+}
+
+type APIKeyResponseDto struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Prefix      string   `json:"prefix"`
+	Key         string   `json:"key,omitempty"` // Solo se devuelve al crearla
+	Permissions []string `json:"permissions"`
+	ExpiresAt   string   `json:"expires_at,omitempty"`
+	LastUsedAt  string   `json:"last_used_at,omitempty"`
+	RevokedAt   string   `json:"revoked_at,omitempty"`
+	CreatedBy   string   `json:"created_by"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+func (d *APIKeyRequestDto) Validate() []FieldError {
+	errs := validPermissions("permissions", d.Permissions)
+	if t, err := time.Parse(time.RFC3339, d.ExpiresAt); err == nil && !t.After(time.Now()) {
+		errs = append(errs, FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+	return errs
+}
@@ -0,0 +1,15 @@
+package api
+
+type AttachmentResponseDto struct {
+	ID           int    `json:"id"`
+	Entity       string `json:"entity"`
+	EntityID     uint   `json:"entity_id"`
+	Filename     string `json:"filename"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	HasThumbnail bool   `json:"has_thumbnail"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	UploadedBy   string `json:"uploaded_by"`
+	CreatedAt    string `json:"created_at"`
+}
@@ -1,19 +1,19 @@
 package api
 
 type AuditRequestDto struct {
-	Action    string `json:"action"`
-	Entity    string `json:"entity"`
+	Action    string `json:"action" validate:"required,max=64"`
+	Entity    string `json:"entity" validate:"required,max=64"`
 	EntityID  uint   `json:"entity_id"`
-	UserEmail string `json:"user_email"`
-	Details   string `json:"details"`
+	UserEmail string `json:"user_email" validate:"omitempty,email"`
+	Details   string `json:"details" validate:"max=2000"`
 }
 
 type AuditEditRequestDto struct {
-	Action    *string `json:"action,omitempty"`
-	Entity    *string `json:"entity,omitempty"`
+	Action    *string `json:"action,omitempty" validate:"notblank,max=64"`
+	Entity    *string `json:"entity,omitempty" validate:"notblank,max=64"`
 	EntityID  *uint   `json:"entity_id,omitempty"`
-	UserEmail *string `json:"user_email,omitempty"`
-	Details   *string `json:"details,omitempty"`
+	UserEmail *string `json:"user_email,omitempty" validate:"omitempty,email"`
+	Details   *string `json:"details,omitempty" validate:"max=2000"`
 }
 
 type AuditResponseDto struct {
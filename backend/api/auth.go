@@ -1,16 +1,90 @@
 package api
 
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Role     string `json:"role"` // "alchemist" | "supervisor"
+	Email      string               `json:"email" validate:"required,email,max=255"`
+	Password   string               `json:"password" validate:"required,min=8,max=72"`
+	Role       string               `json:"role" validate:"omitempty,max=32"` // Por defecto "alchemist"; otros roles requieren invitación
+	InviteCode string               `json:"invite_code,omitempty"`
+	Alchemist  *AlchemistRequestDto `json:"alchemist,omitempty"` // Crea y vincula un perfil propio
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"` // Opcional: cierra también la sesión de refresco
+}
+
+// AuthResponse trae los tokens de sesión o, si falta el segundo factor,
+// MFAToken para completar el login en /auth/login/2fa (o darse de alta en el
+// doble factor si es obligatorio y aún no lo tiene).
 type AuthResponse struct {
-	Token string `json:"token"`
+	Token            string   `json:"token,omitempty"`
+	RefreshToken     string   `json:"refresh_token,omitempty"`
+	ExpiresIn        int      `json:"expires_in,omitempty"` // Segundos de validez del token de acceso
+	MFARequired      bool     `json:"mfa_required,omitempty"`
+	MFASetupRequired bool     `json:"mfa_setup_required,omitempty"`
+	MFAToken         string   `json:"mfa_token,omitempty"`
+	RecoveryCodes    []string `json:"recovery_codes,omitempty"` // Solo al activar el doble factor
+}
+
+// Segundo paso del login: código TOTP o código de recuperación.
+type TwoFactorLoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// Alta del doble factor durante el login, cuando es obligatorio.
+type TwoFactorSetupRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+type TwoFactorEnableRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled           bool  `json:"enabled"`
+	Required          bool  `json:"required"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Petición de enlace por correo (restablecer contraseña o verificar email).
+// La respuesta es la misma exista o no la cuenta.
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type PasswordResetRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type EmailVerifyRequest struct {
+	Token string `json:"token" validate:"required"`
 }
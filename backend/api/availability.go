@@ -0,0 +1,38 @@
+package api
+
+import (
+	"time"
+)
+
+type AvailabilityWindowRequestDto struct {
+	Kind     string `json:"kind" validate:"required,oneof=leave training field_deployment"` // "leave" | "training" | "field_deployment"
+	StartsAt string `json:"starts_at" validate:"required,rfc3339"`                          // RFC3339
+	EndsAt   string `json:"ends_at" validate:"required,rfc3339"`                            // RFC3339
+	Notes    string `json:"notes" validate:"max=500"`
+}
+
+type AvailabilityWindowResponseDto struct {
+	ID          int    `json:"id"`
+	AlchemistID uint   `json:"alchemist_id"`
+	Kind        string `json:"kind"`
+	StartsAt    string `json:"starts_at"`
+	EndsAt      string `json:"ends_at"`
+	Notes       string `json:"notes"`
+	CreatedBy   string `json:"created_by"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// timesInOrder comprueba que end sea posterior a start cuando ambos son
+// fechas RFC3339 válidas (el formato lo validan las etiquetas).
+func timesInOrder(startField, start, endField, end string) []FieldError {
+	s, err1 := time.Parse(time.RFC3339, start)
+	e, err2 := time.Parse(time.RFC3339, end)
+	if err1 != nil || err2 != nil || e.After(s) {
+		return nil
+	}
+	return []FieldError{{Field: endField, Message: "must be after " + startField}}
+}
+
+func (d *AvailabilityWindowRequestDto) Validate() []FieldError {
+	return timesInOrder("starts_at", d.StartsAt, "ends_at", d.EndsAt)
+}
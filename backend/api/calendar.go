@@ -0,0 +1,7 @@
+package api
+
+type CalendarTokenResponseDto struct {
+	AlchemistID uint   `json:"alchemist_id"`
+	Token       string `json:"token"` // Solo se muestra al generarlo
+	URL         string `json:"url"`
+}
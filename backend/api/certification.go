@@ -0,0 +1,46 @@
+package api
+
+type CertificationRequestDto struct {
+	Kind             string `json:"kind" validate:"omitempty,oneof=license certification"` // "license" (por defecto) | "certification"
+	Name             string `json:"name" validate:"required,max=150"`
+	Number           string `json:"number" validate:"max=100"`
+	IssuingAuthority string `json:"issuing_authority" validate:"required,max=150"`
+	IssuedAt         string `json:"issued_at" validate:"omitempty,rfc3339"` // RFC3339
+	ExpiresAt        string `json:"expires_at" validate:"required,rfc3339"` // RFC3339
+}
+
+type CertificationEditRequestDto struct {
+	Kind             *string `json:"kind,omitempty" validate:"oneof=license certification"`
+	Name             *string `json:"name,omitempty" validate:"notblank,max=150"`
+	Number           *string `json:"number,omitempty" validate:"max=100"`
+	IssuingAuthority *string `json:"issuing_authority,omitempty" validate:"notblank,max=150"`
+	IssuedAt         *string `json:"issued_at,omitempty" validate:"rfc3339"`
+	ExpiresAt        *string `json:"expires_at,omitempty" validate:"rfc3339"`
+}
+
+type CertificationRevokeRequestDto struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type CertificationResponseDto struct {
+	ID               int    `json:"id"`
+	AlchemistID      uint   `json:"alchemist_id"`
+	Kind             string `json:"kind"`
+	Name             string `json:"name"`
+	Number           string `json:"number"`
+	IssuingAuthority string `json:"issuing_authority"`
+	IssuedAt         string `json:"issued_at"`
+	ExpiresAt        string `json:"expires_at"`
+	Status           string `json:"status"` // "active" | "expired" | "revoked"
+	RevokedAt        string `json:"revoked_at,omitempty"`
+	RevokedBy        string `json:"revoked_by,omitempty"`
+	RevocationReason string `json:"revocation_reason,omitempty"`
+	CreatedAt        string `json:"created_at"`
+}
+
+func (d *CertificationRequestDto) Validate() []FieldError {
+	if d.IssuedAt == "" {
+		return nil
+	}
+	return timesInOrder("issued_at", d.IssuedAt, "expires_at", d.ExpiresAt)
+}
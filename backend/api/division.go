@@ -0,0 +1,24 @@
+package api
+
+type DivisionRequestDto struct {
+	Name            string `json:"name" validate:"required,max=100"`
+	Description     string `json:"description" validate:"max=1000"`
+	ParentID        *uint  `json:"parent_id,omitempty" validate:"min=1"`
+	SupervisorEmail string `json:"supervisor_email" validate:"omitempty,email"`
+}
+
+type DivisionEditRequestDto struct {
+	Name            *string `json:"name,omitempty" validate:"notblank,max=100"`
+	Description     *string `json:"description,omitempty" validate:"max=1000"`
+	ParentID        *uint   `json:"parent_id,omitempty"` // 0 la convierte en raíz
+	SupervisorEmail *string `json:"supervisor_email,omitempty" validate:"omitempty,email"`
+}
+
+type DivisionResponseDto struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	ParentID        *uint  `json:"parent_id,omitempty"`
+	SupervisorEmail string `json:"supervisor_email,omitempty"`
+	CreatedAt       string `json:"created_at"`
+}
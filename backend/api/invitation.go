@@ -0,0 +1,19 @@
+package api
+
+type InvitationRequestDto struct {
+	Role           string `json:"role" validate:"required,max=32"`
+	Email          string `json:"email" validate:"omitempty,email,max=255"`  // Restringe el código a esta dirección
+	ExpiresInHours int    `json:"expires_in_hours" validate:"min=0,max=720"` // 0 = valor por defecto (72 h)
+}
+
+type InvitationResponseDto struct {
+	ID        int    `json:"id"`
+	Role      string `json:"role"`
+	Email     string `json:"email,omitempty"`
+	Code      string `json:"code,omitempty"` // Solo se devuelve al crearla
+	ExpiresAt string `json:"expires_at"`
+	CreatedBy string `json:"created_by"`
+	UsedAt    string `json:"used_at,omitempty"`
+	UsedBy    string `json:"used_by,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
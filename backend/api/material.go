@@ -1,9 +1,9 @@
 package api
 
 type MaterialRequestDto struct {
-	Name     string  `json:"name"`
-	Category string  `json:"category"`
-	Quantity float64 `json:"quantity"`
+	Name     string  `json:"name" validate:"required,max=100"`
+	Category string  `json:"category" validate:"max=100"`
+	Quantity float64 `json:"quantity" validate:"min=0"`
 }
 
 type MaterialResponseDto struct {
@@ -15,7 +15,7 @@ type MaterialResponseDto struct {
 }
 
 type MaterialEditRequestDto struct {
-	Name     *string  `json:"name,omitempty"`
-	Category *string  `json:"category,omitempty"`
-	Quantity *float64 `json:"quantity,omitempty"`
+	Name     *string  `json:"name,omitempty" validate:"notblank,max=100"`
+	Category *string  `json:"category,omitempty" validate:"max=100"`
+	Quantity *float64 `json:"quantity,omitempty" validate:"min=0"`
 }
@@ -1,26 +1,36 @@
 package api
 
 type MissionRequestDto struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Difficulty  string `json:"difficulty"`
+	Title       string `json:"title" validate:"required,max=200"`
+	Description string `json:"description" validate:"max=5000"`
+	Difficulty  string `json:"difficulty" validate:"max=50"`
 	AssignedTo  uint   `json:"assigned_to"`
+	DueDate     string `json:"due_date" validate:"omitempty,rfc3339"` // RFC3339
+}
+
+type MissionMaterialDto struct {
+	MaterialID uint    `json:"material_id"`
+	Quantity   float64 `json:"quantity"`
 }
 
 type MissionResponseDto struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Difficulty  string `json:"difficulty"`
-	Status      string `json:"status"`
-	AssignedTo  uint   `json:"assigned_to"`
-	CreatedAt   string `json:"created_at"`
+	ID          int                  `json:"id"`
+	Title       string               `json:"title"`
+	Description string               `json:"description"`
+	Difficulty  string               `json:"difficulty"`
+	Status      string               `json:"status"`
+	AssignedTo  uint                 `json:"assigned_to"`
+	DueDate     string               `json:"due_date,omitempty"`
+	TemplateID  *uint                `json:"template_id,omitempty"`
+	Materials   []MissionMaterialDto `json:"materials,omitempty"`
+	CreatedAt   string               `json:"created_at"`
 }
 
 type MissionEditRequestDto struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
-	Difficulty  *string `json:"difficulty,omitempty"`
-	Status      *string `json:"status,omitempty"`
+	Title       *string `json:"title,omitempty" validate:"notblank,max=200"`
+	Description *string `json:"description,omitempty" validate:"max=5000"`
+	Difficulty  *string `json:"difficulty,omitempty" validate:"max=50"`
+	Status      *string `json:"status,omitempty" validate:"oneof=pendiente en_progreso completada cancelada"`
 	AssignedTo  *uint   `json:"assigned_to,omitempty"`
+	DueDate     *string `json:"due_date,omitempty" validate:"omitempty,rfc3339"` // "" elimina la fecha límite
 }
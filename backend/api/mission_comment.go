@@ -0,0 +1,35 @@
+package api
+
+type MissionCommentRequestDto struct {
+	Body     string `json:"body" validate:"required,max=5000"` // Se admiten menciones con @correo
+	ParentID *uint  `json:"parent_id,omitempty" validate:"min=1"`
+}
+
+type MissionCommentEditRequestDto struct {
+	Body *string `json:"body,omitempty" validate:"notblank,max=5000"`
+}
+
+type MissionCommentResponseDto struct {
+	ID          int                          `json:"id"`
+	MissionID   uint                         `json:"mission_id"`
+	ParentID    *uint                        `json:"parent_id,omitempty"`
+	AuthorEmail string                       `json:"author_email"`
+	Body        string                       `json:"body"`
+	Mentions    []string                     `json:"mentions"`
+	Replies     []*MissionCommentResponseDto `json:"replies,omitempty"`
+	CreatedAt   string                       `json:"created_at"`
+	UpdatedAt   string                       `json:"updated_at"`
+}
+
+// MissionTimelineEntryDto es un evento del historial de la misión. Type puede
+// ser "comment", "status_change" o "audit".
+type MissionTimelineEntryDto struct {
+	Type       string                     `json:"type"`
+	At         string                     `json:"at"`
+	Actor      string                     `json:"actor"`
+	Summary    string                     `json:"summary"`
+	Comment    *MissionCommentResponseDto `json:"comment,omitempty"`
+	FromStatus string                     `json:"from_status,omitempty"`
+	ToStatus   string                     `json:"to_status,omitempty"`
+	Audit      *AuditResponseDto          `json:"audit,omitempty"`
+}
@@ -0,0 +1,33 @@
+package api
+
+type MissionDependencyRequestDto struct {
+	DependsOnID uint `json:"depends_on_id" validate:"required"`
+}
+
+type MissionGraphNodeDto struct {
+	ID     uint   `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	Depth  int    `json:"depth"` // Distancia a la misión consultada
+}
+
+// MissionGraphEdgeDto indica que From bloquea a To.
+type MissionGraphEdgeDto struct {
+	From uint `json:"from"`
+	To   uint `json:"to"`
+}
+
+type MissionDependenciesResponseDto struct {
+	MissionID uint                  `json:"mission_id"`
+	BlockedBy []MissionGraphNodeDto `json:"blocked_by"`
+	Blocks    []MissionGraphNodeDto `json:"blocks"`
+}
+
+type MissionGraphResponseDto struct {
+	MissionID          uint                  `json:"mission_id"`
+	Upstream           []MissionGraphNodeDto `json:"upstream"`
+	Downstream         []MissionGraphNodeDto `json:"downstream"`
+	Edges              []MissionGraphEdgeDto `json:"edges"`
+	CriticalPath       []uint                `json:"critical_path"`
+	CriticalPathLength int                   `json:"critical_path_length"`
+}
@@ -0,0 +1,30 @@
+package api
+
+type MissionTaskRequestDto struct {
+	Title       string `json:"title" validate:"required,max=200"`
+	Description string `json:"description" validate:"max=2000"`
+	AssignedTo  uint   `json:"assigned_to"`
+	Position    *int   `json:"position,omitempty" validate:"min=0"`
+	Mandatory   *bool  `json:"mandatory,omitempty"`
+}
+
+type MissionTaskResponseDto struct {
+	ID          int    `json:"id"`
+	MissionID   uint   `json:"mission_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	AssignedTo  uint   `json:"assigned_to"`
+	Status      string `json:"status"`
+	Position    int    `json:"position"`
+	Mandatory   bool   `json:"mandatory"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type MissionTaskEditRequestDto struct {
+	Title       *string `json:"title,omitempty" validate:"notblank,max=200"`
+	Description *string `json:"description,omitempty" validate:"max=2000"`
+	AssignedTo  *uint   `json:"assigned_to,omitempty"`
+	Status      *string `json:"status,omitempty" validate:"oneof=pendiente en_progreso completada"`
+	Position    *int    `json:"position,omitempty" validate:"min=0"`
+	Mandatory   *bool   `json:"mandatory,omitempty"`
+}
@@ -0,0 +1,53 @@
+package api
+
+type MissionTemplateMaterialDto struct {
+	MaterialID uint    `json:"material_id" validate:"required"`
+	Quantity   float64 `json:"quantity" validate:"min=0"`
+}
+
+type MissionTemplateTaskDto struct {
+	Title       string `json:"title" validate:"required,max=200"`
+	Description string `json:"description" validate:"max=2000"`
+	AssignedTo  uint   `json:"assigned_to"`
+	Mandatory   *bool  `json:"mandatory,omitempty"`
+}
+
+type MissionTemplateRequestDto struct {
+	Title       string                       `json:"title" validate:"required,max=200"`
+	Description string                       `json:"description" validate:"max=5000"`
+	Difficulty  string                       `json:"difficulty" validate:"max=50"`
+	AssignedTo  uint                         `json:"assigned_to"`
+	Materials   []MissionTemplateMaterialDto `json:"materials"`
+	Tasks       []MissionTemplateTaskDto     `json:"tasks"`
+	Recurrence  string                       `json:"recurrence" validate:"max=200"`          // RRULE: FREQ=DAILY|WEEKLY|MONTHLY;INTERVAL=n
+	StartsAt    string                       `json:"starts_at" validate:"omitempty,rfc3339"` // RFC3339, por defecto ahora
+	Active      *bool                        `json:"active,omitempty"`
+}
+
+type MissionTemplateEditRequestDto struct {
+	Title       *string                       `json:"title,omitempty" validate:"notblank,max=200"`
+	Description *string                       `json:"description,omitempty" validate:"max=5000"`
+	Difficulty  *string                       `json:"difficulty,omitempty" validate:"max=50"`
+	AssignedTo  *uint                         `json:"assigned_to,omitempty"`
+	Materials   *[]MissionTemplateMaterialDto `json:"materials,omitempty"`
+	Tasks       *[]MissionTemplateTaskDto     `json:"tasks,omitempty"`
+	Recurrence  *string                       `json:"recurrence,omitempty" validate:"max=200"`
+	StartsAt    *string                       `json:"starts_at,omitempty" validate:"rfc3339"`
+	Active      *bool                         `json:"active,omitempty"`
+}
+
+type MissionTemplateResponseDto struct {
+	ID          int                          `json:"id"`
+	Title       string                       `json:"title"`
+	Description string                       `json:"description"`
+	Difficulty  string                       `json:"difficulty"`
+	AssignedTo  uint                         `json:"assigned_to"`
+	Materials   []MissionTemplateMaterialDto `json:"materials"`
+	Tasks       []MissionTemplateTaskDto     `json:"tasks"`
+	Recurrence  string                       `json:"recurrence"`
+	StartsAt    string                       `json:"starts_at"`
+	NextRunAt   string                       `json:"next_run_at,omitempty"`
+	LastRunAt   string                       `json:"last_run_at,omitempty"`
+	Active      bool                         `json:"active"`
+	CreatedAt   string                       `json:"created_at"`
+}
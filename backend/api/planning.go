@@ -0,0 +1,32 @@
+package api
+
+type PlannedMissionDto struct {
+	ID         int     `json:"id"`
+	Title      string  `json:"title"`
+	Difficulty string  `json:"difficulty"`
+	DueDate    string  `json:"due_date,omitempty"`
+	Hours      float64 `json:"hours"` // Parte de la estimación que cae en el periodo
+}
+
+type AlchemistCapacityDto struct {
+	AlchemistID          uint                `json:"alchemist_id"`
+	Name                 string              `json:"name"`
+	DivisionID           *uint               `json:"division_id,omitempty"`
+	WeeklyCapacityHours  float64             `json:"weekly_capacity_hours"`
+	UnavailableHours     float64             `json:"unavailable_hours"`
+	CapacityHours        float64             `json:"capacity_hours"`
+	MissionHours         float64             `json:"mission_hours"`
+	TransmutationHours   float64             `json:"transmutation_hours"`
+	CommittedHours       float64             `json:"committed_hours"`
+	Utilization          float64             `json:"utilization"` // committed / capacity
+	Overloaded           bool                `json:"overloaded"`
+	OpenMissions         []PlannedMissionDto `json:"open_missions"`
+	QueuedTransmutations int                 `json:"queued_transmutations"`
+}
+
+type CapacityResponseDto struct {
+	From       string                  `json:"from"`
+	To         string                  `json:"to"`
+	Overloaded int                     `json:"overloaded"`
+	Alchemists []*AlchemistCapacityDto `json:"alchemists"`
+}
@@ -0,0 +1,60 @@
+package api
+
+type RankDto struct {
+	Name                        string `json:"name"`
+	Position                    int    `json:"position"`
+	MinCompletedMissions        int    `json:"min_completed_missions"`
+	MinSuccessfulTransmutations int    `json:"min_successful_transmutations"`
+}
+
+type PromotionEligibilityDto struct {
+	AlchemistID              uint   `json:"alchemist_id"`
+	Name                     string `json:"name"`
+	CurrentRank              string `json:"current_rank"`
+	NextRank                 string `json:"next_rank,omitempty"`
+	CompletedMissions        int    `json:"completed_missions"`
+	SuccessfulTransmutations int    `json:"successful_transmutations"`
+	RequiredMissions         int    `json:"required_missions"`
+	RequiredTransmutations   int    `json:"required_transmutations"`
+	Eligible                 bool   `json:"eligible"`
+	PendingRequest           bool   `json:"pending_request"`
+}
+
+type PromotionRequestDto struct {
+	ToRank        string `json:"to_rank" validate:"max=100"` // Opcional: por defecto el siguiente peldaño
+	Justification string `json:"justification" validate:"required,max=2000"`
+}
+
+type PromotionReviewRequestDto struct {
+	Comment string `json:"comment" validate:"max=2000"`
+}
+
+type PromotionResponseDto struct {
+	ID                       int    `json:"id"`
+	AlchemistID              uint   `json:"alchemist_id"`
+	FromRank                 string `json:"from_rank"`
+	ToRank                   string `json:"to_rank"`
+	Justification            string `json:"justification"`
+	RequestedBy              string `json:"requested_by"`
+	Status                   string `json:"status"`
+	CompletedMissions        int    `json:"completed_missions"`
+	SuccessfulTransmutations int    `json:"successful_transmutations"`
+	Eligible                 bool   `json:"eligible"`
+	ReviewedBy               string `json:"reviewed_by,omitempty"`
+	ReviewedAt               string `json:"reviewed_at,omitempty"`
+	ReviewComment            string `json:"review_comment,omitempty"`
+	CreatedAt                string `json:"created_at"`
+}
+
+type RankChangeResponseDto struct {
+	FromRank           string `json:"from_rank"`
+	ToRank             string `json:"to_rank"`
+	PromotionRequestID *uint  `json:"promotion_request_id,omitempty"`
+	ChangedBy          string `json:"changed_by"`
+	Reason             string `json:"reason"`
+	CreatedAt          string `json:"created_at"`
+}
+
+func (d *PromotionRequestDto) Validate() []FieldError {
+	return validRank("to_rank", d.ToRank)
+}
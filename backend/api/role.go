@@ -0,0 +1,65 @@
+package api
+
+import (
+	"backend-avanzada/models"
+	"fmt"
+)
+
+type RoleRequestDto struct {
+	Name        string   `json:"name" validate:"required,max=32"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleEditRequestDto struct {
+	Description *string   `json:"description,omitempty" validate:"max=255"`
+	Permissions *[]string `json:"permissions,omitempty"` // Reemplaza la lista completa
+}
+
+type RoleResponseDto struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	System      bool     `json:"system"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// validRoleName admite minúsculas, dígitos y guiones bajos, empezando por letra.
+func validRoleName(field, name string) []FieldError {
+	for i, c := range name {
+		if c >= 'a' && c <= 'z' || i > 0 && (c >= '0' && c <= '9' || c == '_') {
+			continue
+		}
+		return []FieldError{{Field: field, Message: "must be lowercase letters, digits or underscores, starting with a letter"}}
+	}
+	return nil
+}
+
+// validPermissions exige permisos del catálogo y sin repetir.
+func validPermissions(field string, perms []string) []FieldError {
+	var errs []FieldError
+	seen := make(map[string]bool, len(perms))
+	for i, p := range perms {
+		name := fmt.Sprintf("%s[%d]", field, i)
+		switch {
+		case !models.IsPermission(p):
+			errs = append(errs, FieldError{Field: name, Message: fmt.Sprintf("unknown permission %q", p)})
+		case seen[p]:
+			errs = append(errs, FieldError{Field: name, Message: "is listed twice"})
+		}
+		seen[p] = true
+	}
+	return errs
+}
+
+func (d *RoleRequestDto) Validate() []FieldError {
+	return append(validRoleName("name", d.Name), validPermissions("permissions", d.Permissions)...)
+}
+
+func (d *RoleEditRequestDto) Validate() []FieldError {
+	if d.Permissions == nil {
+		return nil
+	}
+	return validPermissions("permissions", *d.Permissions)
+}
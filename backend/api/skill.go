@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+)
+
+type SkillRequestDto struct {
+	Name        string `json:"name" validate:"required,max=50"`
+	Description string `json:"description" validate:"max=500"`
+}
+
+type SkillEditRequestDto struct {
+	Name        *string `json:"name,omitempty" validate:"notblank,max=50"`
+	Description *string `json:"description,omitempty" validate:"max=500"`
+}
+
+type SkillResponseDto struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type AlchemistSkillRequestDto struct {
+	Level int `json:"level" validate:"min=1,max=5"` // 1–5
+}
+
+type SkillEndorsementRequestDto struct {
+	Comment string `json:"comment" validate:"max=500"`
+}
+
+type SkillEndorsementDto struct {
+	EndorsedBy string `json:"endorsed_by"`
+	Level      int    `json:"level"`
+	Comment    string `json:"comment"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type AlchemistSkillResponseDto struct {
+	AlchemistID  uint                  `json:"alchemist_id"`
+	SkillID      uint                  `json:"skill_id"`
+	Skill        string                `json:"skill"`
+	Level        int                   `json:"level"`
+	Endorsed     bool                  `json:"endorsed"`
+	Endorsements []SkillEndorsementDto `json:"endorsements"`
+	UpdatedBy    string                `json:"updated_by"`
+	UpdatedAt    string                `json:"updated_at"`
+}
+
+type SkillRequirementDto struct {
+	SkillID  uint   `json:"skill_id" validate:"required"`
+	Skill    string `json:"skill,omitempty"`
+	MinLevel int    `json:"min_level" validate:"min=1,max=5"`
+}
+
+type SkillRequirementsRequestDto struct {
+	Skills []SkillRequirementDto `json:"skills"`
+}
+
+type SkillRequirementsResponseDto struct {
+	Entity   string                `json:"entity"`
+	EntityID uint                  `json:"entity_id"`
+	Skills   []SkillRequirementDto `json:"skills"`
+}
+
+func (d *SkillRequirementsRequestDto) Validate() []FieldError {
+	var errs []FieldError
+	seen := make(map[uint]bool, len(d.Skills))
+	for i, s := range d.Skills {
+		if s.SkillID != 0 && seen[s.SkillID] {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("skills[%d].skill_id", i), Message: "is listed twice"})
+		}
+		seen[s.SkillID] = true
+	}
+	return errs
+}
@@ -0,0 +1,36 @@
+package api
+
+type TransmutationStatsDto struct {
+	Total                int     `json:"total"`
+	Completed            int     `json:"completed"`
+	InProgress           int     `json:"in_progress"`
+	Failed               int     `json:"failed"`
+	Cancelled            int     `json:"cancelled"`
+	SuccessRate          float64 `json:"success_rate"` // completadas / (completadas + fallidas), 0–1
+	AvgProcessingSeconds float64 `json:"avg_processing_seconds"`
+}
+
+type MissionStatsDto struct {
+	Assigned       int `json:"assigned"`
+	Completed      int `json:"completed"`
+	OnTime         int `json:"on_time"`
+	Late           int `json:"late"`
+	WithoutDueDate int `json:"without_due_date"`
+	Overdue        int `json:"overdue"`
+}
+
+type MaterialConsumptionDto struct {
+	MaterialID      uint    `json:"material_id"`
+	Name            string  `json:"name"`
+	Transmutations  int     `json:"transmutations"`
+	MissionQuantity float64 `json:"mission_quantity"`
+}
+
+type AlchemistStatsResponseDto struct {
+	AlchemistID    uint                     `json:"alchemist_id"`
+	Name           string                   `json:"name"`
+	Transmutations TransmutationStatsDto    `json:"transmutations"`
+	Missions       MissionStatsDto          `json:"missions"`
+	Materials      []MaterialConsumptionDto `json:"materials"`
+	GeneratedAt    string                   `json:"generated_at"`
+}
@@ -2,8 +2,9 @@ package api
 
 type TransmutationRequestDto struct {
 	AlchemistID uint   `json:"alchemist_id"`
-	MaterialID  uint   `json:"material_id"`
-	Formula     string `json:"formula"`
+	MaterialID  uint   `json:"material_id" validate:"required"`
+	Formula     string `json:"formula" validate:"max=2000"`
+	ScheduledAt string `json:"scheduled_at" validate:"omitempty,rfc3339"` // RFC3339
 }
 
 type TransmutationResponseDto struct {
@@ -12,11 +13,13 @@ type TransmutationResponseDto struct {
 	MaterialID  uint   `json:"material_id"`
 	Status      string `json:"status"`
 	Result      string `json:"result"`
+	ScheduledAt string `json:"scheduled_at,omitempty"`
 	CreatedAt   string `json:"created_at"`
 }
 
 type TransmutationEditRequestDto struct {
-	Formula *string `json:"formula,omitempty"`
-	Status  *string `json:"status,omitempty"`
-	Result  *string `json:"result,omitempty"`
+	Formula     *string `json:"formula,omitempty" validate:"max=2000"`
+	Status      *string `json:"status,omitempty" validate:"oneof=en_proceso completada fallida cancelada"`
+	Result      *string `json:"result,omitempty" validate:"max=2000"`
+	ScheduledAt *string `json:"scheduled_at,omitempty" validate:"omitempty,rfc3339"` // "" elimina la programación
 }
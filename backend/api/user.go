@@ -0,0 +1,20 @@
+package api
+
+type UserRoleRequestDto struct {
+	Role string `json:"role" validate:"required,max=32"`
+}
+
+type UserResponseDto struct {
+	ID                    int    `json:"id"`
+	Email                 string `json:"email"`
+	Role                  string `json:"role"`
+	AlchemistID           *uint  `json:"alchemist_id,omitempty"`
+	EmailVerified         bool   `json:"email_verified"`
+	TwoFactorEnabled      bool   `json:"two_factor_enabled"`
+	Disabled              bool   `json:"disabled"`
+	DisabledAt            string `json:"disabled_at,omitempty"`
+	PasswordResetRequired bool   `json:"password_reset_required"`
+	OIDCLinked            bool   `json:"oidc_linked"`
+	OIDCLinkPending       bool   `json:"oidc_link_pending"`
+	CreatedAt             string `json:"created_at"`
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Las reglas se declaran con la etiqueta `validate` en los DTO, separadas por
+// comas:
+//
+//	required   el campo debe venir (punteros) y no estar vacío
+//	notblank   si viene, no puede ser una cadena en blanco
+//	omitempty  omite el resto de reglas si el valor está vacío
+//	min=N      número >= N; longitud >= N para cadenas y listas
+//	max=N      número <= N; longitud <= N para cadenas y listas
+//	oneof=a b  el valor debe ser uno de los indicados
+//	email      dirección de correo válida
+//	rfc3339    fecha en formato RFC3339
+//
+// En los punteros las reglas (salvo required) solo se aplican si el campo
+// viene en la petición. Los structs y listas de structs anidados se validan
+// recursivamente. Las reglas entre campos se implementan con Validator.
+
+// FieldError describe un campo que no supera la validación.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError agrupa los errores de validación de una petición.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		parts = append(parts, fe.Field+" "+fe.Message)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Validator lo implementan los DTO con reglas que no caben en las etiquetas.
+type Validator interface {
+	Validate() []FieldError
+}
+
+// Validate comprueba las etiquetas `validate` de dto (struct o puntero a
+// struct) y sus reglas propias si implementa Validator. Devuelve un
+// *ValidationError o nil.
+func Validate(dto any) error {
+	errs := validateValue(reflect.ValueOf(dto), "")
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+func validateValue(v reflect.Value, prefix string) []FieldError {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	var errs []FieldError
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := prefix + fieldName(f)
+			fv := v.Field(i)
+			if tag := f.Tag.Get("validate"); tag != "" {
+				if fe := checkField(fv, name, tag); fe != nil {
+					errs = append(errs, *fe)
+					continue
+				}
+			}
+			errs = append(errs, validateValue(fv, name+".")...)
+		}
+		var val Validator
+		if v.CanAddr() {
+			val, _ = v.Addr().Interface().(Validator)
+		} else {
+			val, _ = v.Interface().(Validator)
+		}
+		if val != nil {
+			for _, fe := range val.Validate() {
+				fe.Field = prefix + fe.Field
+				errs = append(errs, fe)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		base := strings.TrimSuffix(prefix, ".")
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validateValue(v.Index(i), fmt.Sprintf("%s[%d].", base, i))...)
+		}
+	}
+	return errs
+}
+
+func fieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
+
+// checkField aplica las reglas de la etiqueta y devuelve el primer error.
+func checkField(v reflect.Value, name, tag string) *FieldError {
+	rules := strings.Split(tag, ",")
+	fail := func(msg string) *FieldError { return &FieldError{Field: name, Message: msg} }
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			for _, rule := range rules {
+				if rule == "required" {
+					return fail("is required")
+				}
+			}
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		key, arg, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			if isBlank(v) {
+				return fail("is required")
+			}
+		case "notblank":
+			if v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "" {
+				return fail("must not be blank")
+			}
+		case "omitempty":
+			if v.IsZero() {
+				return nil
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				panic(fmt.Sprintf("api: invalid %s rule on %s: %q", key, name, arg))
+			}
+			if msg := checkBound(v, key, limit, arg); msg != "" {
+				return fail(msg)
+			}
+		case "oneof":
+			options := strings.Fields(arg)
+			value := fmt.Sprint(v.Interface())
+			found := false
+			for _, o := range options {
+				if o == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fail("must be one of: " + strings.Join(options, ", "))
+			}
+		case "email":
+			addr, err := mail.ParseAddress(v.String())
+			if err != nil || addr.Address != v.String() {
+				return fail("must be a valid email address")
+			}
+		case "rfc3339":
+			if _, err := time.Parse(time.RFC3339, v.String()); err != nil {
+				return fail("must be an RFC3339 timestamp")
+			}
+		default:
+			panic(fmt.Sprintf("api: unknown validation rule %q on %s", key, name))
+		}
+	}
+	return nil
+}
+
+func isBlank(v reflect.Value) bool {
+	if v.Kind() == reflect.String {
+		return strings.TrimSpace(v.String()) == ""
+	}
+	return v.IsZero()
+}
+
+func checkBound(v reflect.Value, key string, limit float64, arg string) string {
+	var n float64
+	unit := ""
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	default:
+		return ""
+	}
+	if key == "min" && n < limit {
+		if unit != "" {
+			return "must have at least " + arg + unit
+		}
+		return "must be at least " + arg
+	}
+	if key == "max" && n > limit {
+		if unit != "" {
+			return "must have at most " + arg + unit
+		}
+		return "must be at most " + arg
+	}
+	return ""
+}
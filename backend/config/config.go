@@ -1,12 +1,57 @@
 package config
 
 type Config struct {
-	Address                     string  `json:"address"`
-	Database                    string  `json:"database"`
-	KillDuration                int     `json:"kill_duration"`
-	KillDurationWithDescription int     `json:"kill_duration_with_desc"`
-	RedisAddress                string  `json:"redis_address"`
-	VerificationIntervalMinutes int     `json:"verification_interval_minutes"`
-	PendingTransmutationHours   int     `json:"pending_transmutation_hours"`
-	MaterialLowStockThreshold   float64 `json:"material_low_stock_threshold"`
+	Address                          string             `json:"address"`
+	Database                         string             `json:"database"`
+	KillDuration                     int                `json:"kill_duration"`
+	KillDurationWithDescription      int                `json:"kill_duration_with_desc"`
+	RedisAddress                     string             `json:"redis_address"`
+	VerificationIntervalMinutes      int                `json:"verification_interval_minutes"`
+	PendingTransmutationHours        int                `json:"pending_transmutation_hours"`
+	MaterialLowStockThreshold        float64            `json:"material_low_stock_threshold"`
+	RecurringMissionsIntervalMinutes int                `json:"recurring_missions_interval_minutes"`
+	CertificationExpiryWarningDays   int                `json:"certification_expiry_warning_days"`
+	RequireActiveLicense             bool               `json:"require_active_license"`
+	StorageDir                       string             `json:"storage_dir"`
+	MaxUploadMB                      int                `json:"max_upload_mb"`
+	WeeklyCapacityHours              float64            `json:"weekly_capacity_hours"`
+	MissionHoursByDifficulty         map[string]float64 `json:"mission_hours_by_difficulty"`
+	DefaultMissionHours              float64            `json:"default_mission_hours"`
+	TransmutationHours               float64            `json:"transmutation_hours"`
+	AccessTokenMinutes               int                `json:"access_token_minutes"`
+	RefreshTokenDays                 int                `json:"refresh_token_days"`
+	MailDriver                       string             `json:"mail_driver"` // "smtp" | "file" | "log"
+	MailFrom                         string             `json:"mail_from"`
+	MailDir                          string             `json:"mail_dir"`
+	SMTPHost                         string             `json:"smtp_host"`
+	SMTPPort                         int                `json:"smtp_port"`
+	AppURL                           string             `json:"app_url"`
+	PasswordResetMinutes             int                `json:"password_reset_minutes"`
+	EmailVerificationHours           int                `json:"email_verification_hours"`
+	RequireVerifiedEmail             bool               `json:"require_verified_email"`
+	LoginFreeAttempts                int                `json:"login_free_attempts"`
+	LoginLockoutAttempts             int                `json:"login_lockout_attempts"`
+	LoginIPLockoutAttempts           int                `json:"login_ip_lockout_attempts"`
+	LoginLockoutMinutes              int                `json:"login_lockout_minutes"`
+	TrustProxyHeaders                bool               `json:"trust_proxy_headers"`
+	TrustedProxies                   []string           `json:"trusted_proxies"` // IPs o CIDR; vacío = solo el proxy que conecta
+	RequireSupervisor2FA             bool               `json:"require_supervisor_2fa"`
+	JWTKeysDir                       string             `json:"jwt_keys_dir"`           // Vacío = HS256 con JWT_SECRET
+	JWTActiveKeyID                   string             `json:"jwt_active_kid"`         // Clave de firma dentro de jwt_keys_dir
+	JWTAcceptHS256                   bool               `json:"jwt_accept_hs256"`       // Aceptar tokens HS256 previos durante la migración
+	OIDCIssuer                       string             `json:"oidc_issuer"`            // Vacío = sin login SSO
+	OIDCClientID                     string             `json:"oidc_client_id"`         // El secreto se lee de OIDC_CLIENT_SECRET
+	OIDCRedirectURL                  string             `json:"oidc_redirect_url"`      // URL pública de /auth/oidc/callback
+	OIDCAuthURL                      string             `json:"oidc_authorization_url"` // Opcional: authorization_endpoint tal como lo ve el navegador
+	OIDCScopes                       []string           `json:"oidc_scopes"`
+	OIDCRoleClaim                    string             `json:"oidc_role_claim"`
+	OIDCRoleMappings                 []OIDCRoleMapping  `json:"oidc_role_mappings"` // La primera que encaje decide el rol
+	OIDCDefaultRole                  string             `json:"oidc_default_role"`  // Vacío = rechazar a quien no encaje
+	OIDCPostLoginURL                 string             `json:"oidc_post_login_url"`
+}
+
+// OIDCRoleMapping asigna Role a quien traiga Value en la claim de roles.
+type OIDCRoleMapping struct {
+	Value string `json:"value"`
+	Role  string `json:"role"`
 }
@@ -0,0 +1,99 @@
+// Package ical genera calendarios iCalendar (RFC 5545) con eventos simples.
+package ical
+
+import (
+	"strings"
+	"time"
+)
+
+const dateTimeFormat = "20060102T150405Z"
+
+// Event es un VEVENT. Si End es cero el evento se publica como un instante.
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Categories   []string
+	Start        time.Time
+	End          time.Time
+	LastModified time.Time
+	Status       string // TENTATIVE | CONFIRMED | CANCELLED
+}
+
+// Calendar es un VCALENDAR publicado para suscripción.
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Encode serializa el calendario con finales de línea CRLF y líneas plegadas a
+// 75 octetos, como exige la RFC 5545.
+func (c *Calendar) Encode(stamp time.Time) string {
+	var b strings.Builder
+	w := func(name, value string) { writeLine(&b, name+":"+value) }
+
+	w("BEGIN", "VCALENDAR")
+	w("VERSION", "2.0")
+	w("PRODID", c.ProdID)
+	w("CALSCALE", "GREGORIAN")
+	w("METHOD", "PUBLISH")
+	if c.Name != "" {
+		w("X-WR-CALNAME", Escape(c.Name))
+	}
+	for _, e := range c.Events {
+		w("BEGIN", "VEVENT")
+		w("UID", e.UID)
+		w("DTSTAMP", stamp.UTC().Format(dateTimeFormat))
+		w("DTSTART", e.Start.UTC().Format(dateTimeFormat))
+		if !e.End.IsZero() {
+			w("DTEND", e.End.UTC().Format(dateTimeFormat))
+		}
+		w("SUMMARY", Escape(e.Summary))
+		if e.Description != "" {
+			w("DESCRIPTION", Escape(e.Description))
+		}
+		if len(e.Categories) > 0 {
+			cats := make([]string, 0, len(e.Categories))
+			for _, cat := range e.Categories {
+				cats = append(cats, Escape(cat))
+			}
+			w("CATEGORIES", strings.Join(cats, ","))
+		}
+		if e.Status != "" {
+			w("STATUS", e.Status)
+		}
+		if !e.LastModified.IsZero() {
+			w("LAST-MODIFIED", e.LastModified.UTC().Format(dateTimeFormat))
+		}
+		w("END", "VEVENT")
+	}
+	w("END", "VCALENDAR")
+	return b.String()
+}
+
+// Escape aplica el escape de valores TEXT (RFC 5545, sección 3.3.11).
+func Escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return r.Replace(s)
+}
+
+// writeLine pliega la línea a 75 octetos sin partir caracteres UTF-8. Las
+// continuaciones empiezan con un espacio, que cuenta para el límite.
+func writeLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool { return c&0xC0 != 0x80 }
@@ -0,0 +1,90 @@
+// Package imaging genera miniaturas de imágenes usando solo la biblioteca
+// estándar.
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Registra el decodificador GIF
+	"image/jpeg"
+	_ "image/png" // Registra el decodificador PNG
+	"io"
+)
+
+// MaxPixels limita el tamaño de las imágenes que se decodifican para evitar
+// agotar la memoria con ficheros pequeños que declaran dimensiones enormes.
+const MaxPixels = 40_000_000
+
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+// Decode lee una imagen JPEG, PNG o GIF comprobando antes sus dimensiones.
+func Decode(r io.ReadSeeker) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, format, ErrTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, format, err
+	}
+	return image.Decode(r)
+}
+
+// Thumbnail reduce la imagen para que su lado mayor mida como mucho max
+// píxeles, promediando cada bloque de origen (box filter). Las imágenes
+// más pequeñas se devuelven sin escalar.
+func Thumbnail(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return src
+	}
+	tw, th := max, max
+	if w > h {
+		th = h * max / w
+	} else {
+		tw = w * max / h
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := b.Min.Y + y*h/th
+		y1 := b.Min.Y + (y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0 := b.Min.X + x*w/tw
+			x1 := b.Min.X + (x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// EncodeJPEG escribe la miniatura como JPEG sobre fondo blanco (JPEG no
+// admite transparencia).
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	flat := image.NewRGBA(b)
+	draw.Draw(flat, b, image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, b, img, b.Min, draw.Over)
+	return jpeg.Encode(w, flat, &jpeg.Options{Quality: 85})
+}
@@ -0,0 +1,205 @@
+// Package keyring gestiona las claves con las que se firman y verifican los
+// tokens de sesión (JWT).
+//
+// Las claves se cargan de un directorio: cada fichero .pem es una clave y su
+// nombre (sin extensión) es el kid que viaja en la cabecera del token. Se
+// admiten claves RSA (RS256, 2048 bits o más) y Ed25519 (EdDSA), privadas en
+// PKCS#8 o PKCS#1, o solo públicas (PKIX) para claves retiradas que aún deben
+// verificar tokens emitidos.
+//
+// Para rotar: añadir la clave nueva, activarla y reiniciar; la anterior se
+// mantiene en el directorio hasta que caduquen los tokens que firmó.
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSABits = 2048
+
+// Key es una clave de firma identificada por su kid. Las claves sin parte
+// privada solo sirven para verificar.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// Keyring reúne la clave activa, con la que se firma, y todas las que se
+// aceptan al verificar.
+type Keyring struct {
+	active *Key
+	keys   map[string]*Key
+	// Secreto HS256 para tokens sin kid: modo clásico de un solo secreto o
+	// tokens emitidos antes de pasar a claves asimétricas.
+	legacy []byte
+}
+
+// NewHMAC crea un llavero con un único secreto compartido (HS256, sin kid),
+// el funcionamiento anterior a las claves asimétricas.
+func NewHMAC(secret []byte) *Keyring {
+	return &Keyring{
+		active: &Key{Method: jwt.SigningMethodHS256, private: secret},
+		keys:   map[string]*Key{},
+		legacy: secret,
+	}
+}
+
+// LoadDir carga las claves del directorio. activeID elige la clave de firma;
+// si está vacío y solo hay una clave privada, se usa esa.
+func LoadDir(dir, activeID string) (*Keyring, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{keys: map[string]*Key{}}
+	var private []string
+	for _, p := range paths {
+		key, err := loadKey(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		k.keys[key.ID] = key
+		if key.private != nil {
+			private = append(private, key.ID)
+		}
+	}
+	if len(k.keys) == 0 {
+		return nil, fmt.Errorf("no signing keys found in %s", dir)
+	}
+
+	if activeID == "" {
+		if len(private) != 1 {
+			return nil, fmt.Errorf("%d private keys in %s; set the active key id", len(private), dir)
+		}
+		activeID = private[0]
+	}
+	active, ok := k.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found in %s", activeID, dir)
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+	k.active = active
+	return k, nil
+}
+
+func loadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("not a PEM file")
+	}
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.private, key.public = v, &v.PublicKey
+	case *rsa.PublicKey:
+		key.public = v
+	case ed25519.PrivateKey:
+		key.private, key.public = v, v.Public()
+	case ed25519.PublicKey:
+		key.public = v
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	switch pub := key.public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key must have at least %d bits", minRSABits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	}
+	return key, nil
+}
+
+// AcceptHMAC sigue aceptando tokens HS256 sin kid firmados con secret, para
+// no cerrar las sesiones abiertas al pasar a claves asimétricas.
+func (k *Keyring) AcceptHMAC(secret []byte) {
+	k.legacy = secret
+}
+
+// ActiveID devuelve el kid de la clave de firma ("" en modo HS256).
+func (k *Keyring) ActiveID() string {
+	return k.active.ID
+}
+
+// Sign firma los claims con la clave activa e incluye su kid.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.Method, claims)
+	if k.active.ID != "" {
+		token.Header["kid"] = k.active.ID
+	}
+	return token.SignedString(k.active.private)
+}
+
+// Keyfunc elige la clave de verificación según el kid del token y rechaza
+// algoritmos que no correspondan a esa clave.
+func (k *Keyring) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		if k.legacy == nil || t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, errors.New("token has no key id")
+		}
+		return k.legacy, nil
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("algorithm %s does not match key %q", t.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+// Methods lista los algoritmos aceptados, para jwt.WithValidMethods.
+func (k *Keyring) Methods() []string {
+	var out []string
+	if k.legacy != nil {
+		out = append(out, jwt.SigningMethodHS256.Alg())
+	}
+	for _, key := range k.keys {
+		if !slices.Contains(out, key.Method.Alg()) {
+			out = append(out, key.Method.Alg())
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Finalidades de los enlaces de cuenta enviados por correo.
+const (
+	TokenPasswordReset     = "password_reset"
+	TokenEmailVerification = "email_verification"
+)
+
+// AccountToken registra un enlace firmado de un solo uso enviado por correo.
+// Solo se guarda el ID (jti) del token; la firma impide fabricarlos.
+type AccountToken struct {
+	gorm.Model
+	TokenID   string `gorm:"uniqueIndex;size:64;not null"`
+	UserID    uint   `gorm:"index;not null"`
+	Purpose   string `gorm:"index;size:32;not null"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
@@ -4,8 +4,11 @@ import "gorm.io/gorm"
 
 type Alchemist struct {
 	gorm.Model
-	Name      string `gorm:"not null"`
-	Age       int    `gorm:"not null"`
-	Specialty string `gorm:"size:255"`
-	Rank      string `gorm:"size:100"`
+	Name       string `gorm:"not null"`
+	Age        int    `gorm:"not null"`
+	Specialty  string `gorm:"size:255"`
+	Rank       string `gorm:"size:100"`
+	DivisionID *uint  `gorm:"index"` // División a la que pertenece
+	// Horas semanales disponibles; 0 usa la capacidad por defecto de la configuración.
+	WeeklyCapacityHours float64
 }
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey es una clave para integraciones sin usuario (p. ej. instrumentos de
+// laboratorio). Solo se guarda el hash; Prefix permite reconocerla en listados.
+type APIKey struct {
+	gorm.Model
+	Name        string `gorm:"size:100;not null"`
+	Prefix      string `gorm:"size:16;index"`
+	KeyHash     string `gorm:"uniqueIndex;size:64;not null"`
+	Permissions []APIKeyPermission
+	ExpiresAt   *time.Time // nil = no caduca
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+	CreatedBy   string `gorm:"size:255"`
+}
+
+type APIKeyPermission struct {
+	APIKeyID   uint   `gorm:"primaryKey"`
+	Permission string `gorm:"primaryKey;size:64"`
+}
+
+// PermissionNames devuelve los permisos de la clave como lista de cadenas.
+func (k *APIKey) PermissionNames() []string {
+	out := make([]string, 0, len(k.Permissions))
+	for _, p := range k.Permissions {
+		out = append(out, p.Permission)
+	}
+	return out
+}
+
+// Active indica si la clave puede usarse en el instante dado.
+func (k *APIKey) Active(at time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || at.Before(*k.ExpiresAt))
+}
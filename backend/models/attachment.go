@@ -0,0 +1,23 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	AttachmentAlchemistPhoto = "alchemist_photo"
+	AttachmentMission        = "mission"
+	AttachmentTransmutation  = "transmutation"
+)
+
+// Attachment describe un fichero subido y dónde está guardado en el
+// almacén de blobs. Las imágenes guardan además una miniatura.
+type Attachment struct {
+	gorm.Model
+	Entity       string `gorm:"size:32;index:idx_attachment_entity;not null"` // "alchemist_photo" | "mission" | "transmutation"
+	EntityID     uint   `gorm:"index:idx_attachment_entity;not null"`
+	Filename     string `gorm:"not null"`
+	ContentType  string `gorm:"size:100;not null"`
+	Size         int64
+	StorageKey   string `gorm:"not null"`
+	ThumbnailKey string
+	UploadedBy   string
+}
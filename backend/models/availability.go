@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AvailabilityLeave           = "leave"
+	AvailabilityTraining        = "training"
+	AvailabilityFieldDeployment = "field_deployment"
+)
+
+// AvailabilityWindow es un periodo [StartsAt, EndsAt) en el que el
+// alquimista no está disponible para nuevas misiones ni transmutaciones.
+type AvailabilityWindow struct {
+	gorm.Model
+	AlchemistID uint      `gorm:"index;not null"`
+	Kind        string    `gorm:"size:32;not null"` // "leave" | "training" | "field_deployment"
+	StartsAt    time.Time `gorm:"index;not null"`
+	EndsAt      time.Time `gorm:"index;not null"`
+	Notes       string
+	CreatedBy   string
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarFeed guarda el token de suscripción del calendario de un alquimista y
+// la última versión generada del .ics.
+type CalendarFeed struct {
+	gorm.Model
+	AlchemistID uint   `gorm:"uniqueIndex;not null"`
+	TokenHash   string `gorm:"size:64;not null"`
+	Version     string `gorm:"size:64"`
+	Content     string `gorm:"type:text"`
+	GeneratedAt *time.Time
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CertificationKindLicense = "license"
+	CertificationKindGeneral = "certification"
+
+	CertificationActive  = "active"
+	CertificationExpired = "expired"
+	CertificationRevoked = "revoked"
+)
+
+// Certification es una licencia o certificación emitida a un alquimista.
+type Certification struct {
+	gorm.Model
+	AlchemistID      uint   `gorm:"index;not null"`
+	Kind             string `gorm:"size:32;not null"` // "license" | "certification"
+	Name             string `gorm:"not null"`
+	Number           string `gorm:"size:100"`
+	IssuingAuthority string `gorm:"not null"`
+	IssuedAt         time.Time
+	ExpiresAt        time.Time `gorm:"index"`
+	RevokedAt        *time.Time
+	RevokedBy        string
+	RevocationReason string
+}
+
+// Status devuelve el estado de la certificación en el instante indicado.
+func (c *Certification) Status(now time.Time) string {
+	switch {
+	case c.RevokedAt != nil:
+		return CertificationRevoked
+	case !now.Before(c.ExpiresAt):
+		return CertificationExpired
+	default:
+		return CertificationActive
+	}
+}
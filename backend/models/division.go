@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// Division agrupa alquimistas bajo un supervisor. ParentID forma la línea de
+// reporte: el supervisor de una división también responde de sus subdivisiones.
+type Division struct {
+	gorm.Model
+	Name         string `gorm:"uniqueIndex;size:100;not null"`
+	Description  string
+	ParentID     *uint `gorm:"index"`
+	SupervisorID *uint `gorm:"index"` // Usuario que dirige la división
+	Supervisor   *User `gorm:"foreignKey:SupervisorID"`
+}
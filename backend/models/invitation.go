@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invitation registra un código de invitación firmado. El código en sí no se
+// guarda: solo su identificador (jti), que permite comprobar que no se ha
+// usado ni revocado.
+type Invitation struct {
+	gorm.Model
+	CodeID    string `gorm:"uniqueIndex;size:64;not null"`
+	Role      string `gorm:"size:32;not null"`
+	Email     string `gorm:"size:255"` // Vacío = cualquier dirección
+	ExpiresAt time.Time
+	CreatedBy string `gorm:"size:255"`
+	UsedAt    *time.Time
+	UsedBy    string `gorm:"size:255"`
+}
@@ -1,12 +1,20 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Mission struct {
 	gorm.Model
 	Title       string
 	Description string
 	Difficulty  string
-	Status      string `gorm:"default:pendiente"`
-	AssignedTo  uint   // Alchemist ID
+	Status      string            `gorm:"default:pendiente"`
+	AssignedTo  uint              // Alchemist ID
+	DueDate     *time.Time        `gorm:"index"`
+	TemplateID  *uint             `gorm:"index"` // Plantilla de origen, si aplica
+	Tasks       []MissionTask     `gorm:"foreignKey:MissionID"`
+	Materials   []MissionMaterial `gorm:"foreignKey:MissionID"`
 }
@@ -0,0 +1,22 @@
+package models
+
+import "gorm.io/gorm"
+
+// MissionComment es un comentario de una misión. ParentID apunta al comentario
+// al que responde (nil para comentarios de primer nivel).
+type MissionComment struct {
+	gorm.Model
+	MissionID   uint  `gorm:"index;not null"`
+	ParentID    *uint `gorm:"index"`
+	AuthorEmail string
+	Body        string                  `gorm:"not null"`
+	Mentions    []MissionCommentMention `gorm:"foreignKey:CommentID"`
+}
+
+// MissionCommentMention registra a un usuario mencionado con @email.
+type MissionCommentMention struct {
+	gorm.Model
+	CommentID uint `gorm:"index;not null"`
+	UserID    uint `gorm:"index;not null"`
+	Email     string
+}
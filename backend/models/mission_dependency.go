@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// MissionDependency indica que MissionID no puede comenzar hasta que
+// DependsOnID esté completada (DependsOnID "bloquea" a MissionID).
+type MissionDependency struct {
+	gorm.Model
+	MissionID   uint `gorm:"index;not null"`
+	DependsOnID uint `gorm:"index;not null"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// MissionMaterial registra los materiales requeridos por una misión.
+type MissionMaterial struct {
+	gorm.Model
+	MissionID  uint `gorm:"index;not null"`
+	MaterialID uint `gorm:"not null"`
+	Quantity   float64
+}
@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// MissionStatusChange guarda cada transición de estado de una misión.
+type MissionStatusChange struct {
+	gorm.Model
+	MissionID  uint `gorm:"index;not null"`
+	FromStatus string
+	ToStatus   string
+	ChangedBy  string
+}
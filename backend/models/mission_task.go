@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+type MissionTask struct {
+	gorm.Model
+	MissionID   uint   `gorm:"index;not null"`
+	Title       string `gorm:"not null"`
+	Description string
+	AssignedTo  uint   // Alchemist ID
+	Status      string `gorm:"default:pendiente"`
+	Position    int    `gorm:"not null;default:0"`
+	Mandatory   bool   `gorm:"not null"`
+}
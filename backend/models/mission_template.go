@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MissionTemplate describe una misión que se repite. Si Recurrence está vacío
+// la plantilla solo se instancia manualmente.
+type MissionTemplate struct {
+	gorm.Model
+	Title       string `gorm:"not null"`
+	Description string
+	Difficulty  string
+	AssignedTo  uint   // Alchemist ID
+	Recurrence  string `gorm:"size:100"` // p. ej. "FREQ=MONTHLY;INTERVAL=1"
+	StartsAt    time.Time
+	NextRunAt   *time.Time `gorm:"index"`
+	LastRunAt   *time.Time
+	Active      bool                      `gorm:"not null"`
+	Materials   []MissionTemplateMaterial `gorm:"foreignKey:TemplateID"`
+	Tasks       []MissionTemplateTask     `gorm:"foreignKey:TemplateID"`
+}
+
+type MissionTemplateMaterial struct {
+	gorm.Model
+	TemplateID uint `gorm:"index;not null"`
+	MaterialID uint `gorm:"not null"`
+	Quantity   float64
+}
+
+type MissionTemplateTask struct {
+	gorm.Model
+	TemplateID  uint   `gorm:"index;not null"`
+	Title       string `gorm:"not null"`
+	Description string
+	AssignedTo  uint
+	Position    int  `gorm:"not null"`
+	Mandatory   bool `gorm:"not null"`
+}
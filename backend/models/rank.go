@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RankLevel es un peldaño de la escala de rangos junto con los méritos que
+// se sugieren para alcanzarlo.
+type RankLevel struct {
+	Name                        string
+	MinCompletedMissions        int
+	MinSuccessfulTransmutations int
+}
+
+// RankLadder es la escala oficial de rangos, de menor a mayor.
+var RankLadder = []RankLevel{
+	{Name: "aprendiz"},
+	{Name: "alquimista", MinCompletedMissions: 3, MinSuccessfulTransmutations: 5},
+	{Name: "alquimista_estatal", MinCompletedMissions: 10, MinSuccessfulTransmutations: 20},
+	{Name: "alquimista_mayor", MinCompletedMissions: 25, MinSuccessfulTransmutations: 50},
+	{Name: "gran_alquimista", MinCompletedMissions: 50, MinSuccessfulTransmutations: 100},
+}
+
+// RankIndex devuelve la posición del rango en la escala o -1 si no figura.
+func RankIndex(name string) int {
+	for i, r := range RankLadder {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// NextRank devuelve el peldaño siguiente a current; los rangos fuera de la
+// escala (p. ej. vacíos o heredados) ascienden al primer peldaño.
+func NextRank(current string) (RankLevel, bool) {
+	i := RankIndex(current) + 1
+	if i >= len(RankLadder) {
+		return RankLevel{}, false
+	}
+	return RankLadder[i], true
+}
+
+const (
+	PromotionPending  = "pendiente"
+	PromotionApproved = "aprobada"
+	PromotionRejected = "rechazada"
+)
+
+// PromotionRequest es una solicitud de ascenso pendiente de revisión. Guarda
+// los méritos del alquimista en el momento de la solicitud.
+type PromotionRequest struct {
+	gorm.Model
+	AlchemistID              uint   `gorm:"index;not null"`
+	FromRank                 string `gorm:"size:100"`
+	ToRank                   string `gorm:"size:100;not null"`
+	Justification            string `gorm:"type:text;not null"`
+	RequestedBy              string `gorm:"not null"`
+	Status                   string `gorm:"size:32;index;default:pendiente"`
+	CompletedMissions        int
+	SuccessfulTransmutations int
+	Eligible                 bool
+	ReviewedBy               string
+	ReviewedAt               *time.Time
+	ReviewComment            string
+}
+
+// RankChange es una entrada del historial de rangos de un alquimista.
+type RankChange struct {
+	gorm.Model
+	AlchemistID        uint   `gorm:"index;not null"`
+	FromRank           string `gorm:"size:100"`
+	ToRank             string `gorm:"size:100;not null"`
+	PromotionRequestID *uint
+	ChangedBy          string
+	Reason             string
+}
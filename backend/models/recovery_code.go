@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode es un código de recuperación del doble factor. Sirve una sola
+// vez y solo se guarda su hash.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"uniqueIndex;size:64;not null"`
+	UsedAt   *time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken es un token de refresco emitido en un login. Solo se guarda su
+// hash. Cada rotación crea un token nuevo de la misma familia y marca el
+// anterior como usado; presentar un token ya usado revoca toda la familia.
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint   `gorm:"index;not null"`
+	TokenHash string `gorm:"uniqueIndex;size:64;not null"`
+	FamilyID  string `gorm:"index;size:64;not null"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time // Rotado
+	RevokedAt *time.Time // Logout o reutilización detectada
+	// Token de acceso emitido junto a este, para revocarlo con la familia.
+	AccessJTI       string `gorm:"size:64"`
+	AccessExpiresAt time.Time
+}
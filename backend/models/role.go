@@ -0,0 +1,134 @@
+package models
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// Permisos que pueden declarar las rutas protegidas. Los roles agrupan
+// permisos y se guardan en base de datos.
+const (
+	PermAlchemistWrite      = "alchemist:write"
+	PermAvailabilityWrite   = "availability:write"
+	PermCertificationRead   = "certification:read"
+	PermCertificationWrite  = "certification:write"
+	PermMissionWrite        = "mission:write"
+	PermTaskWrite           = "task:write"
+	PermTaskUpdate          = "task:update"
+	PermCommentWrite        = "comment:write"
+	PermTemplateWrite       = "template:write"
+	PermTransmutationCreate = "transmutation:create"
+	PermTransmutationAny    = "transmutation:create_any" // Registrar a nombre de cualquier alquimista
+	PermTransmutationWrite  = "transmutation:write"
+	PermMaterialWrite       = "material:write"
+	PermAuditRead           = "audit:read"
+	PermAuditWrite          = "audit:write"
+	PermSkillWrite          = "skill:write"
+	PermSkillLevel          = "skill:level"
+	PermPromotionRequest    = "promotion:request"
+	PermPromotionReview     = "promotion:review"
+	PermAttachmentRead      = "attachment:read"
+	PermAttachmentWrite     = "attachment:write"
+	PermDivisionWrite       = "division:write"
+	PermDivisionAll         = "division:all" // Actuar sobre todas las divisiones, sin alcance limitado
+	PermPlanningRead        = "planning:read"
+	PermStatsRead           = "stats:read"
+	PermInvitationManage    = "invitation:manage"
+	PermRoleManage          = "role:manage"
+	PermAPIKeyManage        = "apikey:manage"
+	PermUserManage          = "user:manage"
+)
+
+// Permissions es el catálogo completo, en el orden en que se listan.
+var Permissions = []string{
+	PermAlchemistWrite,
+	PermAvailabilityWrite,
+	PermCertificationRead,
+	PermCertificationWrite,
+	PermMissionWrite,
+	PermTaskWrite,
+	PermTaskUpdate,
+	PermCommentWrite,
+	PermTemplateWrite,
+	PermTransmutationCreate,
+	PermTransmutationAny,
+	PermTransmutationWrite,
+	PermMaterialWrite,
+	PermAuditRead,
+	PermAuditWrite,
+	PermSkillWrite,
+	PermSkillLevel,
+	PermPromotionRequest,
+	PermPromotionReview,
+	PermAttachmentRead,
+	PermAttachmentWrite,
+	PermDivisionWrite,
+	PermDivisionAll,
+	PermPlanningRead,
+	PermStatsRead,
+	PermInvitationManage,
+	PermRoleManage,
+	PermAPIKeyManage,
+	PermUserManage,
+}
+
+// IsPermission indica si p pertenece al catálogo.
+func IsPermission(p string) bool {
+	for _, known := range Permissions {
+		if known == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Role es un rol asignable a usuarios (User.Role guarda su nombre). Los roles
+// de sistema se crean al arrancar y no se pueden eliminar.
+type Role struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex;size:32;not null"`
+	Description string `gorm:"size:255"`
+	System      bool
+	Permissions []RolePermission
+}
+
+// KnownPermission registra los permisos del catálogo ya vistos, para conceder
+// solo los nuevos a los roles de sistema al actualizar.
+type KnownPermission struct {
+	Name string `gorm:"primaryKey;size:64"`
+}
+
+type RolePermission struct {
+	RoleID     uint   `gorm:"primaryKey"`
+	Permission string `gorm:"primaryKey;size:64"`
+}
+
+// PermissionNames devuelve los permisos del rol como lista de cadenas.
+func (r *Role) PermissionNames() []string {
+	out := make([]string, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		out = append(out, p.Permission)
+	}
+	return out
+}
+
+// DefaultRoles son los roles de sistema con sus permisos iniciales. alchemist
+// y supervisor equivalen a los dos roles fijos que existían antes del modelo
+// de permisos; el supervisor queda limitado a las divisiones que dirige y solo
+// admin actúa sobre toda la organización.
+var DefaultRoles = map[string][]string{
+	"alchemist": {
+		PermAvailabilityWrite,
+		PermCertificationRead,
+		PermTaskUpdate,
+		PermCommentWrite,
+		PermTransmutationCreate,
+		PermSkillLevel,
+		PermPromotionRequest,
+		PermAttachmentRead,
+		PermAttachmentWrite,
+	},
+	"supervisor": slices.DeleteFunc(slices.Clone(Permissions), func(p string) bool { return p == PermDivisionAll }),
+	"admin":      Permissions,
+}
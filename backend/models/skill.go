@@ -0,0 +1,52 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	SkillMinLevel = 1
+	SkillMaxLevel = 5
+
+	SkillRequirementMission  = "mission"
+	SkillRequirementMaterial = "material"
+)
+
+// Skill es una entrada del catálogo de habilidades (p. ej. "metallurgy").
+// El nombre se guarda normalizado en minúsculas.
+type Skill struct {
+	gorm.Model
+	Name        string `gorm:"size:100;uniqueIndex;not null"`
+	Description string
+}
+
+// AlchemistSkill es el nivel de dominio (1–5) de un alquimista en una
+// habilidad del catálogo.
+type AlchemistSkill struct {
+	gorm.Model
+	AlchemistID  uint `gorm:"uniqueIndex:idx_alchemist_skill;not null"`
+	SkillID      uint `gorm:"uniqueIndex:idx_alchemist_skill;not null"`
+	Level        int  `gorm:"not null"`
+	UpdatedBy    string
+	Skill        Skill              `gorm:"foreignKey:SkillID"`
+	Endorsements []SkillEndorsement `gorm:"foreignKey:AlchemistSkillID"`
+}
+
+// SkillEndorsement registra que un supervisor respalda el nivel declarado.
+// Cambiar el nivel invalida los respaldos anteriores.
+type SkillEndorsement struct {
+	gorm.Model
+	AlchemistSkillID uint   `gorm:"index;not null"`
+	EndorsedBy       string `gorm:"not null"`
+	Level            int    `gorm:"not null"` // Nivel respaldado
+	Comment          string
+}
+
+// SkillRequirement exige un nivel mínimo de una habilidad para una misión o
+// para transmutar un material.
+type SkillRequirement struct {
+	gorm.Model
+	Entity   string `gorm:"size:32;index:idx_skill_requirement_entity;not null"` // "mission" | "material"
+	EntityID uint   `gorm:"index:idx_skill_requirement_entity;not null"`
+	SkillID  uint   `gorm:"not null"`
+	MinLevel int    `gorm:"not null"`
+	Skill    Skill  `gorm:"foreignKey:SkillID"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Transmutation struct {
 	gorm.Model
@@ -9,4 +13,6 @@ type Transmutation struct {
 	Formula     string
 	Status      string `gorm:"default:en_proceso"`
 	Result      string
+	ScheduledAt *time.Time `gorm:"index"`
+	CompletedAt *time.Time // Momento en que terminó el procesamiento
 }
@@ -1,10 +1,45 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	gorm.Model
 	Email        string `gorm:"uniqueIndex;size:255;not null"`
 	PasswordHash string `gorm:"size:255;not null"`
-	Role         string `gorm:"size:32;not null"` // "alchemist" | "supervisor"
+	Role         string `gorm:"size:32;not null"` // Nombre del rol: "alchemist", "supervisor", "admin" u otro creado
+	AlchemistID  *uint  `gorm:"uniqueIndex"`      // Perfil de alquimista vinculado
+	// Momento en que se confirmó el email; nil mientras no se verifique.
+	EmailVerifiedAt *time.Time
+	// Doble factor (TOTP). El secreto existe desde el alta pero solo se exige
+	// una vez confirmado (TOTPEnabledAt). TOTPLastStep impide reutilizar un
+	// código ya aceptado.
+	TOTPSecret    string `gorm:"size:64"`
+	TOTPEnabledAt *time.Time
+	TOTPLastStep  int64
+	// Cuentas desactivadas por un supervisor: no pueden iniciar sesión y sus
+	// tokens se rechazan.
+	DisabledAt *time.Time
+	// Un supervisor exigió cambiar la contraseña; la actual deja de servir
+	// para entrar hasta completar el restablecimiento.
+	PasswordResetRequired bool
+	// Identificador (sub) en el proveedor OpenID Connect si la cuenta se
+	// creó o vinculó mediante SSO.
+	OIDCSubject *string `gorm:"column:oidc_subject;uniqueIndex;size:255"`
+	// Identidad del proveedor que coincide por email con esta cuenta y espera
+	// a que un supervisor confirme la vinculación.
+	OIDCPendingSubject *string `gorm:"column:oidc_pending_subject;size:255"`
+}
+
+// TwoFactorEnabled indica si el login exige un código TOTP.
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// Disabled indica si la cuenta está desactivada.
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
 }
@@ -0,0 +1,229 @@
+// Package oidc implementa la parte cliente del login con OpenID Connect:
+// flujo authorization code con PKCE (S256) contra un proveedor externo.
+//
+// La configuración del proveedor se descubre en
+// {issuer}/.well-known/openid-configuration la primera vez que se necesita,
+// así que el servidor arranca aunque el proveedor no esté disponible.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	httpTimeout = 10 * time.Second
+	// Tamaño máximo aceptado en las respuestas del proveedor.
+	maxResponseBytes = 1 << 20
+)
+
+// Config identifica al cliente ante el proveedor.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // Vacío = cliente público (solo PKCE)
+	RedirectURL  string
+	Scopes       []string // Vacío = openid, email y profile
+	// AuthURL sustituye al authorization_endpoint descubierto, para cuando el
+	// navegador llega al proveedor por otra dirección que el servidor (p. ej.
+	// localhost frente al nombre del contenedor).
+	AuthURL string
+}
+
+// metadata son los campos del documento de descubrimiento que se usan.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider es un proveedor OpenID Connect ya configurado. Es seguro usarlo
+// desde varias goroutines.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu   sync.Mutex
+	meta *metadata
+	keys *keySet
+}
+
+// New crea el proveedor; no contacta con él hasta el primer uso.
+func New(cfg Config) *Provider {
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{cfg: cfg, client: &http.Client{Timeout: httpTimeout}}
+}
+
+// RedirectURL es la dirección de vuelta registrada en el proveedor.
+func (p *Provider) RedirectURL() string {
+	return p.cfg.RedirectURL
+}
+
+// discover descarga (una vez) el documento de descubrimiento y comprueba que
+// corresponde al issuer configurado.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var m metadata
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", &m); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(m.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", m.Issuer, p.cfg.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+	p.meta = &m
+	p.keys = newKeySet(p.getJSON, m.JWKSURI)
+	return p.meta, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v)
+}
+
+// AuthCodeURL devuelve la dirección del proveedor a la que se redirige al
+// usuario. state y nonce enlazan la vuelta con esta petición y challenge es
+// el reto PKCE del verificador guardado.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	endpoint := m.AuthorizationEndpoint
+	if p.cfg.AuthURL != "" {
+		endpoint = p.cfg.AuthURL
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// tokenResponse es la respuesta del token endpoint (RFC 6749 §5).
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange canjea el código de autorización por tokens y devuelve el ID
+// token ya verificado contra el nonce de la petición.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		// client_secret_basic: usuario y contraseña van codificados como
+		// formulario antes de la codificación Basic (RFC 6749 §2.3.1).
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var tr tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("oidc token endpoint: %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		if tr.Error == "" {
+			return nil, fmt.Errorf("oidc token endpoint: %s", resp.Status)
+		}
+		return nil, &Error{Code: tr.Error, Description: tr.ErrorDescription}
+	}
+	if tr.IDToken == "" {
+		return nil, errors.New("oidc token endpoint: response has no id_token")
+	}
+	return p.verify(ctx, m, tr.IDToken, nonce)
+}
+
+// Error es un error devuelto por el proveedor, en la redirección de vuelta o
+// en el token endpoint.
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return "oidc provider error: " + e.Code
+	}
+	return fmt.Sprintf("oidc provider error: %s: %s", e.Code, e.Description)
+}
+
+// RandomString devuelve n bytes aleatorios en base64url, apto para state,
+// nonce y verificadores PKCE (con n = 32 salen 43 caracteres).
+func RandomString(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Challenge calcula el reto PKCE S256 de un verificador (RFC 7636 §4.2).
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
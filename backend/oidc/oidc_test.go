@@ -0,0 +1,181 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestChallengeRFC7636(t *testing.T) {
+	// Ejemplo del apéndice B del RFC 7636.
+	got := Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("Challenge = %s, want %s", got, want)
+	}
+}
+
+// fakeIdP es un proveedor mínimo: descubrimiento, JWKS y un token endpoint
+// que solo canjea el código si el verificador PKCE corresponde al reto.
+type fakeIdP struct {
+	srv       *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	// claims del ID token; issuer, audiencia y fechas se rellenan si faltan.
+	claims jwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.srv.URL,
+			"authorization_endpoint": f.srv.URL + "/authorize",
+			"token_endpoint":         f.srv.URL + "/token",
+			"jwks_uri":               f.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "good-code" || Challenge(r.PostForm.Get("code_verifier")) != f.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": f.sign(t)})
+	})
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeIdP) sign(t *testing.T) string {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": f.srv.URL,
+		"aud": "client",
+		"sub": "user-1",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+	}
+	for k, v := range f.claims {
+		claims[k] = v
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = "k1"
+	signed, err := tok.SignedString(f.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	f := newFakeIdP(t)
+	p := New(Config{Issuer: f.srv.URL, ClientID: "client", RedirectURL: "http://app/callback"})
+	raw, err := p.AuthCodeURL(context.Background(), "st", "no", "ch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"redirect_uri":          "http://app/callback",
+		"state":                 "st",
+		"nonce":                 "no",
+		"code_challenge":        "ch",
+		"code_challenge_method": "S256",
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, q.Get(k), v)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	cases := []struct {
+		name     string
+		code     string
+		verifier string
+		nonce    string
+		claims   jwt.MapClaims
+		wantErr  error
+	}{
+		{"válido", "good-code", verifier, "n1", jwt.MapClaims{"nonce": "n1"}, nil},
+		{"nonce distinto", "good-code", verifier, "n1", jwt.MapClaims{"nonce": "n2"}, ErrInvalidIDToken},
+		{"sin nonce", "good-code", verifier, "n1", nil, ErrInvalidIDToken},
+		{"otro emisor", "good-code", verifier, "n1", jwt.MapClaims{"nonce": "n1", "iss": "https://evil.example"}, ErrInvalidIDToken},
+		{"otra audiencia", "good-code", verifier, "n1", jwt.MapClaims{"nonce": "n1", "aud": "other"}, ErrInvalidIDToken},
+		{"caducado", "good-code", verifier, "n1", jwt.MapClaims{"nonce": "n1", "exp": time.Now().Add(-time.Hour).Unix()}, ErrInvalidIDToken},
+		{"varias audiencias sin azp", "good-code", verifier, "n1", jwt.MapClaims{"nonce": "n1", "aud": []string{"client", "other"}}, ErrInvalidIDToken},
+		{"sin sub", "good-code", verifier, "n1", jwt.MapClaims{"nonce": "n1", "sub": ""}, ErrInvalidIDToken},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newFakeIdP(t)
+			f.challenge = Challenge(verifier)
+			f.claims = c.claims
+			p := New(Config{Issuer: f.srv.URL, ClientID: "client", RedirectURL: "http://app/callback"})
+			claims, err := p.Exchange(context.Background(), c.code, c.verifier, c.nonce)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Exchange error = %v, want %v", err, c.wantErr)
+			}
+			if err == nil && claims.Subject() != "user-1" {
+				t.Errorf("subject = %q", claims.Subject())
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsWrongPKCEVerifier(t *testing.T) {
+	f := newFakeIdP(t)
+	f.challenge = Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	f.claims = jwt.MapClaims{"nonce": "n1"}
+	p := New(Config{Issuer: f.srv.URL, ClientID: "client", RedirectURL: "http://app/callback"})
+	_, err := p.Exchange(context.Background(), "good-code", "another-verifier", "n1")
+	var providerErr *Error
+	if !errors.As(err, &providerErr) || providerErr.Code != "invalid_grant" {
+		t.Fatalf("Exchange error = %v, want invalid_grant", err)
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	f := newFakeIdP(t)
+	p := New(Config{Issuer: f.srv.URL + "/other", ClientID: "client"})
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "c"); err == nil {
+		t.Fatal("AuthCodeURL accepted metadata for another issuer")
+	}
+}
@@ -0,0 +1,276 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// Margen para diferencias de reloj con el proveedor.
+	clockSkew = time.Minute
+	// Intervalo mínimo entre descargas del JWKS al ver un kid desconocido.
+	jwksRefreshInterval = time.Minute
+)
+
+// Algoritmos de firma aceptados en los ID tokens.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}
+
+// ErrInvalidIDToken envuelve los motivos por los que se rechaza un ID token.
+var ErrInvalidIDToken = errors.New("oidc: invalid id_token")
+
+var errUnknownKey = errors.New("signed with an unknown key")
+
+// Claims son las claims del ID token.
+type Claims map[string]any
+
+// String devuelve una claim de texto ("" si falta o es de otro tipo).
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Subject es el identificador estable del usuario en el proveedor.
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Email devuelve la dirección de correo que declara el proveedor.
+func (c Claims) Email() string {
+	return c.String("email")
+}
+
+// EmailVerified indica si el proveedor asegura que el email es del usuario.
+// Algunos proveedores lo envían como texto.
+func (c Claims) EmailVerified() bool {
+	switch v := c["email_verified"].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// Strings devuelve una claim que puede ser texto o lista de textos (p. ej.
+// groups o roles).
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// verify comprueba firma, emisor, audiencia, vigencia y nonce del ID token
+// (OpenID Connect Core §3.1.3.7).
+func (p *Provider) verify(ctx context.Context, m *metadata, raw, nonce string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.get(ctx, kid, t.Method.Alg())
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(m.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	c := Claims(claims)
+	// Con varias audiencias, azp debe ser este cliente.
+	if aud, _ := claims.GetAudience(); len(aud) > 1 && c.String("azp") != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: azp does not match client", ErrInvalidIDToken)
+	}
+	if subtle.ConstantTimeCompare([]byte(c.String("nonce")), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if c.Subject() == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+	return c, nil
+}
+
+// keySet guarda las claves públicas del proveedor y las vuelve a descargar
+// cuando aparece un kid nuevo (rotación), como mucho una vez por intervalo.
+type keySet struct {
+	fetch   func(ctx context.Context, url string, v any) error
+	url     string
+	mu      sync.Mutex
+	keys    map[string]publicKey
+	fetched time.Time
+}
+
+type publicKey struct {
+	alg string // Vacío si el JWK no lo fija
+	key crypto.PublicKey
+}
+
+func newKeySet(fetch func(context.Context, string, any) error, url string) *keySet {
+	return &keySet{fetch: fetch, url: url}
+}
+
+// get busca la clave del kid (o la única, si el token no trae kid) y
+// comprueba que su tipo corresponde al algoritmo del token.
+func (s *keySet) get(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.lookup(kid)
+	if !ok && time.Since(s.fetched) >= jwksRefreshInterval {
+		if err := s.refresh(ctx); err != nil {
+			return nil, err
+		}
+		k, ok = s.lookup(kid)
+	}
+	if !ok {
+		return nil, errUnknownKey
+	}
+	if k.alg != "" && k.alg != alg || !keyMatches(k.key, alg) {
+		return nil, fmt.Errorf("key %q cannot verify %s", kid, alg)
+	}
+	return k.key, nil
+}
+
+func (s *keySet) lookup(kid string) (publicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+func (s *keySet) refresh(ctx context.Context) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := s.fetch(ctx, s.url, &set); err != nil {
+		return fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := map[string]publicKey{}
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		key, err := j.publicKey()
+		if err != nil {
+			// Tipos de clave que no usamos no impiden cargar el resto.
+			continue
+		}
+		keys[j.Kid] = publicKey{alg: j.Alg, key: key}
+	}
+	s.keys = keys
+	s.fetched = time.Now()
+	return nil
+}
+
+func keyMatches(key crypto.PublicKey, alg string) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return slices.Contains([]string{"RS256", "RS384", "RS512", "PS256"}, alg)
+	case *ecdsa.PublicKey:
+		return alg == "ES256" && k.Curve == elliptic.P256() || alg == "ES384" && k.Curve == elliptic.P384()
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+// jwk es una clave pública en formato JWK (RFC 7517/7518/8037).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("RSA key too short")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decodeInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid JWK integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
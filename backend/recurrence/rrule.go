@@ -0,0 +1,118 @@
+// Package recurrence implementa un subconjunto de las reglas RRULE (RFC 5545)
+// suficiente para programar misiones periódicas: FREQ=DAILY|WEEKLY|MONTHLY con
+// INTERVAL opcional.
+package recurrence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Rule describe cada cuánto se repite un evento a partir de una fecha ancla.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+}
+
+// Parse interpreta cadenas como "FREQ=WEEKLY;INTERVAL=2" (el prefijo "RRULE:"
+// es opcional).
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("empty recurrence rule")
+	}
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "FREQ":
+			freq := Frequency(strings.ToUpper(strings.TrimSpace(value)))
+			switch freq {
+			case Daily, Weekly, Monthly:
+				rule.Freq = freq
+			default:
+				return nil, fmt.Errorf("unsupported recurrence frequency %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid recurrence interval %q", value)
+			}
+			rule.Interval = n
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %q", key)
+		}
+	}
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("recurrence rule requires FREQ")
+	}
+	return rule, nil
+}
+
+// String devuelve la regla en formato RRULE normalizado.
+func (r *Rule) String() string {
+	if r.Interval > 1 {
+		return fmt.Sprintf("FREQ=%s;INTERVAL=%d", r.Freq, r.Interval)
+	}
+	return "FREQ=" + string(r.Freq)
+}
+
+// occurrence calcula la k-ésima repetición a partir del ancla. En reglas
+// mensuales el día se ajusta al último día del mes cuando no existe (31 → 30).
+func (r *Rule) occurrence(anchor time.Time, k int) time.Time {
+	switch r.Freq {
+	case Daily:
+		return anchor.AddDate(0, 0, k*r.Interval)
+	case Weekly:
+		return anchor.AddDate(0, 0, 7*k*r.Interval)
+	default:
+		y, m, d := anchor.Date()
+		first := time.Date(y, m+time.Month(k*r.Interval), 1,
+			anchor.Hour(), anchor.Minute(), anchor.Second(), anchor.Nanosecond(), anchor.Location())
+		if last := first.AddDate(0, 1, -1).Day(); d > last {
+			d = last
+		}
+		return first.AddDate(0, 0, d-1)
+	}
+}
+
+// After devuelve la primera repetición estrictamente posterior a t. El ancla
+// cuenta como la primera repetición.
+func (r *Rule) After(anchor, t time.Time) time.Time {
+	if t.Before(anchor) {
+		return anchor
+	}
+	// Estimación inicial para no iterar desde el ancla en reglas antiguas.
+	k := 0
+	switch r.Freq {
+	case Daily:
+		k = int(t.Sub(anchor).Hours()/24) / r.Interval
+	case Weekly:
+		k = int(t.Sub(anchor).Hours()/(24*7)) / r.Interval
+	default:
+		months := (t.Year()-anchor.Year())*12 + int(t.Month()) - int(anchor.Month())
+		k = months / r.Interval
+	}
+	if k > 0 {
+		k--
+	}
+	for {
+		if next := r.occurrence(anchor, k); next.After(t) {
+			return next
+		}
+		k++
+	}
+}
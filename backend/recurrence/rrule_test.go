@@ -0,0 +1,113 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string // String() normalizado; "" si debe fallar
+		wantErr bool
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY", false},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2", "FREQ=WEEKLY;INTERVAL=2", false},
+		{" freq=monthly ; interval=1 ", "FREQ=MONTHLY", false},
+		{"INTERVAL=3;FREQ=DAILY", "FREQ=DAILY;INTERVAL=3", false},
+		{"", "", true},
+		{"RRULE:", "", true},
+		{"FREQ=YEARLY", "", true},
+		{"FREQ=DAILY;INTERVAL=0", "", true},
+		{"FREQ=DAILY;INTERVAL=-1", "", true},
+		{"FREQ=DAILY;INTERVAL=x", "", true},
+		{"FREQ=DAILY;BYDAY=MO", "", true},
+		{"INTERVAL=2", "", true},
+		{"FREQ", "", true},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want error", tt.in, rule)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAfter(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name   string
+		rule   string
+		anchor string
+		t      string
+		want   string
+	}{
+		{"antes del ancla", "FREQ=DAILY", "2026-03-10T09:00:00Z", "2026-03-01T00:00:00Z", "2026-03-10T09:00:00Z"},
+		{"justo en el ancla", "FREQ=DAILY", "2026-03-10T09:00:00Z", "2026-03-10T09:00:00Z", "2026-03-11T09:00:00Z"},
+		{"diaria", "FREQ=DAILY", "2026-03-10T09:00:00Z", "2026-03-12T10:00:00Z", "2026-03-13T09:00:00Z"},
+		{"cada tres días", "FREQ=DAILY;INTERVAL=3", "2026-03-10T09:00:00Z", "2026-03-12T10:00:00Z", "2026-03-13T09:00:00Z"},
+		{"semanal", "FREQ=WEEKLY", "2026-03-02T08:00:00Z", "2026-03-09T08:00:00Z", "2026-03-16T08:00:00Z"},
+		{"quincenal", "FREQ=WEEKLY;INTERVAL=2", "2026-03-02T08:00:00Z", "2026-03-03T00:00:00Z", "2026-03-16T08:00:00Z"},
+		{"mensual", "FREQ=MONTHLY", "2026-01-15T12:00:00Z", "2026-04-20T00:00:00Z", "2026-05-15T12:00:00Z"},
+		{"mensual día 31 en febrero", "FREQ=MONTHLY", "2026-01-31T10:00:00Z", "2026-02-01T00:00:00Z", "2026-02-28T10:00:00Z"},
+		{"mensual día 31 vuelve al 31", "FREQ=MONTHLY", "2026-01-31T10:00:00Z", "2026-02-28T10:00:00Z", "2026-03-31T10:00:00Z"},
+		{"mensual en año bisiesto", "FREQ=MONTHLY", "2028-01-31T10:00:00Z", "2028-02-01T00:00:00Z", "2028-02-29T10:00:00Z"},
+		{"trimestral cruzando el año", "FREQ=MONTHLY;INTERVAL=3", "2026-11-30T10:00:00Z", "2026-12-01T00:00:00Z", "2027-02-28T10:00:00Z"},
+		{"ancla antigua", "FREQ=DAILY", "2000-01-01T06:00:00Z", "2026-10-19T07:00:00Z", "2026-10-20T06:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rule.After(at(tt.anchor), at(tt.t)); !got.Equal(at(tt.want)) {
+				t.Errorf("After = %s, want %s", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+// After siempre devuelve una repetición real y estrictamente posterior.
+func TestAfterIsNextOccurrence(t *testing.T) {
+	anchor := time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC)
+	for _, s := range []string{"FREQ=DAILY;INTERVAL=5", "FREQ=WEEKLY;INTERVAL=3", "FREQ=MONTHLY;INTERVAL=2"} {
+		rule, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for h := 0; h < 24*400; h += 7 {
+			now := anchor.Add(time.Duration(h) * time.Hour)
+			next := rule.After(anchor, now)
+			if !next.After(now) {
+				t.Fatalf("%s: After(%s) = %s is not after", s, now, next)
+			}
+			// No hay otra repetición entre now y next.
+			for k := 0; ; k++ {
+				occ := rule.occurrence(anchor, k)
+				if occ.After(now) {
+					if !occ.Equal(next) {
+						t.Fatalf("%s: After(%s) = %s, first occurrence after is %s", s, now, next, occ)
+					}
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AccountTokenRepository struct{ db *gorm.DB }
+
+func NewAccountTokenRepository(db *gorm.DB) *AccountTokenRepository {
+	return &AccountTokenRepository{db: db}
+}
+
+func (r *AccountTokenRepository) FindByTokenID(tokenID string) (*models.AccountToken, error) {
+	var t models.AccountToken
+	err := r.db.Where("token_id = ?", tokenID).First(&t).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *AccountTokenRepository) Save(t *models.AccountToken) (*models.AccountToken, error) {
+	return t, r.db.Save(t).Error
+}
+
+// Claim marca el token como usado si seguía pendiente. Devuelve false si otra
+// petición lo usó antes.
+func (r *AccountTokenRepository) Claim(id uint, at time.Time) (bool, error) {
+	res := r.db.Model(&models.AccountToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	return res.RowsAffected == 1, res.Error
+}
+
+// ConsumeAll invalida los tokens pendientes del usuario con esa finalidad,
+// p. ej. los demás enlaces de restablecimiento tras cambiar la contraseña.
+func (r *AccountTokenRepository) ConsumeAll(userID uint, purpose string, at time.Time) error {
+	return r.db.Model(&models.AccountToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", at).Error
+}
@@ -39,3 +39,24 @@ func (r *AlchemistRepository) Save(a *models.Alchemist) (*models.Alchemist, erro
 func (r *AlchemistRepository) Delete(a *models.Alchemist) error {
 	return r.db.Delete(a).Error
 }
+
+// FindBySkill devuelve los alquimistas con al menos minLevel en la habilidad
+// indicada (por nombre normalizado).
+func (r *AlchemistRepository) FindBySkill(skill string, minLevel int) ([]*models.Alchemist, error) {
+	var xs []*models.Alchemist
+	err := r.db.
+		Joins("JOIN alchemist_skills ON alchemist_skills.alchemist_id = alchemists.id AND alchemist_skills.deleted_at IS NULL").
+		Joins("JOIN skills ON skills.id = alchemist_skills.skill_id AND skills.deleted_at IS NULL").
+		Where("skills.name = ? AND alchemist_skills.level >= ?", skill, minLevel).
+		Order("alchemist_skills.level DESC, alchemists.id ASC").
+		Find(&xs).Error
+	return xs, err
+}
+
+func (r *AlchemistRepository) FindByIds(ids []uint) ([]*models.Alchemist, error) {
+	var xs []*models.Alchemist
+	if len(ids) == 0 {
+		return xs, nil
+	}
+	return xs, r.db.Where("id IN ?", ids).Order("id ASC").Find(&xs).Error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository struct{ db *gorm.DB }
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) FindAll() ([]*models.APIKey, error) {
+	var xs []*models.APIKey
+	return xs, r.db.Preload("Permissions").Order("created_at desc").Find(&xs).Error
+}
+
+func (r *APIKeyRepository) FindById(id int) (*models.APIKey, error) {
+	var k models.APIKey
+	err := r.db.Preload("Permissions").First(&k, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *APIKeyRepository) FindByHash(hash string) (*models.APIKey, error) {
+	var k models.APIKey
+	err := r.db.Preload("Permissions").Where("key_hash = ?", hash).First(&k).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// Create guarda la clave junto con sus permisos.
+func (r *APIKeyRepository) Create(k *models.APIKey) (*models.APIKey, error) {
+	return k, r.db.Create(k).Error
+}
+
+func (r *APIKeyRepository) Revoke(id uint, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).Update("revoked_at", at).Error
+}
+
+func (r *APIKeyRepository) TouchLastUsed(id uint, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+
+	"gorm.io/gorm"
+)
+
+type AttachmentRepository struct{ db *gorm.DB }
+
+func NewAttachmentRepository(db *gorm.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+func (r *AttachmentRepository) Save(a *models.Attachment) (*models.Attachment, error) {
+	return a, r.db.Save(a).Error
+}
+
+func (r *AttachmentRepository) FindByEntity(entity string, entityID uint) ([]*models.Attachment, error) {
+	var xs []*models.Attachment
+	err := r.db.Where("entity = ? AND entity_id = ?", entity, entityID).Order("created_at ASC, id ASC").Find(&xs).Error
+	return xs, err
+}
+
+func (r *AttachmentRepository) FindById(entity string, entityID uint, id int) (*models.Attachment, error) {
+	var a models.Attachment
+	err := r.db.Where("entity = ? AND entity_id = ?", entity, entityID).First(&a, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Delete borra el registro definitivamente: el blob ya no existe, así que no
+// tiene sentido conservarlo con borrado lógico.
+func (r *AttachmentRepository) Delete(a *models.Attachment) error {
+	return r.db.Unscoped().Delete(a).Error
+}
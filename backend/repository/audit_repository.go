@@ -37,3 +37,14 @@ func (r *AuditRepository) FindById(id int) (*models.Audit, error) {
 func (r *AuditRepository) Delete(a *models.Audit) error {
 	return r.db.Delete(a).Error
 }
+
+// FindByEntity devuelve las auditorías de las entidades indicadas en orden
+// cronológico.
+func (r *AuditRepository) FindByEntity(entity string, ids ...uint) ([]*models.Audit, error) {
+	var audits []*models.Audit
+	if len(ids) == 0 {
+		return audits, nil
+	}
+	err := r.db.Where("entity = ? AND entity_id IN ?", entity, ids).Order("created_at ASC, id ASC").Find(&audits).Error
+	return audits, err
+}
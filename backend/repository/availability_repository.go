@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AvailabilityRepository struct{ db *gorm.DB }
+
+func NewAvailabilityRepository(db *gorm.DB) *AvailabilityRepository {
+	return &AvailabilityRepository{db: db}
+}
+
+func (r *AvailabilityRepository) Save(w *models.AvailabilityWindow) (*models.AvailabilityWindow, error) {
+	return w, r.db.Save(w).Error
+}
+
+func (r *AvailabilityRepository) FindById(alchemistID uint, id int) (*models.AvailabilityWindow, error) {
+	var w models.AvailabilityWindow
+	err := r.db.Where("alchemist_id = ?", alchemistID).First(&w, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *AvailabilityRepository) Delete(w *models.AvailabilityWindow) error {
+	return r.db.Delete(w).Error
+}
+
+// FindOverlapping devuelve las ventanas del alquimista que se solapan con
+// [from, to]. Con from == to comprueba un único instante.
+func (r *AvailabilityRepository) FindOverlapping(alchemistID uint, from, to time.Time) ([]*models.AvailabilityWindow, error) {
+	var xs []*models.AvailabilityWindow
+	err := r.db.Where("alchemist_id = ? AND starts_at <= ? AND ends_at > ?", alchemistID, to, from).
+		Order("starts_at ASC").
+		Find(&xs).Error
+	return xs, err
+}
+
+// FindAllOverlapping devuelve las ventanas de todos los alquimistas que se
+// solapan con [from, to].
+func (r *AvailabilityRepository) FindAllOverlapping(from, to time.Time) ([]*models.AvailabilityWindow, error) {
+	var xs []*models.AvailabilityWindow
+	err := r.db.Where("starts_at < ? AND ends_at > ?", to, from).
+		Order("alchemist_id ASC, starts_at ASC").
+		Find(&xs).Error
+	return xs, err
+}
+
+// FindByAlchemist devuelve las ventanas del alquimista; si from/to no son
+// cero se limitan a las que se solapan con ese intervalo.
+func (r *AvailabilityRepository) FindByAlchemist(alchemistID uint, from, to time.Time) ([]*models.AvailabilityWindow, error) {
+	if !from.IsZero() || !to.IsZero() {
+		if to.IsZero() {
+			to = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+		}
+		return r.FindOverlapping(alchemistID, from, to)
+	}
+	var xs []*models.AvailabilityWindow
+	return xs, r.db.Where("alchemist_id = ?", alchemistID).Order("starts_at ASC").Find(&xs).Error
+}
+
+// FindAvailableAlchemists devuelve los alquimistas sin ventanas de
+// indisponibilidad solapadas con [from, to].
+func (r *AvailabilityRepository) FindAvailableAlchemists(from, to time.Time) ([]*models.Alchemist, error) {
+	busy := r.db.Model(&models.AvailabilityWindow{}).
+		Select("alchemist_id").
+		Where("starts_at <= ? AND ends_at > ?", to, from)
+	var xs []*models.Alchemist
+	err := r.db.Where("id NOT IN (?)", busy).Order("id ASC").Find(&xs).Error
+	return xs, err
+}
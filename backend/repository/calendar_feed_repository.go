@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+
+	"gorm.io/gorm"
+)
+
+type CalendarFeedRepository struct{ db *gorm.DB }
+
+func NewCalendarFeedRepository(db *gorm.DB) *CalendarFeedRepository {
+	return &CalendarFeedRepository{db: db}
+}
+
+func (r *CalendarFeedRepository) FindByAlchemist(alchemistID uint) (*models.CalendarFeed, error) {
+	var f models.CalendarFeed
+	err := r.db.Where("alchemist_id = ?", alchemistID).First(&f).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *CalendarFeedRepository) Save(f *models.CalendarFeed) (*models.CalendarFeed, error) {
+	return f, r.db.Save(f).Error
+}
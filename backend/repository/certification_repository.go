@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CertificationRepository struct{ db *gorm.DB }
+
+func NewCertificationRepository(db *gorm.DB) *CertificationRepository {
+	return &CertificationRepository{db: db}
+}
+
+func (r *CertificationRepository) Save(c *models.Certification) (*models.Certification, error) {
+	return c, r.db.Save(c).Error
+}
+
+func (r *CertificationRepository) FindByAlchemist(alchemistID uint) ([]*models.Certification, error) {
+	var xs []*models.Certification
+	err := r.db.Where("alchemist_id = ?", alchemistID).Order("expires_at DESC").Find(&xs).Error
+	return xs, err
+}
+
+func (r *CertificationRepository) FindById(alchemistID uint, id int) (*models.Certification, error) {
+	var c models.Certification
+	if err := r.db.Where("alchemist_id = ?", alchemistID).First(&c, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CertificationRepository) Delete(c *models.Certification) error {
+	return r.db.Delete(c).Error
+}
+
+// FindLicenses devuelve todas las licencias (vigentes o no) del alquimista.
+func (r *CertificationRepository) FindLicenses(alchemistID uint) ([]*models.Certification, error) {
+	var xs []*models.Certification
+	err := r.db.Where("alchemist_id = ? AND kind = ?", alchemistID, models.CertificationKindLicense).Find(&xs).Error
+	return xs, err
+}
+
+// FindExpiringBetween devuelve las certificaciones no revocadas que vencen en
+// el intervalo [from, to).
+func (r *CertificationRepository) FindExpiringBetween(from, to time.Time) ([]*models.Certification, error) {
+	var xs []*models.Certification
+	err := r.db.Where("revoked_at IS NULL AND expires_at >= ? AND expires_at < ?", from, to).
+		Order("expires_at ASC").
+		Find(&xs).Error
+	return xs, err
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+
+	"gorm.io/gorm"
+)
+
+type DivisionRepository struct {
+	db *gorm.DB
+}
+
+func NewDivisionRepository(db *gorm.DB) *DivisionRepository {
+	return &DivisionRepository{db: db}
+}
+
+func (r *DivisionRepository) FindAll() ([]*models.Division, error) {
+	var xs []*models.Division
+	err := r.db.Preload("Supervisor").Order("name ASC").Find(&xs).Error
+	return xs, err
+}
+
+func (r *DivisionRepository) FindById(id int) (*models.Division, error) {
+	var d models.Division
+	err := r.db.Preload("Supervisor").First(&d, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &d, err
+}
+
+func (r *DivisionRepository) FindByName(name string) (*models.Division, error) {
+	var d models.Division
+	err := r.db.Where("name = ?", name).First(&d).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &d, err
+}
+
+func (r *DivisionRepository) Save(d *models.Division) (*models.Division, error) {
+	if err := r.db.Omit("Supervisor").Save(d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Delete borra la división definitivamente para que su nombre pueda reutilizarse.
+func (r *DivisionRepository) Delete(d *models.Division) error {
+	return r.db.Unscoped().Delete(d).Error
+}
+
+// FindSupervisedIDs devuelve las divisiones que dirige el usuario junto con
+// todas sus subdivisiones.
+func (r *DivisionRepository) FindSupervisedIDs(userID uint) ([]uint, error) {
+	var roots []uint
+	if err := r.db.Model(&models.Division{}).Where("supervisor_id = ?", userID).Order("id ASC").Pluck("id", &roots).Error; err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		return roots, nil
+	}
+	return r.WithDescendants(roots...)
+}
+
+// WithDescendants amplía ids con todas las subdivisiones (sin repetir).
+func (r *DivisionRepository) WithDescendants(ids ...uint) ([]uint, error) {
+	var all []*models.Division
+	if err := r.db.Select("id", "parent_id").Find(&all).Error; err != nil {
+		return nil, err
+	}
+	children := map[uint][]uint{}
+	for _, d := range all {
+		if d.ParentID != nil {
+			children[*d.ParentID] = append(children[*d.ParentID], d.ID)
+		}
+	}
+	seen := map[uint]bool{}
+	out := make([]uint, 0, len(ids))
+	queue := append([]uint(nil), ids...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+		queue = append(queue, children[id]...)
+	}
+	return out, nil
+}
+
+// FindChain devuelve la división indicada seguida de sus ascendientes hasta
+// la raíz.
+func (r *DivisionRepository) FindChain(id uint) ([]*models.Division, error) {
+	var chain []*models.Division
+	seen := map[uint]bool{}
+	for next := &id; next != nil && !seen[*next]; {
+		seen[*next] = true
+		d, err := r.FindById(int(*next))
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			break
+		}
+		chain = append(chain, d)
+		next = d.ParentID
+	}
+	return chain, nil
+}
+
+func (r *DivisionRepository) FindMembers(divisionID uint) ([]*models.Alchemist, error) {
+	var xs []*models.Alchemist
+	err := r.db.Where("division_id = ?", divisionID).Order("id ASC").Find(&xs).Error
+	return xs, err
+}
+
+func (r *DivisionRepository) CountMembers(divisionID uint) (int64, error) {
+	var n int64
+	err := r.db.Model(&models.Alchemist{}).Where("division_id = ?", divisionID).Count(&n).Error
+	return n, err
+}
+
+func (r *DivisionRepository) CountChildren(divisionID uint) (int64, error) {
+	var n int64
+	err := r.db.Model(&models.Division{}).Where("parent_id = ?", divisionID).Count(&n).Error
+	return n, err
+}
+
+// SetMembership mueve al alquimista a la división indicada (nil lo deja sin división).
+func (r *DivisionRepository) SetMembership(alchemistID uint, divisionID *uint) error {
+	return r.db.Model(&models.Alchemist{}).Where("id = ?", alchemistID).Update("division_id", divisionID).Error
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type InvitationRepository struct{ db *gorm.DB }
+
+func NewInvitationRepository(db *gorm.DB) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+func (r *InvitationRepository) FindAll() ([]*models.Invitation, error) {
+	var xs []*models.Invitation
+	err := r.db.Order("created_at desc").Find(&xs).Error
+	return xs, err
+}
+
+func (r *InvitationRepository) FindById(id int) (*models.Invitation, error) {
+	var inv models.Invitation
+	err := r.db.First(&inv, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *InvitationRepository) FindByCodeID(codeID string) (*models.Invitation, error) {
+	var inv models.Invitation
+	err := r.db.Where("code_id = ?", codeID).First(&inv).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *InvitationRepository) Save(inv *models.Invitation) (*models.Invitation, error) {
+	return inv, r.db.Save(inv).Error
+}
+
+func (r *InvitationRepository) Delete(inv *models.Invitation) error {
+	return r.db.Delete(inv).Error
+}
+
+// Claim marca la invitación como usada si nadie lo ha hecho antes. Devuelve
+// false si otra petición se adelantó.
+func (r *InvitationRepository) Claim(id uint, email string, at time.Time) (bool, error) {
+	res := r.db.Model(&models.Invitation{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Updates(map[string]any{"used_at": at, "used_by": email})
+	return res.RowsAffected == 1, res.Error
+}
+
+// Release deshace Claim cuando el registro no llega a completarse.
+func (r *InvitationRepository) Release(id uint) error {
+	return r.db.Model(&models.Invitation{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"used_at": nil, "used_by": ""}).Error
+}
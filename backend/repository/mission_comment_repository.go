@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+
+	"gorm.io/gorm"
+)
+
+type MissionCommentRepository struct{ db *gorm.DB }
+
+func NewMissionCommentRepository(db *gorm.DB) *MissionCommentRepository {
+	return &MissionCommentRepository{db: db}
+}
+
+func (r *MissionCommentRepository) Save(c *models.MissionComment) (*models.MissionComment, error) {
+	return c, r.db.Save(c).Error
+}
+
+func (r *MissionCommentRepository) FindByMission(missionID uint) ([]*models.MissionComment, error) {
+	var xs []*models.MissionComment
+	err := r.db.Preload("Mentions").
+		Where("mission_id = ?", missionID).
+		Order("created_at ASC, id ASC").
+		Find(&xs).Error
+	return xs, err
+}
+
+func (r *MissionCommentRepository) FindById(missionID uint, id int) (*models.MissionComment, error) {
+	var c models.MissionComment
+	if err := r.db.Preload("Mentions").Where("mission_id = ?", missionID).First(&c, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ReplaceMentions sustituye las menciones del comentario por las indicadas.
+func (r *MissionCommentRepository) ReplaceMentions(c *models.MissionComment, mentions []models.MissionCommentMention) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("comment_id = ?", c.ID).Delete(&models.MissionCommentMention{}).Error; err != nil {
+			return err
+		}
+		for i := range mentions {
+			mentions[i].CommentID = c.ID
+		}
+		if len(mentions) > 0 {
+			if err := tx.Create(&mentions).Error; err != nil {
+				return err
+			}
+		}
+		c.Mentions = mentions
+		return nil
+	})
+}
+
+// Delete elimina el comentario junto con sus respuestas.
+func (r *MissionCommentRepository) Delete(c *models.MissionComment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		ids := []uint{c.ID}
+		for frontier := ids; len(frontier) > 0; {
+			var children []uint
+			if err := tx.Model(&models.MissionComment{}).Where("parent_id IN ?", frontier).Pluck("id", &children).Error; err != nil {
+				return err
+			}
+			ids = append(ids, children...)
+			frontier = children
+		}
+		if err := tx.Where("comment_id IN ?", ids).Delete(&models.MissionCommentMention{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.MissionComment{}).Error
+	})
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+
+	"gorm.io/gorm"
+)
+
+type MissionDependencyRepository struct{ db *gorm.DB }
+
+func NewMissionDependencyRepository(db *gorm.DB) *MissionDependencyRepository {
+	return &MissionDependencyRepository{db: db}
+}
+
+// FindAll devuelve las dependencias entre misiones existentes: las aristas
+// hacia misiones eliminadas no cuentan para el grafo ni para los ciclos.
+func (r *MissionDependencyRepository) FindAll() ([]*models.MissionDependency, error) {
+	var xs []*models.MissionDependency
+	err := r.db.
+		Joins("JOIN missions m ON m.id = mission_dependencies.mission_id AND m.deleted_at IS NULL").
+		Joins("JOIN missions p ON p.id = mission_dependencies.depends_on_id AND p.deleted_at IS NULL").
+		Find(&xs).Error
+	return xs, err
+}
+
+func (r *MissionDependencyRepository) Find(missionID, dependsOnID uint) (*models.MissionDependency, error) {
+	var d models.MissionDependency
+	err := r.db.Where("mission_id = ? AND depends_on_id = ?", missionID, dependsOnID).First(&d).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *MissionDependencyRepository) Save(d *models.MissionDependency) (*models.MissionDependency, error) {
+	return d, r.db.Save(d).Error
+}
+
+func (r *MissionDependencyRepository) Delete(d *models.MissionDependency) error {
+	return r.db.Delete(d).Error
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"testing"
+)
+
+func TestMissionDependencyFindAllSkipsDeletedMissions(t *testing.T) {
+	db := newTestDB(t, &models.Mission{}, &models.MissionDependency{})
+	missions := NewMissionRepository(db)
+	deps := NewMissionDependencyRepository(db)
+	var ms [3]*models.Mission
+	for i := range ms {
+		ms[i] = &models.Mission{Title: "m"}
+		if _, err := missions.Save(ms[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, d := range []*models.MissionDependency{
+		{MissionID: ms[1].ID, DependsOnID: ms[0].ID},
+		{MissionID: ms[2].ID, DependsOnID: ms[1].ID},
+	} {
+		if _, err := deps.Save(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Borrado directo de la misión, sin pasar por el repositorio: las filas
+	// de dependencia quedan huérfanas.
+	if err := db.Delete(ms[0]).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := deps.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].MissionID != ms[2].ID || got[0].DependsOnID != ms[1].ID {
+		t.Errorf("FindAll = %+v, want only %d -> %d", got, ms[2].ID, ms[1].ID)
+	}
+}
@@ -17,12 +17,12 @@ func (r *MissionRepository) Save(m *models.Mission) (*models.Mission, error) {
 
 func (r *MissionRepository) FindAll() ([]*models.Mission, error) {
 	var xs []*models.Mission
-	return xs, r.db.Find(&xs).Error
+	return xs, r.db.Preload("Materials").Find(&xs).Error
 }
 
 func (r *MissionRepository) FindById(id int) (*models.Mission, error) {
 	var m models.Mission
-	if err := r.db.First(&m, id).Error; err != nil {
+	if err := r.db.Preload("Materials").First(&m, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -31,8 +31,71 @@ func (r *MissionRepository) FindById(id int) (*models.Mission, error) {
 	return &m, nil
 }
 
-func (r *MissionRepository) Delete(m *models.Mission) error {
-	return r.db.Delete(m).Error
+// Delete elimina la misión junto con sus subtareas, materiales, requisitos de
+// habilidad, comentarios, historial de estados, adjuntos y las dependencias en
+// las que participa, en una sola transacción. Devuelve los adjuntos borrados
+// para que el llamador elimine sus ficheros una vez confirmado el borrado.
+func (r *MissionRepository) Delete(m *models.Mission) ([]*models.Attachment, error) {
+	var attachments []*models.Attachment
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("mission_id = ? OR depends_on_id = ?", m.ID, m.ID).Delete(&models.MissionDependency{}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("mission_id = ?", m.ID).Delete(&models.MissionTask{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("mission_id = ?", m.ID).Delete(&models.MissionMaterial{}).Error; err != nil {
+			return err
+		}
+		err = tx.Where("entity = ? AND entity_id = ?", models.SkillRequirementMission, m.ID).Delete(&models.SkillRequirement{}).Error
+		if err != nil {
+			return err
+		}
+		comments := tx.Model(&models.MissionComment{}).Select("id").Where("mission_id = ?", m.ID)
+		if err := tx.Where("comment_id IN (?)", comments).Delete(&models.MissionCommentMention{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("mission_id = ?", m.ID).Delete(&models.MissionComment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("mission_id = ?", m.ID).Delete(&models.MissionStatusChange{}).Error; err != nil {
+			return err
+		}
+		err = tx.Where("entity = ? AND entity_id = ?", models.AttachmentMission, m.ID).Find(&attachments).Error
+		if err != nil {
+			return err
+		}
+		if len(attachments) > 0 {
+			if err := tx.Unscoped().Delete(&attachments).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(m).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *MissionRepository) FindByIds(ids []uint) ([]*models.Mission, error) {
+	var xs []*models.Mission
+	if len(ids) == 0 {
+		return xs, nil
+	}
+	return xs, r.db.Where("id IN ?", ids).Find(&xs).Error
+}
+
+// FindOpenPrerequisites devuelve las misiones de las que depende la misión
+// indicada y que todavía no están completadas.
+func (r *MissionRepository) FindOpenPrerequisites(missionID uint) ([]*models.Mission, error) {
+	var xs []*models.Mission
+	err := r.db.
+		Joins("JOIN mission_dependencies d ON d.depends_on_id = missions.id AND d.deleted_at IS NULL").
+		Where("d.mission_id = ? AND missions.status <> ?", missionID, "completada").
+		Find(&xs).Error
+	return xs, err
 }
 
 func (r *MissionRepository) FindOpenBefore(threshold time.Time) ([]*models.Mission, error) {
@@ -40,3 +103,52 @@ func (r *MissionRepository) FindOpenBefore(threshold time.Time) ([]*models.Missi
 	err := r.db.Where("status <> ? AND updated_at < ?", "completada", threshold).Find(&ms).Error
 	return ms, err
 }
+
+func (r *MissionRepository) SaveStatusChange(c *models.MissionStatusChange) error {
+	return r.db.Save(c).Error
+}
+
+func (r *MissionRepository) FindStatusChanges(missionID uint) ([]*models.MissionStatusChange, error) {
+	var xs []*models.MissionStatusChange
+	err := r.db.Where("mission_id = ?", missionID).Order("created_at ASC, id ASC").Find(&xs).Error
+	return xs, err
+}
+
+// FindOpenAssigned devuelve las misiones abiertas con responsable asignado.
+func (r *MissionRepository) FindOpenAssigned() ([]*models.Mission, error) {
+	var xs []*models.Mission
+	err := r.db.Where("assigned_to <> 0 AND status NOT IN ?", []string{"completada", "cancelada"}).
+		Order("due_date ASC, id ASC").
+		Find(&xs).Error
+	return xs, err
+}
+
+// FindDueByAssignee devuelve las misiones del alquimista que tienen fecha límite.
+func (r *MissionRepository) FindDueByAssignee(alchemistID uint) ([]*models.Mission, error) {
+	var xs []*models.Mission
+	err := r.db.Where("assigned_to = ? AND due_date IS NOT NULL", alchemistID).Order("due_date ASC").Find(&xs).Error
+	return xs, err
+}
+
+// CountCompletedByAssignee cuenta las misiones completadas por alquimista.
+// Sin IDs cuenta para todos.
+func (r *MissionRepository) CountCompletedByAssignee(ids ...uint) (map[uint]int, error) {
+	var rows []struct {
+		AssignedTo uint
+		Total      int
+	}
+	q := r.db.Model(&models.Mission{}).
+		Select("assigned_to, COUNT(*) AS total").
+		Where("status = ? AND assigned_to <> 0", "completada")
+	if len(ids) > 0 {
+		q = q.Where("assigned_to IN ?", ids)
+	}
+	if err := q.Group("assigned_to").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		out[row.AssignedTo] = row.Total
+	}
+	return out, nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"testing"
+)
+
+func TestMissionDeleteRemovesChildren(t *testing.T) {
+	db := newTestDB(t,
+		&models.Mission{}, &models.MissionTask{}, &models.MissionMaterial{},
+		&models.MissionDependency{}, &models.SkillRequirement{},
+		&models.MissionComment{}, &models.MissionCommentMention{},
+		&models.MissionStatusChange{}, &models.Attachment{},
+	)
+	repo := NewMissionRepository(db)
+	doomed := &models.Mission{Title: "borrar"}
+	kept := &models.Mission{Title: "conservar"}
+	other := &models.Mission{Title: "otra"}
+	for _, m := range []*models.Mission{doomed, kept, other} {
+		if _, err := repo.Save(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows := []any{
+		&models.MissionTask{MissionID: doomed.ID, Title: "t"},
+		&models.MissionTask{MissionID: kept.ID, Title: "t"},
+		&models.MissionMaterial{MissionID: doomed.ID, MaterialID: 1, Quantity: 2},
+		&models.MissionMaterial{MissionID: kept.ID, MaterialID: 1, Quantity: 3},
+		&models.SkillRequirement{Entity: models.SkillRequirementMission, EntityID: doomed.ID, SkillID: 1, MinLevel: 2},
+		&models.SkillRequirement{Entity: models.SkillRequirementMission, EntityID: kept.ID, SkillID: 1, MinLevel: 2},
+		// Requisito de un material con el mismo ID: no es de la misión.
+		&models.SkillRequirement{Entity: models.SkillRequirementMaterial, EntityID: doomed.ID, SkillID: 1, MinLevel: 1},
+		// Dependencias en ambos sentidos y una ajena.
+		&models.MissionDependency{MissionID: doomed.ID, DependsOnID: kept.ID},
+		&models.MissionDependency{MissionID: other.ID, DependsOnID: doomed.ID},
+		&models.MissionDependency{MissionID: other.ID, DependsOnID: kept.ID},
+		&models.MissionStatusChange{MissionID: doomed.ID, FromStatus: "pendiente", ToStatus: "en_progreso"},
+		&models.MissionStatusChange{MissionID: kept.ID, FromStatus: "pendiente", ToStatus: "en_progreso"},
+		&models.Attachment{Entity: models.AttachmentMission, EntityID: doomed.ID, StorageKey: "m/doomed"},
+		&models.Attachment{Entity: models.AttachmentMission, EntityID: kept.ID, StorageKey: "m/kept"},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	doomedComment := &models.MissionComment{MissionID: doomed.ID, Body: "hola"}
+	keptComment := &models.MissionComment{MissionID: kept.ID, Body: "hola"}
+	for _, c := range []*models.MissionComment{doomedComment, keptComment} {
+		if err := db.Create(c).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&models.MissionCommentMention{CommentID: c.ID, UserID: 1}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := repo.Delete(doomed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].StorageKey != "m/doomed" {
+		t.Errorf("removed attachments = %+v, want only m/doomed", removed)
+	}
+
+	count := func(model any, query string, args ...any) int64 {
+		var n int64
+		if err := db.Model(model).Where(query, args...).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	countUnscoped := func(model any, query string, args ...any) int64 {
+		var n int64
+		if err := db.Unscoped().Model(model).Where(query, args...).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	tests := []struct {
+		name string
+		got  int64
+		want int64
+	}{
+		{"subtareas de la misión", count(&models.MissionTask{}, "mission_id = ?", doomed.ID), 0},
+		{"materiales de la misión", count(&models.MissionMaterial{}, "mission_id = ?", doomed.ID), 0},
+		{"requisitos de la misión", count(&models.SkillRequirement{}, "entity = ? AND entity_id = ?", models.SkillRequirementMission, doomed.ID), 0},
+		{"dependencias en ambos sentidos", count(&models.MissionDependency{}, "mission_id = ? OR depends_on_id = ?", doomed.ID, doomed.ID), 0},
+		{"subtareas ajenas", count(&models.MissionTask{}, "mission_id = ?", kept.ID), 1},
+		{"materiales ajenos", count(&models.MissionMaterial{}, "mission_id = ?", kept.ID), 1},
+		{"requisitos de material", count(&models.SkillRequirement{}, "entity = ?", models.SkillRequirementMaterial), 1},
+		{"dependencias ajenas", count(&models.MissionDependency{}, "1 = 1"), 1},
+		{"comentarios de la misión", count(&models.MissionComment{}, "mission_id = ?", doomed.ID), 0},
+		{"menciones de la misión", count(&models.MissionCommentMention{}, "comment_id = ?", doomedComment.ID), 0},
+		{"historial de la misión", count(&models.MissionStatusChange{}, "mission_id = ?", doomed.ID), 0},
+		{"adjuntos de la misión", countUnscoped(&models.Attachment{}, "entity_id = ?", doomed.ID), 0},
+		{"comentarios ajenos", count(&models.MissionComment{}, "mission_id = ?", kept.ID), 1},
+		{"menciones ajenas", count(&models.MissionCommentMention{}, "comment_id = ?", keptComment.ID), 1},
+		{"historial ajeno", count(&models.MissionStatusChange{}, "mission_id = ?", kept.ID), 1},
+		{"adjuntos ajenos", count(&models.Attachment{}, "entity_id = ?", kept.ID), 1},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: %d rows, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if m, err := repo.FindById(int(doomed.ID)); err != nil || m != nil {
+		t.Errorf("FindById after delete = %v, %v", m, err)
+	}
+}
+
+func TestMissionFindAllPreloadsMaterials(t *testing.T) {
+	db := newTestDB(t, &models.Mission{}, &models.MissionMaterial{})
+	repo := NewMissionRepository(db)
+	m := &models.Mission{Title: "m", Materials: []models.MissionMaterial{{MaterialID: 4, Quantity: 1.5}}}
+	if _, err := repo.Save(m); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := repo.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, err := repo.FindById(int(m.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || len(all[0].Materials) != 1 || len(one.Materials) != 1 {
+		t.Fatalf("materials: FindAll %+v, FindById %+v", all, one)
+	}
+	if all[0].Materials[0].MaterialID != one.Materials[0].MaterialID {
+		t.Errorf("FindAll and FindById disagree: %+v vs %+v", all[0].Materials, one.Materials)
+	}
+}
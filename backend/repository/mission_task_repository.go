@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+
+	"gorm.io/gorm"
+)
+
+type MissionTaskRepository struct{ db *gorm.DB }
+
+func NewMissionTaskRepository(db *gorm.DB) *MissionTaskRepository {
+	return &MissionTaskRepository{db: db}
+}
+
+func (r *MissionTaskRepository) Save(t *models.MissionTask) (*models.MissionTask, error) {
+	return t, r.db.Save(t).Error
+}
+
+func (r *MissionTaskRepository) FindByMission(missionID uint) ([]*models.MissionTask, error) {
+	var xs []*models.MissionTask
+	err := r.db.Where("mission_id = ?", missionID).Order("position ASC, id ASC").Find(&xs).Error
+	return xs, err
+}
+
+// FindById busca una subtarea dentro de la misión indicada.
+func (r *MissionTaskRepository) FindById(missionID uint, id int) (*models.MissionTask, error) {
+	var t models.MissionTask
+	if err := r.db.Where("mission_id = ?", missionID).First(&t, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *MissionTaskRepository) Delete(t *models.MissionTask) error {
+	return r.db.Delete(t).Error
+}
+
+// NextPosition devuelve la posición que ocupará una subtarea agregada al final.
+func (r *MissionTaskRepository) NextPosition(missionID uint) (int, error) {
+	var max *int
+	err := r.db.Model(&models.MissionTask{}).
+		Where("mission_id = ?", missionID).
+		Select("MAX(position)").
+		Scan(&max).Error
+	if err != nil || max == nil {
+		return 0, err
+	}
+	return *max + 1, nil
+}
+
+// CountOpenMandatory cuenta las subtareas obligatorias que aún no se completan.
+func (r *MissionTaskRepository) CountOpenMandatory(missionID uint) (int64, error) {
+	var n int64
+	err := r.db.Model(&models.MissionTask{}).
+		Where("mission_id = ? AND mandatory = ? AND status <> ?", missionID, true, "completada").
+		Count(&n).Error
+	return n, err
+}
+
+// FindIDsByMission devuelve los IDs de todas las subtareas, incluidas las
+// eliminadas, para poder rastrear su historial.
+func (r *MissionTaskRepository) FindIDsByMission(missionID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Unscoped().Model(&models.MissionTask{}).Where("mission_id = ?", missionID).Pluck("id", &ids).Error
+	return ids, err
+}
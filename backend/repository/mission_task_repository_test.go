@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"testing"
+)
+
+func TestCountOpenMandatory(t *testing.T) {
+	type task struct {
+		status    string
+		mandatory bool
+	}
+	tests := []struct {
+		name  string
+		tasks []task
+		want  int64
+	}{
+		{"sin subtareas", nil, 0},
+		{"obligatorias completadas", []task{{"completada", true}, {"completada", true}}, 0},
+		{"obligatoria pendiente", []task{{"completada", true}, {"pendiente", true}}, 1},
+		{"obligatoria en progreso", []task{{"en_progreso", true}}, 1},
+		{"opcionales abiertas no bloquean", []task{{"pendiente", false}, {"en_progreso", false}, {"completada", true}}, 0},
+		{"mezcla", []task{{"pendiente", true}, {"en_progreso", true}, {"pendiente", false}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.MissionTask{})
+			repo := NewMissionTaskRepository(db)
+			for i, x := range tt.tasks {
+				if _, err := repo.Save(&models.MissionTask{MissionID: 1, Title: "t", Status: x.status, Mandatory: x.mandatory, Position: i}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Las subtareas de otras misiones no cuentan.
+			if _, err := repo.Save(&models.MissionTask{MissionID: 2, Title: "otra", Status: "pendiente", Mandatory: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := repo.CountOpenMandatory(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CountOpenMandatory = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrTemplateAlreadyRun indica que otra instancia ya ejecutó la plantilla para
+// la fecha programada.
+var ErrTemplateAlreadyRun = errors.New("mission template already instantiated for this run")
+
+type MissionTemplateRepository struct{ db *gorm.DB }
+
+func NewMissionTemplateRepository(db *gorm.DB) *MissionTemplateRepository {
+	return &MissionTemplateRepository{db: db}
+}
+
+func (r *MissionTemplateRepository) withChildren() *gorm.DB {
+	return r.db.
+		Preload("Materials").
+		Preload("Tasks", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC, id ASC") })
+}
+
+func (r *MissionTemplateRepository) FindAll() ([]*models.MissionTemplate, error) {
+	var xs []*models.MissionTemplate
+	return xs, r.withChildren().Find(&xs).Error
+}
+
+func (r *MissionTemplateRepository) FindById(id int) (*models.MissionTemplate, error) {
+	var t models.MissionTemplate
+	if err := r.withChildren().First(&t, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// FindDue devuelve las plantillas activas cuya próxima ejecución ya venció.
+func (r *MissionTemplateRepository) FindDue(now time.Time) ([]*models.MissionTemplate, error) {
+	var xs []*models.MissionTemplate
+	err := r.withChildren().
+		Where("active = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Find(&xs).Error
+	return xs, err
+}
+
+// Save guarda la plantilla y reemplaza sus materiales y subtareas por los que
+// trae el struct.
+func (r *MissionTemplateRepository) Save(t *models.MissionTemplate) (*models.MissionTemplate, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Materials", "Tasks").Save(t).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("template_id = ?", t.ID).Delete(&models.MissionTemplateMaterial{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("template_id = ?", t.ID).Delete(&models.MissionTemplateTask{}).Error; err != nil {
+			return err
+		}
+		for i := range t.Materials {
+			t.Materials[i].ID = 0
+			t.Materials[i].TemplateID = t.ID
+		}
+		for i := range t.Tasks {
+			t.Tasks[i].ID = 0
+			t.Tasks[i].TemplateID = t.ID
+		}
+		if len(t.Materials) > 0 {
+			if err := tx.Create(&t.Materials).Error; err != nil {
+				return err
+			}
+		}
+		if len(t.Tasks) > 0 {
+			if err := tx.Create(&t.Tasks).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *MissionTemplateRepository) Delete(t *models.MissionTemplate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", t.ID).Delete(&models.MissionTemplateMaterial{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("template_id = ?", t.ID).Delete(&models.MissionTemplateTask{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(t).Error
+	})
+}
+
+// Deactivate desactiva la plantilla y borra su próxima ejecución.
+func (r *MissionTemplateRepository) Deactivate(t *models.MissionTemplate) error {
+	err := r.db.Model(&models.MissionTemplate{}).Where("id = ?", t.ID).
+		Updates(map[string]any{"active": false, "next_run_at": nil}).Error
+	if err != nil {
+		return err
+	}
+	t.Active = false
+	t.NextRunAt = nil
+	return nil
+}
+
+// Instantiate crea una misión (con sus subtareas y materiales) a partir de la
+// plantilla y registra la ejecución. nextRun es la siguiente fecha programada;
+// nil la deja sin cambios. Con nextRun, la plantilla solo avanza si su
+// next_run_at sigue siendo el leído: si otra instancia se adelantó se deshace
+// la misión creada y se devuelve ErrTemplateAlreadyRun.
+func (r *MissionTemplateRepository) Instantiate(t *models.MissionTemplate, now time.Time, nextRun *time.Time) (*models.Mission, error) {
+	templateID := t.ID
+	m := &models.Mission{
+		Title:       t.Title,
+		Description: t.Description,
+		Difficulty:  t.Difficulty,
+		Status:      "pendiente",
+		AssignedTo:  t.AssignedTo,
+		TemplateID:  &templateID,
+	}
+	for _, tm := range t.Materials {
+		m.Materials = append(m.Materials, models.MissionMaterial{MaterialID: tm.MaterialID, Quantity: tm.Quantity})
+	}
+	for _, tt := range t.Tasks {
+		m.Tasks = append(m.Tasks, models.MissionTask{
+			Title:       tt.Title,
+			Description: tt.Description,
+			AssignedTo:  tt.AssignedTo,
+			Status:      "pendiente",
+			Position:    tt.Position,
+			Mandatory:   tt.Mandatory,
+		})
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		updates := map[string]any{"last_run_at": now}
+		q := tx.Model(&models.MissionTemplate{}).Where("id = ?", t.ID)
+		if nextRun != nil {
+			updates["next_run_at"] = *nextRun
+			if t.NextRunAt == nil {
+				q = q.Where("next_run_at IS NULL")
+			} else {
+				q = q.Where("next_run_at = ?", *t.NextRunAt)
+			}
+		}
+		res := q.Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if nextRun != nil && res.RowsAffected == 0 {
+			return ErrTemplateAlreadyRun
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.LastRunAt = &now
+	if nextRun != nil {
+		t.NextRunAt = nextRun
+	}
+	return m, nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMissionTemplateInstantiateOncePerRun(t *testing.T) {
+	db := newTestDB(t,
+		&models.MissionTemplate{}, &models.MissionTemplateMaterial{}, &models.MissionTemplateTask{},
+		&models.Mission{}, &models.MissionMaterial{}, &models.MissionTask{},
+	)
+	repo := NewMissionTemplateRepository(db)
+	due := time.Now().Add(-time.Hour)
+	tpl := &models.MissionTemplate{
+		Title:     "inventario",
+		Active:    true,
+		StartsAt:  due,
+		NextRunAt: &due,
+		Tasks:     []models.MissionTemplateTask{{Title: "contar"}},
+	}
+	if _, err := repo.Save(tpl); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dos instancias leen la misma plantilla pendiente.
+	now := time.Now()
+	var copies []*models.MissionTemplate
+	for range 2 {
+		xs, err := repo.FindDue(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(xs) != 1 {
+			t.Fatalf("FindDue = %d templates, want 1", len(xs))
+		}
+		copies = append(copies, xs[0])
+	}
+	next := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		tpl     *models.MissionTemplate
+		wantErr error
+	}{
+		{"primera ejecución", copies[0], nil},
+		{"ejecución concurrente", copies[1], ErrTemplateAlreadyRun},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.Instantiate(tt.tpl, now, &next)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Instantiate error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var missions, tasks int64
+	db.Model(&models.Mission{}).Count(&missions)
+	db.Model(&models.MissionTask{}).Count(&tasks)
+	if missions != 1 || tasks != 1 {
+		t.Errorf("missions = %d, tasks = %d, want 1 and 1", missions, tasks)
+	}
+	if xs, err := repo.FindDue(now); err != nil || len(xs) != 0 {
+		t.Errorf("FindDue after run = %d templates, %v", len(xs), err)
+	}
+}
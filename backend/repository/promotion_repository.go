@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrRankChanged indica que el rango del alquimista cambió desde que se creó
+// la solicitud de ascenso.
+var ErrRankChanged = errors.New("alchemist rank changed since the request was filed")
+
+type PromotionRepository struct{ db *gorm.DB }
+
+func NewPromotionRepository(db *gorm.DB) *PromotionRepository {
+	return &PromotionRepository{db: db}
+}
+
+func (r *PromotionRepository) Save(p *models.PromotionRequest) (*models.PromotionRequest, error) {
+	return p, r.db.Save(p).Error
+}
+
+func (r *PromotionRepository) FindById(id int) (*models.PromotionRequest, error) {
+	var p models.PromotionRequest
+	err := r.db.First(&p, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindAll devuelve las solicitudes, opcionalmente filtradas por estado.
+func (r *PromotionRepository) FindAll(status string) ([]*models.PromotionRequest, error) {
+	var xs []*models.PromotionRequest
+	q := r.db.Order("created_at DESC, id DESC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	return xs, q.Find(&xs).Error
+}
+
+func (r *PromotionRepository) FindByAlchemist(alchemistID uint) ([]*models.PromotionRequest, error) {
+	var xs []*models.PromotionRequest
+	err := r.db.Where("alchemist_id = ?", alchemistID).Order("created_at DESC, id DESC").Find(&xs).Error
+	return xs, err
+}
+
+func (r *PromotionRepository) FindPending(alchemistID uint) (*models.PromotionRequest, error) {
+	var p models.PromotionRequest
+	err := r.db.Where("alchemist_id = ? AND status = ?", alchemistID, models.PromotionPending).First(&p).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// PendingAlchemistIDs devuelve los alquimistas con una solicitud pendiente.
+func (r *PromotionRepository) PendingAlchemistIDs() (map[uint]bool, error) {
+	var ids []uint
+	err := r.db.Model(&models.PromotionRequest{}).
+		Where("status = ?", models.PromotionPending).
+		Pluck("alchemist_id", &ids).Error
+	out := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		out[id] = true
+	}
+	return out, err
+}
+
+// Approve aplica el ascenso: actualiza el rango, registra el historial y
+// cierra la solicitud en una sola transacción.
+func (r *PromotionRepository) Approve(p *models.PromotionRequest, reviewer, comment string, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var a models.Alchemist
+		if err := tx.First(&a, p.AlchemistID).Error; err != nil {
+			return err
+		}
+		if a.Rank != p.FromRank {
+			return ErrRankChanged
+		}
+		a.Rank = p.ToRank
+		if err := tx.Save(&a).Error; err != nil {
+			return err
+		}
+		change := &models.RankChange{
+			AlchemistID:        a.ID,
+			FromRank:           p.FromRank,
+			ToRank:             p.ToRank,
+			PromotionRequestID: &p.ID,
+			ChangedBy:          reviewer,
+			Reason:             p.Justification,
+		}
+		if err := tx.Create(change).Error; err != nil {
+			return err
+		}
+		p.Status = models.PromotionApproved
+		p.ReviewedBy = reviewer
+		p.ReviewedAt = &now
+		p.ReviewComment = comment
+		return tx.Save(p).Error
+	})
+}
+
+func (r *PromotionRepository) FindRankHistory(alchemistID uint) ([]*models.RankChange, error) {
+	var xs []*models.RankChange
+	err := r.db.Where("alchemist_id = ?", alchemistID).Order("created_at ASC, id ASC").Find(&xs).Error
+	return xs, err
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository struct{ db *gorm.DB }
+
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+func (r *RefreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&t).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *RefreshTokenRepository) Save(t *models.RefreshToken) (*models.RefreshToken, error) {
+	return t, r.db.Save(t).Error
+}
+
+// MarkUsed marca el token como rotado si seguía activo. Devuelve false si otra
+// petición lo usó o revocó antes.
+func (r *RefreshTokenRepository) MarkUsed(id uint, at time.Time) (bool, error) {
+	res := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", at)
+	return res.RowsAffected == 1, res.Error
+}
+
+// RevokeFamily revoca todos los tokens de la familia y devuelve los que
+// seguían activos, para poder revocar también sus tokens de acceso.
+func (r *RefreshTokenRepository) RevokeFamily(familyID string, at time.Time) ([]*models.RefreshToken, error) {
+	var live []*models.RefreshToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("family_id = ? AND revoked_at IS NULL AND access_expires_at > ?", familyID, at).
+			Find(&live).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", at).Error
+	})
+	return live, err
+}
+
+// RevokeUser revoca todas las sesiones del usuario (p. ej. al cambiar la
+// contraseña) y devuelve los tokens que seguían activos.
+func (r *RefreshTokenRepository) RevokeUser(userID uint, at time.Time) ([]*models.RefreshToken, error) {
+	var live []*models.RefreshToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND revoked_at IS NULL AND access_expires_at > ?", userID, at).
+			Find(&live).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", at).Error
+	})
+	return live, err
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+
+	"gorm.io/gorm"
+)
+
+type RoleRepository struct{ db *gorm.DB }
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (r *RoleRepository) FindAll() ([]*models.Role, error) {
+	var xs []*models.Role
+	return xs, r.db.Preload("Permissions").Order("name ASC").Find(&xs).Error
+}
+
+func (r *RoleRepository) FindById(id int) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").First(&role, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *RoleRepository) FindByName(name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Save guarda el rol y reemplaza su lista de permisos por role.Permissions.
+func (r *RoleRepository) Save(role *models.Role) (*models.Role, error) {
+	perms := role.Permissions
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Permissions").Save(role).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		for i := range perms {
+			perms[i].RoleID = role.ID
+		}
+		if len(perms) == 0 {
+			return nil
+		}
+		return tx.Create(&perms).Error
+	})
+	role.Permissions = perms
+	return role, err
+}
+
+// Delete elimina el rol definitivamente para poder reutilizar el nombre.
+func (r *RoleRepository) Delete(role *models.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(role).Error
+	})
+}
+
+// CountUsers cuenta los usuarios que tienen asignado el rol.
+func (r *RoleRepository) CountUsers(name string) (int64, error) {
+	var n int64
+	return n, r.db.Model(&models.User{}).Where("role = ?", name).Count(&n).Error
+}
+
+// SeedDefaults crea los roles de sistema que falten y concede a los existentes
+// los permisos añadidos al catálogo desde el último arranque. Los permisos ya
+// conocidos no se tocan para respetar los cambios hechos desde /roles.
+func (r *RoleRepository) SeedDefaults(defaults map[string][]string, catalog []string) error {
+	var names []string
+	if err := r.db.Model(&models.KnownPermission{}).Pluck("name", &names).Error; err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(names))
+	for _, n := range names {
+		known[n] = true
+	}
+
+	for name, perms := range defaults {
+		role, err := r.FindByName(name)
+		if err != nil {
+			return err
+		}
+		if role == nil {
+			role = &models.Role{Name: name, System: true}
+			for _, p := range perms {
+				role.Permissions = append(role.Permissions, models.RolePermission{Permission: p})
+			}
+			if _, err := r.Save(role); err != nil {
+				return err
+			}
+			continue
+		}
+		if !role.System {
+			continue
+		}
+		for _, p := range perms {
+			if known[p] {
+				continue
+			}
+			grant := models.RolePermission{RoleID: role.ID, Permission: p}
+			if err := r.db.FirstOrCreate(&grant, grant).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, p := range catalog {
+		if !known[p] {
+			if err := r.db.Create(&models.KnownPermission{Name: p}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
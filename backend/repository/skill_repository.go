@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+
+	"gorm.io/gorm"
+)
+
+type SkillRepository struct{ db *gorm.DB }
+
+func NewSkillRepository(db *gorm.DB) *SkillRepository {
+	return &SkillRepository{db: db}
+}
+
+// ----- Catálogo -----
+
+func (r *SkillRepository) FindAll() ([]*models.Skill, error) {
+	var xs []*models.Skill
+	return xs, r.db.Order("name ASC").Find(&xs).Error
+}
+
+func (r *SkillRepository) FindById(id int) (*models.Skill, error) {
+	var s models.Skill
+	err := r.db.First(&s, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SkillRepository) FindByName(name string) (*models.Skill, error) {
+	var s models.Skill
+	err := r.db.Where("name = ?", name).First(&s).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SkillRepository) Save(s *models.Skill) (*models.Skill, error) {
+	return s, r.db.Save(s).Error
+}
+
+// Delete elimina la habilidad junto con los niveles, respaldos y requisitos
+// que la referencian.
+func (r *SkillRepository) Delete(s *models.Skill) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		sub := tx.Model(&models.AlchemistSkill{}).Select("id").Where("skill_id = ?", s.ID)
+		if err := tx.Where("alchemist_skill_id IN (?)", sub).Delete(&models.SkillEndorsement{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("skill_id = ?", s.ID).Delete(&models.AlchemistSkill{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("skill_id = ?", s.ID).Delete(&models.SkillRequirement{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(s).Error
+	})
+}
+
+// ----- Niveles por alquimista -----
+
+func (r *SkillRepository) FindByAlchemist(alchemistID uint) ([]*models.AlchemistSkill, error) {
+	var xs []*models.AlchemistSkill
+	err := r.db.Preload("Skill").Preload("Endorsements").
+		Where("alchemist_id = ?", alchemistID).
+		Order("level DESC, skill_id ASC").
+		Find(&xs).Error
+	return xs, err
+}
+
+func (r *SkillRepository) FindAlchemistSkill(alchemistID, skillID uint) (*models.AlchemistSkill, error) {
+	var s models.AlchemistSkill
+	err := r.db.Preload("Skill").Preload("Endorsements").
+		Where("alchemist_id = ? AND skill_id = ?", alchemistID, skillID).
+		First(&s).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveAlchemistSkill guarda el nivel; si resetEndorsements es true se
+// descartan los respaldos previos (el nivel cambió).
+func (r *SkillRepository) SaveAlchemistSkill(s *models.AlchemistSkill, resetEndorsements bool) (*models.AlchemistSkill, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Skill", "Endorsements").Save(s).Error; err != nil {
+			return err
+		}
+		if resetEndorsements {
+			if err := tx.Where("alchemist_skill_id = ?", s.ID).Delete(&models.SkillEndorsement{}).Error; err != nil {
+				return err
+			}
+			s.Endorsements = nil
+		}
+		return nil
+	})
+	return s, err
+}
+
+func (r *SkillRepository) DeleteAlchemistSkill(s *models.AlchemistSkill) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("alchemist_skill_id = ?", s.ID).Delete(&models.SkillEndorsement{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(s).Error
+	})
+}
+
+func (r *SkillRepository) SaveEndorsement(e *models.SkillEndorsement) (*models.SkillEndorsement, error) {
+	return e, r.db.Save(e).Error
+}
+
+// ----- Requisitos -----
+
+func (r *SkillRepository) FindRequirements(entity string, entityID uint) ([]*models.SkillRequirement, error) {
+	var xs []*models.SkillRequirement
+	err := r.db.Preload("Skill").
+		Where("entity = ? AND entity_id = ?", entity, entityID).
+		Order("skill_id ASC").
+		Find(&xs).Error
+	return xs, err
+}
+
+// ReplaceRequirements sustituye todos los requisitos de la entidad.
+func (r *SkillRepository) ReplaceRequirements(entity string, entityID uint, reqs []*models.SkillRequirement) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("entity = ? AND entity_id = ?", entity, entityID).Delete(&models.SkillRequirement{}).Error; err != nil {
+			return err
+		}
+		for _, req := range reqs {
+			req.Entity = entity
+			req.EntityID = entityID
+			if err := tx.Omit("Skill").Create(req).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Unmet devuelve los requisitos que el alquimista no alcanza.
+func (r *SkillRepository) Unmet(alchemistID uint, reqs []*models.SkillRequirement) ([]*models.SkillRequirement, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	var owned []*models.AlchemistSkill
+	if err := r.db.Where("alchemist_id = ?", alchemistID).Find(&owned).Error; err != nil {
+		return nil, err
+	}
+	levels := make(map[uint]int, len(owned))
+	for _, s := range owned {
+		levels[s.SkillID] = s.Level
+	}
+	var unmet []*models.SkillRequirement
+	for _, req := range reqs {
+		if levels[req.SkillID] < req.MinLevel {
+			unmet = append(unmet, req)
+		}
+	}
+	return unmet, nil
+}
+
+// FindQualifiedAlchemistIDs devuelve los alquimistas que cumplen todos los
+// requisitos indicados.
+func (r *SkillRepository) FindQualifiedAlchemistIDs(reqs []*models.SkillRequirement) ([]uint, error) {
+	q := r.db.Model(&models.Alchemist{}).Select("alchemists.id")
+	for _, req := range reqs {
+		q = q.Where(
+			"EXISTS (SELECT 1 FROM alchemist_skills s WHERE s.alchemist_id = alchemists.id AND s.skill_id = ? AND s.level >= ? AND s.deleted_at IS NULL)",
+			req.SkillID, req.MinLevel,
+		)
+	}
+	var ids []uint
+	return ids, q.Order("alchemists.id ASC").Pluck("alchemists.id", &ids).Error
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TransmutationStats resume las transmutaciones de un alquimista.
+type TransmutationStats struct {
+	Total                int
+	Completed            int
+	InProgress           int
+	Failed               int
+	Cancelled            int // No cuentan como fallidas
+	AvgProcessingSeconds float64
+}
+
+// MissionStats resume las misiones asignadas a un alquimista.
+type MissionStats struct {
+	Assigned       int
+	Completed      int
+	OnTime         int
+	Late           int
+	WithoutDueDate int
+	Overdue        int // Abiertas con la fecha límite vencida
+}
+
+// MaterialConsumption agrupa el uso de un material por un alquimista.
+type MaterialConsumption struct {
+	MaterialID      uint
+	Name            string
+	Transmutations  int
+	MissionQuantity float64
+}
+
+// StatsRepository agrupa las consultas de agregación de rendimiento. Solo se
+// usa SQL común a SQLite y Postgres salvo la diferencia de fechas, que
+// depende del motor (ver secondsBetween).
+type StatsRepository struct{ db *gorm.DB }
+
+func NewStatsRepository(db *gorm.DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// secondsBetween devuelve la expresión SQL para (to - from) en segundos.
+func (r *StatsRepository) secondsBetween(from, to string) string {
+	if r.db.Dialector.Name() == "postgres" {
+		return "EXTRACT(EPOCH FROM (" + to + " - " + from + "))"
+	}
+	return "(julianday(" + to + ") - julianday(" + from + ")) * 86400.0"
+}
+
+func (r *StatsRepository) Transmutations(alchemistID uint) (*TransmutationStats, error) {
+	var s TransmutationStats
+	err := r.db.Model(&models.Transmutation{}).
+		Select(`COUNT(*) AS total,
+			COALESCE(SUM(CASE WHEN status = 'completada' THEN 1 ELSE 0 END), 0) AS completed,
+			COALESCE(SUM(CASE WHEN status IN ('en_proceso', 'programada') THEN 1 ELSE 0 END), 0) AS in_progress,
+			COALESCE(SUM(CASE WHEN status NOT IN ('completada', 'en_proceso', 'programada', 'cancelada') THEN 1 ELSE 0 END), 0) AS failed,
+			COALESCE(SUM(CASE WHEN status = 'cancelada' THEN 1 ELSE 0 END), 0) AS cancelled,
+			COALESCE(AVG(CASE WHEN status = 'completada' AND completed_at IS NOT NULL THEN `+
+			r.secondsBetween("created_at", "completed_at")+` END), 0) AS avg_processing_seconds`).
+		Where("alchemist_id = ?", alchemistID).
+		Scan(&s).Error
+	return &s, err
+}
+
+func (r *StatsRepository) Missions(alchemistID uint, now time.Time) (*MissionStats, error) {
+	// Momento de cierre: último cambio a "completada"; para misiones cerradas
+	// antes de que existiera el historial se usa updated_at. Las fechas se
+	// comparan con secondsBetween: en SQLite son texto y la comparación
+	// directa depende del formato y la zona horaria con que se guardaron.
+	completedAt := "COALESCE(c.completed_at, m.updated_at)"
+	delay := r.secondsBetween("m.due_date", completedAt)
+	completions := r.db.Model(&models.MissionStatusChange{}).
+		Select("mission_id, MAX(created_at) AS completed_at").
+		Where("to_status = ?", "completada").
+		Group("mission_id")
+
+	var s MissionStats
+	err := r.db.Table("missions AS m").
+		Select(`COUNT(*) AS assigned,
+			COALESCE(SUM(CASE WHEN m.status = 'completada' THEN 1 ELSE 0 END), 0) AS completed,
+			COALESCE(SUM(CASE WHEN m.status = 'completada' AND m.due_date IS NOT NULL
+				AND `+delay+` <= 0 THEN 1 ELSE 0 END), 0) AS on_time,
+			COALESCE(SUM(CASE WHEN m.status = 'completada' AND m.due_date IS NOT NULL
+				AND `+delay+` > 0 THEN 1 ELSE 0 END), 0) AS late,
+			COALESCE(SUM(CASE WHEN m.status = 'completada' AND m.due_date IS NULL THEN 1 ELSE 0 END), 0) AS without_due_date,
+			COALESCE(SUM(CASE WHEN m.status NOT IN ('completada', 'cancelada') AND `+r.secondsBetween("m.due_date", "?")+` > 0 THEN 1 ELSE 0 END), 0) AS overdue`, now).
+		Joins("LEFT JOIN (?) AS c ON c.mission_id = m.id", completions).
+		Where("m.assigned_to = ? AND m.deleted_at IS NULL", alchemistID).
+		Scan(&s).Error
+	return &s, err
+}
+
+// Materials devuelve los materiales usados en transmutaciones completadas y
+// los requeridos por misiones completadas del alquimista.
+func (r *StatsRepository) Materials(alchemistID uint) ([]*MaterialConsumption, error) {
+	var fromTransmutations []struct {
+		MaterialID uint
+		Total      int
+	}
+	if err := r.db.Model(&models.Transmutation{}).
+		Select("material_id, COUNT(*) AS total").
+		Where("alchemist_id = ? AND status = ?", alchemistID, "completada").
+		Group("material_id").
+		Scan(&fromTransmutations).Error; err != nil {
+		return nil, err
+	}
+
+	var fromMissions []struct {
+		MaterialID uint
+		Quantity   float64
+	}
+	if err := r.db.Table("mission_materials AS mm").
+		Select("mm.material_id, SUM(mm.quantity) AS quantity").
+		Joins("JOIN missions m ON m.id = mm.mission_id AND m.deleted_at IS NULL").
+		Where("m.assigned_to = ? AND m.status = ? AND mm.deleted_at IS NULL", alchemistID, "completada").
+		Group("mm.material_id").
+		Scan(&fromMissions).Error; err != nil {
+		return nil, err
+	}
+
+	byID := map[uint]*MaterialConsumption{}
+	var ids []uint
+	entry := func(id uint) *MaterialConsumption {
+		if c, ok := byID[id]; ok {
+			return c
+		}
+		c := &MaterialConsumption{MaterialID: id}
+		byID[id] = c
+		ids = append(ids, id)
+		return c
+	}
+	for _, row := range fromTransmutations {
+		entry(row.MaterialID).Transmutations = row.Total
+	}
+	for _, row := range fromMissions {
+		entry(row.MaterialID).MissionQuantity = row.Quantity
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var materials []*models.Material
+	if err := r.db.Unscoped().Where("id IN ?", ids).Find(&materials).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range materials {
+		byID[m.ID].Name = m.Name
+	}
+	out := make([]*MaterialConsumption, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, byID[id])
+	}
+	return out, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"testing"
+	"time"
+)
+
+func TestStatsMissionsOnTimeAndLate(t *testing.T) {
+	db := newTestDB(t, &models.Mission{}, &models.MissionStatusChange{})
+	repo := NewStatsRepository(db)
+	const alchemist = 7
+	utc := func(h int) time.Time { return time.Date(2026, 3, 1, h, 0, 0, 0, time.UTC) }
+	// Fechas límite con otra zona horaria: comparadas como texto darían el
+	// resultado contrario.
+	plus2 := time.FixedZone("UTC+2", 2*60*60)
+	minus3 := time.FixedZone("UTC-3", -3*60*60)
+	late := utc(11)
+	onTime := utc(11)
+	lateDue := time.Date(2026, 3, 1, 12, 0, 0, 0, plus2)   // 10:00 UTC
+	onTimeDue := time.Date(2026, 3, 1, 9, 0, 0, 0, minus3) // 12:00 UTC
+	overdueDue := utc(9)
+	futureDue := utc(23)
+
+	missions := []struct {
+		m         *models.Mission
+		completed *time.Time
+	}{
+		{&models.Mission{Title: "tarde", Status: "completada", AssignedTo: alchemist, DueDate: &lateDue}, &late},
+		{&models.Mission{Title: "tarde otra vez", Status: "completada", AssignedTo: alchemist, DueDate: &lateDue}, &late},
+		{&models.Mission{Title: "a tiempo", Status: "completada", AssignedTo: alchemist, DueDate: &onTimeDue}, &onTime},
+		{&models.Mission{Title: "sin fecha", Status: "completada", AssignedTo: alchemist}, &onTime},
+		{&models.Mission{Title: "vencida", Status: "en_progreso", AssignedTo: alchemist, DueDate: &overdueDue}, nil},
+		{&models.Mission{Title: "en plazo", Status: "pendiente", AssignedTo: alchemist, DueDate: &futureDue}, nil},
+		{&models.Mission{Title: "cancelada", Status: "cancelada", AssignedTo: alchemist, DueDate: &overdueDue}, nil},
+		{&models.Mission{Title: "de otro", Status: "completada", AssignedTo: alchemist + 1, DueDate: &lateDue}, &late},
+	}
+	for _, x := range missions {
+		if err := db.Create(x.m).Error; err != nil {
+			t.Fatal(err)
+		}
+		if x.completed != nil {
+			change := &models.MissionStatusChange{MissionID: x.m.ID, FromStatus: "en_progreso", ToStatus: "completada"}
+			change.CreatedAt = *x.completed
+			if err := db.Create(change).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	s, err := repo.Missions(alchemist, utc(12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"asignadas", s.Assigned, 7},
+		{"completadas", s.Completed, 4},
+		{"a tiempo", s.OnTime, 1},
+		{"tarde", s.Late, 2},
+		{"sin fecha límite", s.WithoutDueDate, 1},
+		{"vencidas", s.Overdue, 1},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestStatsTransmutationsExcludeCancelled(t *testing.T) {
+	db := newTestDB(t, &models.Transmutation{})
+	repo := NewStatsRepository(db)
+	const alchemist = 7
+	for _, status := range []string{"completada", "completada", "fallida", "cancelada", "cancelada", "en_proceso", "programada"} {
+		if err := db.Create(&models.Transmutation{AlchemistID: alchemist, MaterialID: 1, Status: status}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := repo.Transmutations(alchemist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"total", s.Total, 7},
+		{"completadas", s.Completed, 2},
+		{"en curso", s.InProgress, 2},
+		{"fallidas", s.Failed, 1},
+		{"canceladas", s.Cancelled, 2},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
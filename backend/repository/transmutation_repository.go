@@ -46,3 +46,61 @@ func (r *TransmutationRepository) Save(t *models.Transmutation) (*models.Transmu
 	}
 	return t, nil
 }
+
+// FindQueued devuelve las transmutaciones pendientes de procesar, incluidas
+// las programadas para más adelante.
+func (r *TransmutationRepository) FindQueued() ([]*models.Transmutation, error) {
+	var ts []*models.Transmutation
+	err := r.db.Where("status IN ?", []string{"en_proceso", "programada"}).Order("id ASC").Find(&ts).Error
+	return ts, err
+}
+
+// FindScheduledDue devuelve las transmutaciones programadas cuya hora ya llegó.
+func (r *TransmutationRepository) FindScheduledDue(now time.Time) ([]*models.Transmutation, error) {
+	var ts []*models.Transmutation
+	err := r.db.Where("status = ? AND scheduled_at <= ?", "programada", now).Order("scheduled_at ASC").Find(&ts).Error
+	return ts, err
+}
+
+// Release pasa una transmutación programada a en_proceso. Devuelve false si
+// otra pasada del planificador ya la había liberado.
+func (r *TransmutationRepository) Release(t *models.Transmutation) (bool, error) {
+	res := r.db.Model(&models.Transmutation{}).
+		Where("id = ? AND status = ?", t.ID, "programada").
+		Update("status", "en_proceso")
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	t.Status = "en_proceso"
+	return true, nil
+}
+
+// FindScheduledByAlchemist devuelve las transmutaciones programadas del alquimista.
+func (r *TransmutationRepository) FindScheduledByAlchemist(alchemistID uint) ([]*models.Transmutation, error) {
+	var ts []*models.Transmutation
+	err := r.db.Where("alchemist_id = ? AND scheduled_at IS NOT NULL", alchemistID).Order("scheduled_at ASC").Find(&ts).Error
+	return ts, err
+}
+
+// CountCompletedByAlchemist cuenta las transmutaciones completadas con éxito
+// por alquimista. Sin IDs cuenta para todos.
+func (r *TransmutationRepository) CountCompletedByAlchemist(ids ...uint) (map[uint]int, error) {
+	var rows []struct {
+		AlchemistID uint
+		Total       int
+	}
+	q := r.db.Model(&models.Transmutation{}).
+		Select("alchemist_id, COUNT(*) AS total").
+		Where("status = ?", "completada")
+	if len(ids) > 0 {
+		q = q.Where("alchemist_id IN ?", ids)
+	}
+	if err := q.Group("alchemist_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		out[row.AlchemistID] = row.Total
+	}
+	return out, nil
+}
@@ -2,13 +2,27 @@ package repository
 
 import (
 	"backend-avanzada/models"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
+// UserFilter acota el listado de usuarios; los campos vacíos no filtran.
+type UserFilter struct {
+	Role     string
+	Disabled *bool
+	Email    string // Subcadena, sin distinguir mayúsculas
+}
+
 type UserRepository interface {
+	FindAll(f UserFilter) ([]*models.User, error)
 	FindByEmail(email string) (*models.User, error)
+	FindByID(id uint) (*models.User, error)
+	FindByAlchemistID(alchemistID uint) (*models.User, error)
+	FindByOIDCSubject(subject string) (*models.User, error)
 	Save(u *models.User) (*models.User, error)
+	CountByRole(role string) (int64, error)
+	AdvanceTOTPStep(id uint, step int64) (bool, error)
 }
 
 type GormUserRepository struct {
@@ -19,6 +33,25 @@ func NewUserRepository(db *gorm.DB) *GormUserRepository {
 	return &GormUserRepository{db: db}
 }
 
+func (r *GormUserRepository) FindAll(f UserFilter) ([]*models.User, error) {
+	var users []*models.User
+	q := r.db.Order("email ASC")
+	if f.Role != "" {
+		q = q.Where("role = ?", f.Role)
+	}
+	if f.Disabled != nil {
+		if *f.Disabled {
+			q = q.Where("disabled_at IS NOT NULL")
+		} else {
+			q = q.Where("disabled_at IS NULL")
+		}
+	}
+	if f.Email != "" {
+		q = q.Where("LOWER(email) LIKE ?", "%"+strings.ToLower(f.Email)+"%")
+	}
+	return users, q.Find(&users).Error
+}
+
 func (r *GormUserRepository) FindByEmail(email string) (*models.User, error) {
 	var u models.User
 	err := r.db.Where("email = ?", email).First(&u).Error
@@ -37,3 +70,54 @@ func (r *GormUserRepository) Save(u *models.User) (*models.User, error) {
 	}
 	return u, nil
 }
+
+func (r *GormUserRepository) FindByAlchemistID(alchemistID uint) (*models.User, error) {
+	var u models.User
+	err := r.db.Where("alchemist_id = ?", alchemistID).First(&u).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *GormUserRepository) FindByOIDCSubject(subject string) (*models.User, error) {
+	var u models.User
+	err := r.db.Where("oidc_subject = ?", subject).First(&u).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *GormUserRepository) CountByRole(role string) (int64, error) {
+	var n int64
+	err := r.db.Model(&models.User{}).Where("role = ?", role).Count(&n).Error
+	return n, err
+}
+
+func (r *GormUserRepository) FindByID(id uint) (*models.User, error) {
+	var u models.User
+	err := r.db.First(&u, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// AdvanceTOTPStep registra el paso TOTP aceptado si es posterior al último.
+// Devuelve false si ese código (o uno más reciente) ya se había usado.
+func (r *GormUserRepository) AdvanceTOTPStep(id uint, step int64) (bool, error) {
+	res := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	return res.RowsAffected == 1, res.Error
+}
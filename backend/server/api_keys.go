@@ -0,0 +1,36 @@
+package server
+
+import (
+	"backend-avanzada/server/handlers"
+	"net/http"
+	"time"
+)
+
+// apiKeyTouchInterval limita las escrituras de LastUsedAt a una por minuto.
+const apiKeyTouchInterval = time.Minute
+
+// apiKeyClaims resuelve una cabecera X-API-Key. Devuelve nil si la clave no
+// existe, está revocada o ha caducado.
+func (s *Server) apiKeyClaims(key string) (*AuthClaims, error) {
+	if s.APIKeyRepository == nil {
+		return nil, nil
+	}
+	k, err := s.APIKeyRepository.FindByHash(handlers.HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if k == nil || !k.Active(now) {
+		return nil, nil
+	}
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.APIKeyRepository.TouchLastUsed(k.ID, now); err != nil {
+			s.logger.Error(http.StatusInternalServerError, "api-key", err)
+		}
+	}
+	return &AuthClaims{
+		Email:       "apikey:" + k.Name,
+		APIKeyID:    k.ID,
+		Permissions: k.PermissionNames(),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bootstrapAdmin crea el primer administrador a partir de
+// BOOTSTRAP_ADMIN_EMAIL y BOOTSTRAP_ADMIN_PASSWORD. Solo actúa mientras no
+// exista ningún administrador; a partir de ahí las cuentas privilegiadas se
+// crean con invitaciones. Si la cuenta ya existe (p. ej. el supervisor inicial
+// de una instalación anterior) y la contraseña coincide, se promueve.
+func (s *Server) bootstrapAdmin() error {
+	email := strings.TrimSpace(os.Getenv("BOOTSTRAP_ADMIN_EMAIL"))
+	password := os.Getenv("BOOTSTRAP_ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return nil
+	}
+	if len(password) < 8 {
+		return fmt.Errorf("BOOTSTRAP_ADMIN_PASSWORD must have at least 8 characters")
+	}
+
+	admins, err := s.UserRepository.CountByRole("admin")
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+	existing, err := s.UserRepository.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if bcrypt.CompareHashAndPassword([]byte(existing.PasswordHash), []byte(password)) != nil {
+			return fmt.Errorf("bootstrap admin %s already exists with a different password", email)
+		}
+		existing.Role = "admin"
+		if _, err := s.UserRepository.Save(existing); err != nil {
+			return err
+		}
+		fmt.Println("Cuenta promovida a administrador:", email)
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if _, err := s.UserRepository.Save(&models.User{
+		Email:           email,
+		PasswordHash:    string(hash),
+		Role:            "admin",
+		EmailVerifiedAt: &now,
+	}); err != nil {
+		return err
+	}
+	fmt.Println("Administrador inicial creado:", email)
+	return nil
+}
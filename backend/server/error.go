@@ -1,16 +1,19 @@
 package server
 
 import (
+	"backend-avanzada/api"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 )
 
 type ErrorResponse struct {
-	StatusCode int    `json:"status_code"`
-	Path       string `json:"path"`
-	Message    string `json:"message"`
-	Timestamp  string `json:"timestamp"`
+	StatusCode int              `json:"status_code"`
+	Path       string           `json:"path"`
+	Message    string           `json:"message"`
+	Errors     []api.FieldError `json:"errors,omitempty"`
+	Timestamp  string           `json:"timestamp"`
 }
 
 func (s *Server) HandleError(w http.ResponseWriter, statusCode int, path string, cause error) {
@@ -23,6 +26,10 @@ func (s *Server) HandleError(w http.ResponseWriter, statusCode int, path string,
 		Message:    cause.Error(),
 		Timestamp:  time.Now().Format(time.RFC3339),
 	}
+	var ve *api.ValidationError
+	if errors.As(cause, &ve) {
+		resp.Errors = ve.Errors
+	}
 
 	json.NewEncoder(w).Encode(resp)
 	s.logger.Error(statusCode, path, cause)
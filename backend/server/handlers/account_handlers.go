@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/mail"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultPasswordResetTTL     = time.Hour
+	DefaultEmailVerificationTTL = 48 * time.Hour
+	mailTimeout                 = 30 * time.Second
+)
+
+var errInvalidAccountToken = errors.New("invalid or expired token")
+
+// AccountClaims son los datos firmados en los enlaces de cuenta. El ID (jti)
+// enlaza con el registro que impide reutilizarlos y Subject es el usuario.
+type AccountClaims struct {
+	Purpose string `json:"purpose"`
+	Email   string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// accountKey deriva una clave de firma distinta por finalidad, de modo que un
+// enlace de verificación no sirva para restablecer la contraseña ni como
+// token de sesión.
+func accountKey(purpose, secret string) []byte {
+	sum := sha256.Sum256([]byte(purpose + ":" + secret))
+	return sum[:]
+}
+
+// AccountHandler gestiona los flujos por correo: restablecer la contraseña y
+// verificar la dirección de email.
+type AccountHandler struct {
+	Users            repository.UserRepository
+	Tokens           *repository.AccountTokenRepository
+	Mailer           mail.Sender
+	Secret           string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+	// AppURL es la base de los enlaces enviados (p. ej. la URL del frontend);
+	// vacía, el correo solo incluye el token.
+	AppURL          string
+	ResetTTL        time.Duration
+	VerificationTTL time.Duration
+	// RevokeSessions cierra las sesiones abiertas tras cambiar la contraseña.
+	RevokeSessions func(ctx context.Context, userID uint) error
+}
+
+func NewAccountHandler(
+	users repository.UserRepository,
+	tokens *repository.AccountTokenRepository,
+	mailer mail.Sender,
+	secret string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *AccountHandler {
+	return &AccountHandler{
+		Users:            users,
+		Tokens:           tokens,
+		Mailer:           mailer,
+		Secret:           secret,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+		ResetTTL:         DefaultPasswordResetTTL,
+		VerificationTTL:  DefaultEmailVerificationTTL,
+	}
+}
+
+// issue registra un token de un solo uso para el usuario y devuelve su firma.
+func (h *AccountHandler) issue(u *models.User, purpose string, ttl time.Duration) (string, error) {
+	tokenID, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	t, err := h.Tokens.Save(&models.AccountToken{
+		TokenID:   tokenID,
+		UserID:    u.ID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	claims := &AccountClaims{
+		Purpose: purpose,
+		Email:   u.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        t.TokenID,
+			Subject:   strconv.FormatUint(uint64(u.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(t.ExpiresAt),
+			Issuer:    "alchemist-system",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(accountKey(purpose, h.Secret))
+}
+
+// redeem verifica la firma del token, lo marca como usado y devuelve su
+// usuario. Falla si el email de la cuenta cambió desde que se emitió.
+func (h *AccountHandler) redeem(code, purpose string) (*models.User, error) {
+	claims := &AccountClaims{}
+	_, err := jwt.ParseWithClaims(code, claims, func(t *jwt.Token) (interface{}, error) {
+		return accountKey(purpose, h.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || claims.Purpose != purpose {
+		return nil, errInvalidAccountToken
+	}
+	t, err := h.Tokens.FindByTokenID(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.Purpose != purpose || t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		return nil, errInvalidAccountToken
+	}
+	u, err := h.Users.FindByID(t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || u.Disabled() || !strings.EqualFold(u.Email, claims.Email) {
+		return nil, errInvalidAccountToken
+	}
+	claimed, err := h.Tokens.Claim(t.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, errInvalidAccountToken
+	}
+	return u, nil
+}
+
+func (h *AccountHandler) link(path, token string) string {
+	if h.AppURL == "" {
+		return token
+	}
+	return strings.TrimRight(h.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// durationText expresa una duración en horas, minutos o segundos para los
+// textos dirigidos a personas.
+func durationText(d time.Duration) string {
+	unit := func(n int, one, many string) string {
+		if n == 1 {
+			return "1 " + one
+		}
+		return fmt.Sprintf("%d %s", n, many)
+	}
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return unit(int(d/time.Hour), "hora", "horas")
+	case d >= time.Minute:
+		return unit(int(d/time.Minute), "minuto", "minutos")
+	default:
+		return unit(int((d+time.Second-1)/time.Second), "segundo", "segundos")
+	}
+}
+
+// send entrega el correo en segundo plano para que la respuesta no dependa
+// del servidor de correo ni delate si la cuenta existe.
+func (h *AccountHandler) send(path string, msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := h.Mailer.Send(ctx, msg); err != nil {
+			h.ReportAsyncError(path, err)
+		}
+	}()
+}
+
+func (h *AccountHandler) sendVerification(path string, u *models.User) error {
+	token, err := h.issue(u, models.TokenEmailVerification, h.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	h.send(path, mail.Message{
+		To:      u.Email,
+		Subject: "Confirma tu dirección de correo",
+		Body: fmt.Sprintf("Hola,\n\nConfirma tu dirección de correo con este enlace:\n\n%s\n\nCaduca en %s. Si no has creado una cuenta, ignora este mensaje.\n",
+			h.link("/verify-email", token), durationText(h.VerificationTTL)),
+	})
+	return nil
+}
+
+// sendPasswordReset envía el enlace de restablecimiento; intro explica el
+// motivo y note cierra el mensaje.
+func (h *AccountHandler) sendPasswordReset(path string, u *models.User, intro, note string) error {
+	token, err := h.issue(u, models.TokenPasswordReset, h.ResetTTL)
+	if err != nil {
+		return err
+	}
+	h.send(path, mail.Message{
+		To:      u.Email,
+		Subject: "Restablecer contraseña",
+		Body: fmt.Sprintf("Hola,\n\n%s Usa este enlace:\n\n%s\n\nCaduca en %s y solo se puede usar una vez. %s\n",
+			intro, h.link("/reset-password", token), durationText(h.ResetTTL), note),
+	})
+	return nil
+}
+
+// SendForcedPasswordReset envía el enlace de restablecimiento cuando un
+// supervisor obliga a cambiar la contraseña.
+func (h *AccountHandler) SendForcedPasswordReset(r *http.Request, u *models.User) error {
+	return h.sendPasswordReset(r.URL.Path, u,
+		"Un supervisor ha solicitado que cambies tu contraseña; la actual ya no permite iniciar sesión.",
+		"Si no puedes usarlo a tiempo, pide uno nuevo desde la pantalla de acceso.")
+}
+
+// SendVerification envía el enlace de verificación a una cuenta recién
+// registrada. Los fallos no impiden el registro: se pueden pedir de nuevo.
+func (h *AccountHandler) SendVerification(r *http.Request, u *models.User) {
+	if err := h.sendVerification(r.URL.Path, u); err != nil {
+		h.ReportAsyncError(r.URL.Path, err)
+	}
+}
+
+// POST /auth/password/forgot. Responde 202 exista o no la cuenta.
+func (h *AccountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.EmailRequest
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	u, err := h.Users.FindByEmail(req.Email)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if u != nil && !u.Disabled() {
+		if err := h.sendPasswordReset(r.URL.Path, u,
+			"Hemos recibido una solicitud para restablecer tu contraseña.",
+			"Si no lo has pedido tú, ignora este mensaje."); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// POST /auth/password/reset fija la nueva contraseña y cierra las sesiones
+// abiertas. Usar el enlace también confirma el email.
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.PasswordResetRequest
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	u, err := h.redeem(req.Token, models.TokenPasswordReset)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidAccountToken) {
+			status = http.StatusBadRequest
+		}
+		h.HandleErr(w, status, r.URL.Path, err)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	now := time.Now()
+	u.PasswordHash = string(hash)
+	u.PasswordResetRequired = false
+	if u.EmailVerifiedAt == nil {
+		u.EmailVerifiedAt = &now
+	}
+	if _, err := h.Users.Save(u); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if err := h.Tokens.ConsumeAll(u.ID, models.TokenPasswordReset, now); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.RevokeSessions != nil {
+		if err := h.RevokeSessions(r.Context(), u.ID); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.Log(http.StatusNoContent, r.URL.Path, start)
+}
+
+// POST /auth/email/verification reenvía el enlace de verificación. Responde
+// 202 exista o no la cuenta.
+func (h *AccountHandler) RequestVerification(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.EmailRequest
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	u, err := h.Users.FindByEmail(req.Email)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if u != nil && u.EmailVerifiedAt == nil {
+		if err := h.sendVerification(r.URL.Path, u); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// POST /auth/email/verify
+func (h *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.EmailVerifyRequest
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	u, err := h.redeem(req.Token, models.TokenEmailVerification)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidAccountToken) {
+			status = http.StatusBadRequest
+		}
+		h.HandleErr(w, status, r.URL.Path, err)
+		return
+	}
+	now := time.Now()
+	if u.EmailVerifiedAt == nil {
+		u.EmailVerifiedAt = &now
+		if _, err := h.Users.Save(u); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+	}
+	if err := h.Tokens.ConsumeAll(u.ID, models.TokenEmailVerification, now); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.Log(http.StatusNoContent, r.URL.Path, start)
+}
@@ -4,8 +4,10 @@ import (
 	"backend-avanzada/api"
 	"backend-avanzada/models"
 	"backend-avanzada/repository"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -15,15 +17,22 @@ import (
 
 type AlchemistHandler struct {
 	Repo             *repository.AlchemistRepository
+	UserRepo         repository.UserRepository
 	Dispatcher       AsyncDispatcher
 	CurrentUser      func(*http.Request) string
 	ReportAsyncError func(string, error)
 	Log              func(status int, path string, start time.Time)
 	HandleErr        func(w http.ResponseWriter, statusCode int, path string, cause error)
+	// Scope restringe a los supervisores de división a sus propios recursos.
+	Scope *DivisionScope
+	// RevokeSessions cierra las sesiones del usuario al cambiar su vínculo:
+	// los tokens emitidos llevan el alquimista anterior.
+	RevokeSessions func(ctx context.Context, userID uint) error
 }
 
 func NewAlchemistHandler(
 	repo *repository.AlchemistRepository,
+	userRepo repository.UserRepository,
 	dispatcher AsyncDispatcher,
 	currentUser func(*http.Request) string,
 	reportAsyncError func(string, error),
@@ -32,6 +41,7 @@ func NewAlchemistHandler(
 ) *AlchemistHandler {
 	return &AlchemistHandler{
 		Repo:             repo,
+		UserRepo:         userRepo,
 		Dispatcher:       dispatcher,
 		CurrentUser:      currentUser,
 		ReportAsyncError: reportAsyncError,
@@ -47,23 +57,59 @@ func (h *AlchemistHandler) userEmail(r *http.Request) string {
 	return ""
 }
 
+// saveLink guarda el vínculo del usuario y cierra sus sesiones abiertas.
+func (h *AlchemistHandler) saveLink(w http.ResponseWriter, r *http.Request, u *models.User) bool {
+	if _, err := h.UserRepo.Save(u); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return false
+	}
+	if h.RevokeSessions != nil {
+		if err := h.RevokeSessions(r.Context(), u.ID); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return false
+		}
+	}
+	return true
+}
+
+func alchemistResponse(a *models.Alchemist) *api.AlchemistResponseDto {
+	return &api.AlchemistResponseDto{
+		ID:                  int(a.ID),
+		Name:                a.Name,
+		Age:                 a.Age,
+		Specialty:           a.Specialty,
+		Rank:                a.Rank,
+		DivisionID:          a.DivisionID,
+		WeeklyCapacityHours: a.WeeklyCapacityHours,
+		CreatedAt:           a.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// GET /alchemists[?skill=metallurgy&min_level=3]
 func (h *AlchemistHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	alchs, err := h.Repo.FindAll()
+	var alchs []*models.Alchemist
+	var err error
+	if skill := r.URL.Query().Get("skill"); skill != "" {
+		minLevel := models.SkillMinLevel
+		if raw := r.URL.Query().Get("min_level"); raw != "" {
+			minLevel, err = strconv.Atoi(raw)
+			if err != nil || minLevel < models.SkillMinLevel || minLevel > models.SkillMaxLevel {
+				h.HandleErr(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("min_level must be between %d and %d", models.SkillMinLevel, models.SkillMaxLevel))
+				return
+			}
+		}
+		alchs, err = h.Repo.FindBySkill(normalizeSkillName(skill), minLevel)
+	} else {
+		alchs, err = h.Repo.FindAll()
+	}
 	if err != nil {
 		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
 	}
 	resp := make([]*api.AlchemistResponseDto, 0, len(alchs))
 	for _, a := range alchs {
-		resp = append(resp, &api.AlchemistResponseDto{
-			ID:        int(a.ID),
-			Name:      a.Name,
-			Age:       a.Age,
-			Specialty: a.Specialty,
-			Rank:      a.Rank,
-			CreatedAt: a.CreatedAt.Format(time.RFC3339),
-		})
+		resp = append(resp, alchemistResponse(a))
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"data": resp})
@@ -86,14 +132,7 @@ func (h *AlchemistHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
 		return
 	}
-	resp := &api.AlchemistResponseDto{
-		ID:        int(a.ID),
-		Name:      a.Name,
-		Age:       a.Age,
-		Specialty: a.Specialty,
-		Rank:      a.Rank,
-		CreatedAt: a.CreatedAt.Format(time.RFC3339),
-	}
+	resp := alchemistResponse(a)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"data": resp})
 	h.Log(http.StatusOK, r.URL.Path, start)
@@ -102,21 +141,47 @@ func (h *AlchemistHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 func (h *AlchemistHandler) Create(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	var req api.AlchemistRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleErr) {
 		return
 	}
-	if req.Name == "" {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("name required"))
+	// El rango inicial debe pertenecer a la escala (lo comprueba la
+	// validación); los cambios posteriores pasan por el flujo de ascensos.
+	if req.Rank == "" {
+		req.Rank = models.RankLadder[0].Name
+	}
+	// Los supervisores de división registran alquimistas en su propia división.
+	scoped, err := h.Scope.Divisions(r)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if req.DivisionID == nil && len(scoped) > 0 {
+		req.DivisionID = &scoped[0]
+	}
+	if req.DivisionID != nil && h.Scope != nil {
+		d, err := h.Scope.Repo.FindById(int(*req.DivisionID))
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if d == nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("division not found"))
+			return
+		}
+	}
+	if err := h.Scope.CheckDivision(r, req.DivisionID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
 		return
 	}
 	a := &models.Alchemist{
-		Name:      req.Name,
-		Age:       int(req.Age),
-		Specialty: req.Specialty,
-		Rank:      req.Rank,
+		Name:                req.Name,
+		Age:                 int(req.Age),
+		Specialty:           req.Specialty,
+		Rank:                req.Rank,
+		DivisionID:          req.DivisionID,
+		WeeklyCapacityHours: req.WeeklyCapacityHours,
 	}
-	a, err := h.Repo.Save(a)
+	a, err = h.Repo.Save(a)
 	if err != nil {
 		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
@@ -126,14 +191,7 @@ func (h *AlchemistHandler) Create(w http.ResponseWriter, r *http.Request) {
 			h.ReportAsyncError(r.URL.Path, err)
 		}
 	}
-	resp := &api.AlchemistResponseDto{
-		ID:        int(a.ID),
-		Name:      a.Name,
-		Age:       a.Age,
-		Specialty: a.Specialty,
-		Rank:      a.Rank,
-		CreatedAt: a.CreatedAt.Format(time.RFC3339),
-	}
+	resp := alchemistResponse(a)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{"data": resp})
@@ -151,6 +209,10 @@ func (h *AlchemistHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
 		return
 	}
+	if err := h.Scope.CheckDivision(r, a.DivisionID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
 	if err := h.Repo.Delete(a); err != nil {
 		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
@@ -181,9 +243,13 @@ func (h *AlchemistHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.Scope.CheckDivision(r, a.DivisionID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+
 	var req api.AlchemistEditRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleErr) {
 		return
 	}
 
@@ -196,8 +262,12 @@ func (h *AlchemistHandler) Edit(w http.ResponseWriter, r *http.Request) {
 	if req.Specialty != nil {
 		a.Specialty = *req.Specialty
 	}
-	if req.Rank != nil {
-		a.Rank = *req.Rank
+	if req.WeeklyCapacityHours != nil {
+		a.WeeklyCapacityHours = *req.WeeklyCapacityHours
+	}
+	if req.Rank != nil && *req.Rank != a.Rank {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("rank changes must go through a promotion request"))
+		return
 	}
 
 	if _, err := h.Repo.Save(a); err != nil {
@@ -210,16 +280,112 @@ func (h *AlchemistHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	resp := &api.AlchemistResponseDto{
-		ID:        int(a.ID),
-		Name:      a.Name,
-		Age:       a.Age,
-		Specialty: a.Specialty,
-		Rank:      a.Rank,
-		CreatedAt: a.CreatedAt.Format(time.RFC3339),
-	}
+	resp := alchemistResponse(a)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]interface{}{"data": resp})
 	h.Log(http.StatusAccepted, r.URL.Path, start)
 }
+
+// PUT /alchemists/{id}/user vincula una cuenta de usuario con el perfil.
+func (h *AlchemistHandler) LinkUser(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	a, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return
+	}
+	if err := h.Scope.CheckDivision(r, a.DivisionID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+
+	var req api.AlchemistUserLinkRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	u, err := h.UserRepo.FindByEmail(req.Email)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if u == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("user not found"))
+		return
+	}
+	linked, err := h.UserRepo.FindByAlchemistID(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if linked != nil && linked.ID != u.ID {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("alchemist is already linked to another user"))
+		return
+	}
+	if u.AlchemistID != nil {
+		if *u.AlchemistID != a.ID {
+			// Moverlo en silencio dejaría al otro perfil sin cuenta.
+			h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("user is already linked to another alchemist"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		h.Log(http.StatusNoContent, r.URL.Path, start)
+		return
+	}
+
+	u.AlchemistID = &a.ID
+	if !h.saveLink(w, r, u) {
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("link_user", "alchemist", a.ID, h.userEmail(r), "Perfil vinculado al usuario "+u.Email); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.Log(http.StatusNoContent, r.URL.Path, start)
+}
+
+// DELETE /alchemists/{id}/user elimina el vínculo con la cuenta de usuario.
+func (h *AlchemistHandler) UnlinkUser(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	u, err := h.UserRepo.FindByAlchemistID(uint(id))
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if u == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist has no linked user"))
+		return
+	}
+	if err := h.Scope.CheckAlchemist(r, uint(id)); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+
+	u.AlchemistID = nil
+	if !h.saveLink(w, r, u) {
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("unlink_user", "alchemist", uint(id), h.userEmail(r), "Perfil desvinculado del usuario "+u.Email); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.Log(http.StatusNoContent, r.URL.Path, start)
+}
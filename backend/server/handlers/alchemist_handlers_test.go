@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAlchemistUserLink(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.Alchemist{})
+	ed := &models.Alchemist{Name: "Ed", Age: 15}
+	al := &models.Alchemist{Name: "Al", Age: 14}
+	create(t, db, ed)
+	create(t, db, al)
+	edUser := &models.User{Email: "ed@example.com", PasswordHash: "x", Role: "alchemist"}
+	alUser := &models.User{Email: "al@example.com", PasswordHash: "x", Role: "alchemist"}
+	create(t, db, edUser)
+	create(t, db, alUser)
+
+	var revoked []uint
+	h := NewAlchemistHandler(
+		repository.NewAlchemistRepository(db),
+		repository.NewUserRepository(db),
+		nil,
+		nil,
+		nil,
+		func(w http.ResponseWriter, status int, _ string, err error) { http.Error(w, err.Error(), status) },
+		func(int, string, time.Time) {},
+	)
+	h.RevokeSessions = func(_ context.Context, userID uint) error {
+		revoked = append(revoked, userID)
+		return nil
+	}
+	call := func(handler http.HandlerFunc, method string, alchemist uint, body string) int {
+		r := httptest.NewRequest(method, "/", strings.NewReader(body))
+		r = mux.SetURLVars(r, map[string]string{"id": strconv.Itoa(int(alchemist))})
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+	link := `{"email":"ed@example.com"}`
+
+	steps := []struct {
+		name        string
+		handler     http.HandlerFunc
+		method      string
+		alchemist   uint
+		body        string
+		wantStatus  int
+		wantRevoked []uint
+	}{
+		{"vincular", h.LinkUser, http.MethodPut, ed.ID, link, http.StatusNoContent, []uint{edUser.ID}},
+		{"repetir el mismo vínculo", h.LinkUser, http.MethodPut, ed.ID, link, http.StatusNoContent, []uint{edUser.ID}},
+		{"usuario ya vinculado a otro perfil", h.LinkUser, http.MethodPut, al.ID, link, http.StatusConflict, []uint{edUser.ID}},
+		{"perfil ya vinculado a otro usuario", h.LinkUser, http.MethodPut, ed.ID, `{"email":"al@example.com"}`, http.StatusConflict, []uint{edUser.ID}},
+		{"desvincular", h.UnlinkUser, http.MethodDelete, ed.ID, "", http.StatusNoContent, []uint{edUser.ID, edUser.ID}},
+		{"desvincular sin vínculo", h.UnlinkUser, http.MethodDelete, ed.ID, "", http.StatusNotFound, []uint{edUser.ID, edUser.ID}},
+		{"vincular tras desvincular", h.LinkUser, http.MethodPut, al.ID, link, http.StatusNoContent, []uint{edUser.ID, edUser.ID, edUser.ID}},
+	}
+	for _, s := range steps {
+		if got := call(s.handler, s.method, s.alchemist, s.body); got != s.wantStatus {
+			t.Fatalf("%s: status %d, want %d", s.name, got, s.wantStatus)
+		}
+		if !slices.Equal(revoked, s.wantRevoked) {
+			t.Fatalf("%s: revoked sessions %v, want %v", s.name, revoked, s.wantRevoked)
+		}
+	}
+
+	var got models.User
+	if err := db.First(&got, edUser.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.AlchemistID == nil || *got.AlchemistID != al.ID {
+		t.Errorf("AlchemistID = %v, want %d", got.AlchemistID, al.ID)
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const apiKeyPrefix = "ak_"
+
+// HashAPIKey es el valor con el que se guarda y busca una clave de API.
+func HashAPIKey(key string) string {
+	return hashToken(key)
+}
+
+// APIKeyHandler gestiona las claves de API de las integraciones.
+type APIKeyHandler struct {
+	Repo             *repository.APIKeyRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+	// HasPermission impide emitir claves con permisos que el creador no tiene.
+	HasPermission func(*http.Request, string) bool
+}
+
+func NewAPIKeyHandler(
+	repo *repository.APIKeyRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *APIKeyHandler {
+	return &APIKeyHandler{
+		Repo:             repo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *APIKeyHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func apiKeyResponse(k *models.APIKey) *api.APIKeyResponseDto {
+	perms := k.PermissionNames()
+	slices.Sort(perms)
+	resp := &api.APIKeyResponseDto{
+		ID:          int(k.ID),
+		Name:        k.Name,
+		Prefix:      k.Prefix,
+		Permissions: perms,
+		CreatedBy:   k.CreatedBy,
+		CreatedAt:   k.CreatedAt.Format(time.RFC3339),
+	}
+	if k.ExpiresAt != nil {
+		resp.ExpiresAt = k.ExpiresAt.Format(time.RFC3339)
+	}
+	if k.LastUsedAt != nil {
+		resp.LastUsedAt = k.LastUsedAt.Format(time.RFC3339)
+	}
+	if k.RevokedAt != nil {
+		resp.RevokedAt = k.RevokedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// GET /api-keys
+func (h *APIKeyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	keys, err := h.Repo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.APIKeyResponseDto, 0, len(keys))
+	for _, k := range keys {
+		resp = append(resp, apiKeyResponse(k))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /api-keys. La clave en claro solo aparece en esta respuesta.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.APIKeyRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	if h.HasPermission != nil {
+		for _, p := range req.Permissions {
+			if !h.HasPermission(r, p) {
+				h.HandleErr(w, http.StatusForbidden, r.URL.Path, fmt.Errorf("cannot grant %s: you do not have it", p))
+				return
+			}
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	k := &models.APIKey{
+		Name:      req.Name,
+		Prefix:    key[:len(apiKeyPrefix)+6],
+		KeyHash:   HashAPIKey(key),
+		CreatedBy: h.userEmail(r),
+	}
+	for _, p := range req.Permissions {
+		k.Permissions = append(k.Permissions, models.APIKeyPermission{Permission: p})
+	}
+	if req.ExpiresAt != "" {
+		t, _ := time.Parse(time.RFC3339, req.ExpiresAt)
+		k.ExpiresAt = &t
+	}
+	k, err := h.Repo.Create(k)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		details := fmt.Sprintf("Clave de API %q (%s)", k.Name, k.Prefix)
+		if err := h.Dispatcher.EnqueueAudit("create", "api_key", k.ID, h.userEmail(r), details); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	resp := apiKeyResponse(k)
+	resp.Key = key
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// DELETE /api-keys/{id} revoca la clave; el registro se conserva para auditoría.
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	k, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if k == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("api key not found"))
+		return
+	}
+	if k.RevokedAt == nil {
+		if err := h.Repo.Revoke(k.ID, time.Now()); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if h.Dispatcher != nil {
+			details := fmt.Sprintf("Revocación de la clave de API %q (%s)", k.Name, k.Prefix)
+			if err := h.Dispatcher.EnqueueAudit("delete", "api_key", k.ID, h.userEmail(r), details); err != nil {
+				h.ReportAsyncError(r.URL.Path, err)
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
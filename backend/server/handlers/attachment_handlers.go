@@ -0,0 +1,656 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/imaging"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"backend-avanzada/storage"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// DefaultMaxUploadBytes es el tamaño máximo de un fichero si no se configura otro.
+	DefaultMaxUploadBytes = 10 << 20
+	thumbnailSize         = 256
+)
+
+// Tipos aceptados según el contenido real del fichero (no la extensión ni la
+// cabecera enviada por el cliente).
+var (
+	imageContentTypes = map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+	}
+	documentContentTypes = map[string]string{
+		"image/jpeg":      ".jpg",
+		"image/png":       ".png",
+		"image/gif":       ".gif",
+		"application/pdf": ".pdf",
+		"text/plain":      ".txt",
+	}
+)
+
+// AttachmentHandler gestiona las fotos de perfil de los alquimistas y los
+// adjuntos de misiones y transmutaciones.
+type AttachmentHandler struct {
+	Repo              *repository.AttachmentRepository
+	Store             storage.BlobStore
+	AlchemistRepo     *repository.AlchemistRepository
+	MissionRepo       *repository.MissionRepository
+	TransmutationRepo *repository.TransmutationRepository
+	Dispatcher        AsyncDispatcher
+	CurrentUser       func(*http.Request) string
+	CurrentRole       func(*http.Request) string
+	CurrentAlchemist  func(*http.Request) uint
+	ReportAsyncError  func(string, error)
+	HandleErr         func(http.ResponseWriter, int, string, error)
+	Log               func(int, string, time.Time)
+	MaxBytes          int64
+	// HasPermission resuelve permisos del usuario o de la clave de API.
+	HasPermission func(*http.Request, string) bool
+}
+
+func NewAttachmentHandler(
+	repo *repository.AttachmentRepository,
+	store storage.BlobStore,
+	alchemistRepo *repository.AlchemistRepository,
+	missionRepo *repository.MissionRepository,
+	transmutationRepo *repository.TransmutationRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	currentRole func(*http.Request) string,
+	currentAlchemist func(*http.Request) uint,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *AttachmentHandler {
+	return &AttachmentHandler{
+		Repo:              repo,
+		Store:             store,
+		AlchemistRepo:     alchemistRepo,
+		MissionRepo:       missionRepo,
+		TransmutationRepo: transmutationRepo,
+		Dispatcher:        dispatcher,
+		CurrentUser:       currentUser,
+		CurrentRole:       currentRole,
+		CurrentAlchemist:  currentAlchemist,
+		ReportAsyncError:  reportAsyncError,
+		HandleErr:         handleErr,
+		Log:               log,
+		MaxBytes:          DefaultMaxUploadBytes,
+	}
+}
+
+func (h *AttachmentHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func (h *AttachmentHandler) can(r *http.Request, perm string) bool {
+	return hasPermission(r, h.HasPermission, h.CurrentRole, perm)
+}
+
+// entityPermission es el permiso que permite gestionar los adjuntos de otros
+// usuarios en la entidad.
+func entityPermission(entity string) string {
+	if entity == models.AttachmentTransmutation {
+		return models.PermTransmutationWrite
+	}
+	return models.PermMissionWrite
+}
+
+func (h *AttachmentHandler) isAlchemist(r *http.Request, id uint) bool {
+	return h.CurrentAlchemist != nil && id != 0 && h.CurrentAlchemist(r) == id
+}
+
+func attachmentURL(a *models.Attachment) string {
+	switch a.Entity {
+	case models.AttachmentAlchemistPhoto:
+		return fmt.Sprintf("/alchemists/%d/photo", a.EntityID)
+	case models.AttachmentTransmutation:
+		return fmt.Sprintf("/transmutations/%d/attachments/%d", a.EntityID, a.ID)
+	default:
+		return fmt.Sprintf("/missions/%d/attachments/%d", a.EntityID, a.ID)
+	}
+}
+
+func attachmentResponse(a *models.Attachment) *api.AttachmentResponseDto {
+	resp := &api.AttachmentResponseDto{
+		ID:           int(a.ID),
+		Entity:       a.Entity,
+		EntityID:     a.EntityID,
+		Filename:     a.Filename,
+		ContentType:  a.ContentType,
+		Size:         a.Size,
+		HasThumbnail: a.ThumbnailKey != "",
+		URL:          attachmentURL(a),
+		UploadedBy:   a.UploadedBy,
+		CreatedAt:    a.CreatedAt.Format(time.RFC3339),
+	}
+	if resp.HasThumbnail {
+		resp.ThumbnailURL = resp.URL + "?thumbnail=true"
+	}
+	return resp
+}
+
+func randomKey() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// errUpload asocia un error de subida con el código HTTP que debe devolverse.
+type errUpload struct {
+	status int
+	err    error
+}
+
+func (e *errUpload) Error() string { return e.err.Error() }
+
+// receive lee el campo "file" del formulario multipart, comprueba su tipo y
+// tamaño, lo guarda en el almacén y genera la miniatura si es una imagen.
+// Devuelve el adjunto sin persistir.
+func (h *AttachmentHandler) receive(w http.ResponseWriter, r *http.Request, entity string, entityID uint, allowed map[string]string) (*models.Attachment, error) {
+	// Margen para las cabeceras multipart; el límite real se aplica al fichero.
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxBytes+64<<10)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, &errUpload{http.StatusBadRequest, err}
+	}
+	var part io.Reader
+	var filename string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, &errUpload{http.StatusBadRequest, errors.New(`multipart field "file" is required`)}
+		}
+		if err != nil {
+			return nil, &errUpload{http.StatusBadRequest, err}
+		}
+		if p.FormName() == "file" {
+			part, filename = p, filepath.Base(p.FileName())
+			break
+		}
+	}
+	if filename == "" || filename == "." || filename == string(filepath.Separator) {
+		filename = "file"
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, &errUpload{http.StatusBadRequest, err}
+	}
+	if n == 0 {
+		return nil, &errUpload{http.StatusBadRequest, errors.New("empty file")}
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	ext, ok := allowed[contentType]
+	if !ok {
+		return nil, &errUpload{http.StatusUnsupportedMediaType, fmt.Errorf("content type %q is not allowed", contentType)}
+	}
+
+	name, err := randomKey()
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s/%d/%s%s", entity, entityID, name, ext)
+	limited := &io.LimitedReader{R: io.MultiReader(bytes.NewReader(head), part), N: h.MaxBytes + 1}
+	size, err := h.Store.Put(key, limited)
+	if err == nil && size > h.MaxBytes {
+		err = &errUpload{http.StatusRequestEntityTooLarge, fmt.Errorf("file exceeds the %d byte limit", h.MaxBytes)}
+	}
+	if err != nil {
+		h.Store.Delete(key)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = &errUpload{http.StatusRequestEntityTooLarge, fmt.Errorf("file exceeds the %d byte limit", h.MaxBytes)}
+		}
+		return nil, err
+	}
+
+	a := &models.Attachment{
+		Entity:      entity,
+		EntityID:    entityID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  key,
+		UploadedBy:  h.userEmail(r),
+	}
+	if _, isImage := imageContentTypes[contentType]; isImage {
+		thumbKey, err := h.thumbnail(key)
+		if err != nil && entity == models.AttachmentAlchemistPhoto {
+			h.Store.Delete(key)
+			return nil, &errUpload{http.StatusUnprocessableEntity, fmt.Errorf("invalid image: %w", err)}
+		}
+		a.ThumbnailKey = thumbKey
+	}
+	return a, nil
+}
+
+// thumbnail genera la miniatura JPEG del blob indicado.
+func (h *AttachmentHandler) thumbnail(key string) (string, error) {
+	rc, err := h.Store.Open(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return "", err
+	}
+	img, _, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := imaging.EncodeJPEG(&buf, imaging.Thumbnail(img, thumbnailSize)); err != nil {
+		return "", err
+	}
+	thumbKey := key + ".thumb.jpg"
+	if _, err := h.Store.Put(thumbKey, &buf); err != nil {
+		return "", err
+	}
+	return thumbKey, nil
+}
+
+func (h *AttachmentHandler) removeBlobs(a *models.Attachment) error {
+	return removeAttachmentBlobs(h.Store, a)
+}
+
+// removeAttachmentBlobs borra la miniatura y el fichero de un adjunto.
+func removeAttachmentBlobs(store storage.BlobStore, a *models.Attachment) error {
+	if a.ThumbnailKey != "" {
+		if err := store.Delete(a.ThumbnailKey); err != nil {
+			return err
+		}
+	}
+	return store.Delete(a.StorageKey)
+}
+
+func (h *AttachmentHandler) uploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var ue *errUpload
+	if errors.As(err, &ue) {
+		h.HandleErr(w, ue.status, r.URL.Path, ue.err)
+		return
+	}
+	h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+}
+
+// serve envía el blob (o su miniatura con ?thumbnail=true).
+func (h *AttachmentHandler) serve(w http.ResponseWriter, r *http.Request, a *models.Attachment, start time.Time) {
+	key, contentType, filename := a.StorageKey, a.ContentType, a.Filename
+	if r.URL.Query().Get("thumbnail") == "true" {
+		if a.ThumbnailKey == "" {
+			h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("attachment has no thumbnail"))
+			return
+		}
+		key, contentType = a.ThumbnailKey, "image/jpeg"
+		filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + "-thumb.jpg"
+	}
+	rc, err := h.Store.Open(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("attachment content not found"))
+		return
+	}
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	defer rc.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	io.Copy(w, rc)
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// ===================== Foto de perfil =====================
+
+func (h *AttachmentHandler) alchemist(w http.ResponseWriter, r *http.Request) *models.Alchemist {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	a, err := h.AlchemistRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return nil
+	}
+	return a
+}
+
+func (h *AttachmentHandler) currentPhoto(alchemistID uint) (*models.Attachment, error) {
+	photos, err := h.Repo.FindByEntity(models.AttachmentAlchemistPhoto, alchemistID)
+	if err != nil || len(photos) == 0 {
+		return nil, err
+	}
+	return photos[len(photos)-1], nil
+}
+
+// PUT /alchemists/{id}/photo (multipart, campo "file")
+func (h *AttachmentHandler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	alch := h.alchemist(w, r)
+	if alch == nil {
+		return
+	}
+	if !h.can(r, models.PermAlchemistWrite) && !h.isAlchemist(r, alch.ID) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("alchemists can only change their own photo"))
+		return
+	}
+	previous, err := h.Repo.FindByEntity(models.AttachmentAlchemistPhoto, alch.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+
+	a, err := h.receive(w, r, models.AttachmentAlchemistPhoto, alch.ID, imageContentTypes)
+	if err != nil {
+		h.uploadError(w, r, err)
+		return
+	}
+	if a, err = h.Repo.Save(a); err != nil {
+		h.removeBlobs(a)
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	for _, p := range previous {
+		if err := h.removeBlobs(p); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+			continue
+		}
+		if err := h.Repo.Delete(p); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("upload_photo", "alchemist", alch.ID, h.userEmail(r), "Nueva foto de perfil"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": attachmentResponse(a)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// GET /alchemists/{id}/photo[?thumbnail=true]
+func (h *AttachmentHandler) GetPhoto(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	alch := h.alchemist(w, r)
+	if alch == nil {
+		return
+	}
+	photo, err := h.currentPhoto(alch.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if photo == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist has no photo"))
+		return
+	}
+	h.serve(w, r, photo, start)
+}
+
+// DELETE /alchemists/{id}/photo
+func (h *AttachmentHandler) DeletePhoto(w http.ResponseWriter, r *http.Request) {
+	alch := h.alchemist(w, r)
+	if alch == nil {
+		return
+	}
+	if !h.can(r, models.PermAlchemistWrite) && !h.isAlchemist(r, alch.ID) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("alchemists can only change their own photo"))
+		return
+	}
+	photos, err := h.Repo.FindByEntity(models.AttachmentAlchemistPhoto, alch.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if len(photos) == 0 {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist has no photo"))
+		return
+	}
+	for _, p := range photos {
+		if err := h.removeBlobs(p); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if err := h.Repo.Delete(p); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete_photo", "alchemist", alch.ID, h.userEmail(r), "Foto de perfil eliminada"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ===================== Adjuntos de misiones y transmutaciones =====================
+
+// owner resuelve la misión o transmutación de la ruta. Devuelve el ID y el
+// alquimista responsable (0 si no tiene).
+func (h *AttachmentHandler) owner(w http.ResponseWriter, r *http.Request, entity string) (uint, uint, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return 0, 0, false
+	}
+	var alchemistID uint
+	found := false
+	switch entity {
+	case models.AttachmentMission:
+		m, ferr := h.MissionRepo.FindById(id)
+		if err = ferr; m != nil {
+			found, alchemistID = true, m.AssignedTo
+		}
+	case models.AttachmentTransmutation:
+		t, ferr := h.TransmutationRepo.FindById(id)
+		if err = ferr; t != nil {
+			found, alchemistID = true, t.AlchemistID
+		}
+	}
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return 0, 0, false
+	}
+	if !found {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, fmt.Errorf("%s not found", entity))
+		return 0, 0, false
+	}
+	return uint(id), alchemistID, true
+}
+
+func (h *AttachmentHandler) attachment(w http.ResponseWriter, r *http.Request, entity string, entityID uint) *models.Attachment {
+	id, err := strconv.Atoi(mux.Vars(r)["attachmentId"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	a, err := h.Repo.FindById(entity, entityID, id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("attachment not found"))
+		return nil
+	}
+	return a
+}
+
+func (h *AttachmentHandler) list(w http.ResponseWriter, r *http.Request, entity string) {
+	start := time.Now()
+	id, _, ok := h.owner(w, r, entity)
+	if !ok {
+		return
+	}
+	as, err := h.Repo.FindByEntity(entity, id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.AttachmentResponseDto, 0, len(as))
+	for _, a := range as {
+		resp = append(resp, attachmentResponse(a))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+func (h *AttachmentHandler) upload(w http.ResponseWriter, r *http.Request, entity string) {
+	start := time.Now()
+	id, alchemistID, ok := h.owner(w, r, entity)
+	if !ok {
+		return
+	}
+	// En las transmutaciones solo adjunta el alquimista que la realiza o un
+	// supervisor; las misiones admiten adjuntos de cualquier alquimista.
+	if entity == models.AttachmentTransmutation && !h.can(r, models.PermTransmutationWrite) && !h.isAlchemist(r, alchemistID) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("only the transmuting alchemist or a supervisor can attach files"))
+		return
+	}
+
+	a, err := h.receive(w, r, entity, id, documentContentTypes)
+	if err != nil {
+		h.uploadError(w, r, err)
+		return
+	}
+	if a, err = h.Repo.Save(a); err != nil {
+		h.removeBlobs(a)
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("upload_attachment", entity, id, h.userEmail(r), fmt.Sprintf("Adjunto %q (%d bytes)", a.Filename, a.Size)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": attachmentResponse(a)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+func (h *AttachmentHandler) download(w http.ResponseWriter, r *http.Request, entity string) {
+	start := time.Now()
+	id, _, ok := h.owner(w, r, entity)
+	if !ok {
+		return
+	}
+	a := h.attachment(w, r, entity, id)
+	if a == nil {
+		return
+	}
+	h.serve(w, r, a, start)
+}
+
+func (h *AttachmentHandler) remove(w http.ResponseWriter, r *http.Request, entity string) {
+	id, _, ok := h.owner(w, r, entity)
+	if !ok {
+		return
+	}
+	a := h.attachment(w, r, entity, id)
+	if a == nil {
+		return
+	}
+	if !h.can(r, entityPermission(entity)) && a.UploadedBy != h.userEmail(r) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("only the uploader or a supervisor can delete this attachment"))
+		return
+	}
+	if err := h.removeBlobs(a); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if err := h.Repo.Delete(a); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete_attachment", entity, id, h.userEmail(r), fmt.Sprintf("Adjunto %q eliminado", a.Filename)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /missions/{id}/attachments
+func (h *AttachmentHandler) ListMission(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, models.AttachmentMission)
+}
+
+// POST /missions/{id}/attachments (multipart, campo "file")
+func (h *AttachmentHandler) UploadMission(w http.ResponseWriter, r *http.Request) {
+	h.upload(w, r, models.AttachmentMission)
+}
+
+// GET /missions/{id}/attachments/{attachmentId}[?thumbnail=true]
+func (h *AttachmentHandler) DownloadMission(w http.ResponseWriter, r *http.Request) {
+	h.download(w, r, models.AttachmentMission)
+}
+
+// DELETE /missions/{id}/attachments/{attachmentId}
+func (h *AttachmentHandler) DeleteMission(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, models.AttachmentMission)
+}
+
+// GET /transmutations/{id}/attachments
+func (h *AttachmentHandler) ListTransmutation(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, models.AttachmentTransmutation)
+}
+
+// POST /transmutations/{id}/attachments (multipart, campo "file")
+func (h *AttachmentHandler) UploadTransmutation(w http.ResponseWriter, r *http.Request) {
+	h.upload(w, r, models.AttachmentTransmutation)
+}
+
+// GET /transmutations/{id}/attachments/{attachmentId}[?thumbnail=true]
+func (h *AttachmentHandler) DownloadTransmutation(w http.ResponseWriter, r *http.Request) {
+	h.download(w, r, models.AttachmentTransmutation)
+}
+
+// DELETE /transmutations/{id}/attachments/{attachmentId}
+func (h *AttachmentHandler) DeleteTransmutation(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, models.AttachmentTransmutation)
+}
@@ -91,8 +91,7 @@ func (h *AuditHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 func (h *AuditHandler) Create(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	var req api.AuditRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleErr) {
 		return
 	}
 
@@ -144,8 +143,7 @@ func (h *AuditHandler) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req api.AuditEditRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleErr) {
 		return
 	}
 
@@ -3,10 +3,16 @@ package handlers
 import (
 	"backend-avanzada/api"
 	"backend-avanzada/models"
+	"backend-avanzada/oidc"
 	"backend-avanzada/repository"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -16,41 +22,146 @@ import (
 // Estructura que representa los claims del token JWT.
 // Se define localmente para no depender del paquete server.
 type AuthClaims struct {
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	AlchemistID uint   `json:"alchemist_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
+const (
+	DefaultAccessTokenTTL  = 2 * time.Hour
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// TokenRevoker invalida tokens de acceso antes de que caduquen.
+type TokenRevoker interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+}
+
+// TokenSigner firma los tokens de acceso (p. ej. con la clave activa de un
+// keyring.Keyring).
+type TokenSigner interface {
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// LoginThrottle limita los intentos de login fallidos por email y por IP.
+type LoginThrottle interface {
+	// Check devuelve cuánto falta para poder volver a intentarlo (0 = ya).
+	Check(ctx context.Context, email, ip string) (time.Duration, error)
+	Failure(ctx context.Context, email, ip string) (*LoginFailure, error)
+	Success(ctx context.Context, email, ip string) error
+}
+
+// LoginFailure resume el estado tras registrar un intento fallido.
+type LoginFailure struct {
+	Attempts    int64         // Fallos recientes de ese email
+	IPAttempts  int64         // Fallos recientes desde esa IP
+	RetryAfter  time.Duration // Espera impuesta antes del siguiente intento
+	LockedOut   bool          // Este fallo ha bloqueado la cuenta
+	IPLockedOut bool          // Este fallo ha bloqueado la IP
+}
+
 // AuthHandler gestiona login y registro de usuarios.
 type AuthHandler struct {
-	UserRepository repository.UserRepository
-	Logger         func(status int, path string, start time.Time)
-	HandleError    func(w http.ResponseWriter, statusCode int, path string, cause error)
-	JWTSecret      string
+	UserRepository      repository.UserRepository
+	AlchemistRepository *repository.AlchemistRepository
+	Logger              func(status int, path string, start time.Time)
+	HandleError         func(w http.ResponseWriter, statusCode int, path string, cause error)
+	JWTSecret           string
+	// Signer firma los tokens de acceso; sin él se usa HS256 con JWTSecret.
+	Signer TokenSigner
+	// Invitaciones para registrar roles distintos del básico; sin repositorio
+	// solo se pueden registrar alquimistas.
+	InvitationRepository *repository.InvitationRepository
+	// Sesiones: tokens de refresco rotatorios y revocación de tokens de acceso.
+	// Sin repositorio el login solo emite el token de acceso.
+	RefreshTokens *repository.RefreshTokenRepository
+	Revoker       TokenRevoker
+	CurrentUser   func(*http.Request) string
+	CurrentToken  func(*http.Request) (jti string, expiresAt time.Time)
+	AccessTTL     time.Duration
+	RefreshTTL    time.Duration
+	// Verificación de email: OnRegister se llama con cada cuenta nueva (p. ej.
+	// para enviar el enlace) y RequireVerifiedEmail bloquea el login hasta
+	// confirmar la dirección.
+	OnRegister           func(*http.Request, *models.User)
+	RequireVerifiedEmail bool
+	// Protección frente a fuerza bruta; los bloqueos se auditan con Dispatcher.
+	Throttle         LoginThrottle
+	ClientIP         func(*http.Request) string
+	Dispatcher       AsyncDispatcher
+	ReportAsyncError func(string, error)
+	// Doble factor (TOTP); sin repositorio de códigos de recuperación no se
+	// ofrece. RequireSupervisorTwoFactor lo hace obligatorio para supervisores y
+	// administradores.
+	RecoveryCodes              *repository.RecoveryCodeRepository
+	RequireSupervisorTwoFactor bool
+	// Login con un proveedor OpenID Connect (nil = deshabilitado). El rol sale
+	// de la claim OIDCRoleClaim según OIDCRoleMappings; a quien no encaje en
+	// ninguna se le da OIDCDefaultRole o, si está vacío, se le rechaza. Con
+	// RoleRepository el rol resultante tiene que existir; sin él, ser uno de
+	// los roles por defecto.
+	OIDC             *oidc.Provider
+	OIDCRoleClaim    string
+	OIDCRoleMappings []OIDCRoleMapping
+	OIDCDefaultRole  string
+	RoleRepository   *repository.RoleRepository
+	// OIDCPostLoginURL recibe al navegador tras el login, con los tokens en el
+	// fragmento; vacío, el callback responde en JSON.
+	OIDCPostLoginURL string
 }
 
 // Constructor del handler (inyección de dependencias)
-func NewAuthHandler(jwtSecret string, ur repository.UserRepository,
+func NewAuthHandler(jwtSecret string, ur repository.UserRepository, ar *repository.AlchemistRepository,
 	handleError func(w http.ResponseWriter, statusCode int, path string, cause error),
 	logger func(status int, path string, start time.Time)) *AuthHandler {
 
 	return &AuthHandler{
-		UserRepository: ur,
-		JWTSecret:      jwtSecret,
-		HandleError:    handleError,
-		Logger:         logger,
+		UserRepository:      ur,
+		AlchemistRepository: ar,
+		JWTSecret:           jwtSecret,
+		HandleError:         handleError,
+		Logger:              logger,
+		AccessTTL:           DefaultAccessTokenTTL,
+		RefreshTTL:          DefaultRefreshTokenTTL,
 	}
 }
 
-// Registro de nuevo usuario
+// Registro de nuevo usuario. Sin invitación solo se crean cuentas de
+// alquimista; el rol de una cuenta invitada lo fija el código.
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req api.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleError(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleError) {
 		return
 	}
-	if req.Email == "" || req.Password == "" || req.Role == "" {
-		h.HandleError(w, http.StatusBadRequest, r.URL.Path, errors.New("email, password and role are required"))
+
+	role := "alchemist"
+	var inv *models.Invitation
+	if req.InviteCode != "" {
+		if h.InvitationRepository == nil {
+			h.HandleError(w, http.StatusForbidden, r.URL.Path, errInvalidInvitation)
+			return
+		}
+		var err error
+		inv, err = redeemableInvitation(h.InvitationRepository, h.JWTSecret, req.InviteCode, req.Email)
+		if err != nil {
+			status := http.StatusForbidden
+			if !errors.Is(err, errInvalidInvitation) && !errors.Is(err, errInvitationEmail) {
+				status = http.StatusInternalServerError
+			}
+			h.HandleError(w, status, r.URL.Path, err)
+			return
+		}
+		role = inv.Role
+	}
+	if req.Role != "" && req.Role != role {
+		err := fmt.Errorf("registering as %s requires an invitation for that role", req.Role)
+		if inv != nil {
+			err = fmt.Errorf("invitation code is for the %s role", role)
+		}
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, err)
 		return
 	}
 
@@ -70,15 +181,60 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// La invitación se reserva antes de crear la cuenta para que dos registros
+	// simultáneos no puedan usar el mismo código.
+	if inv != nil {
+		claimed, err := h.InvitationRepository.Claim(inv.ID, req.Email, time.Now())
+		if err != nil {
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if !claimed {
+			h.HandleError(w, http.StatusForbidden, r.URL.Path, errInvalidInvitation)
+			return
+		}
+	}
+	release := func() {
+		if inv != nil {
+			h.InvitationRepository.Release(inv.ID)
+		}
+	}
+
+	// Si se envían datos de perfil se crea el alquimista y se vincula al usuario.
+	var alchemist *models.Alchemist
+	if req.Alchemist != nil && h.AlchemistRepository != nil {
+		alchemist, err = h.AlchemistRepository.Save(&models.Alchemist{
+			Name:      req.Alchemist.Name,
+			Age:       int(req.Alchemist.Age),
+			Specialty: req.Alchemist.Specialty,
+			Rank:      models.RankLadder[0].Name,
+		})
+		if err != nil {
+			release()
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+	}
+
 	u := &models.User{
 		Email:        req.Email,
 		PasswordHash: string(hash),
-		Role:         req.Role,
+		Role:         role,
+	}
+	if alchemist != nil {
+		u.AlchemistID = &alchemist.ID
 	}
 	if _, err := h.UserRepository.Save(u); err != nil {
+		if alchemist != nil {
+			h.AlchemistRepository.Delete(alchemist)
+		}
+		release()
 		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
 	}
+	if h.OnRegister != nil {
+		h.OnRegister(r, u)
+	}
 
 	w.WriteHeader(http.StatusCreated)
 }
@@ -86,45 +242,335 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // Inicio de sesión
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req api.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleError(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleError) {
 		return
 	}
 
+	ip := ""
+	if h.ClientIP != nil {
+		ip = h.ClientIP(r)
+	}
+	if h.Throttle != nil {
+		wait, err := h.Throttle.Check(r.Context(), req.Email, ip)
+		if err != nil {
+			h.HandleError(w, http.StatusServiceUnavailable, r.URL.Path, err)
+			return
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			h.HandleError(w, http.StatusTooManyRequests, r.URL.Path, errors.New("too many failed login attempts; try again later"))
+			return
+		}
+	}
+
 	u, err := h.UserRepository.FindByEmail(req.Email)
 	if err != nil {
 		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
 	}
-	if u == nil {
-		h.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("invalid credentials"))
+	if u == nil || bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)) != nil {
+		h.loginFailed(w, r, u, req.Email, ip, errors.New("invalid credentials"))
+		return
+	}
+	if err := h.accountBlocked(u); err != nil {
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, err)
 		return
 	}
+	// Con doble factor los fallos se siguen contando hasta que llegue un
+	// código válido en /auth/login/2fa.
+	if h.RecoveryCodes != nil && (u.TwoFactorEnabled() || h.twoFactorRequired(u)) {
+		h.mfaChallenge(w, r, u)
+		return
+	}
+	if h.Throttle != nil {
+		if err := h.Throttle.Success(r.Context(), req.Email, ip); err != nil {
+			h.HandleError(w, http.StatusServiceUnavailable, r.URL.Path, err)
+			return
+		}
+	}
+
+	resp, err := h.issueTokens(u, "")
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// loginFailed registra el intento fallido, audita los bloqueos y responde 401
+// con cause, indicando en Retry-After la espera impuesta, si la hay.
+// accountBlocked devuelve por qué la cuenta no puede abrir ni renovar una
+// sesión, o nil si puede.
+func (h *AuthHandler) accountBlocked(u *models.User) error {
+	if u.Disabled() {
+		return errors.New("account is disabled")
+	}
+	if u.PasswordResetRequired {
+		return errors.New("password reset required; check your email")
+	}
+	if h.RequireVerifiedEmail && u.EmailVerifiedAt == nil {
+		return errors.New("email address is not verified")
+	}
+	return nil
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)); err != nil {
-		h.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("invalid credentials"))
+func (h *AuthHandler) loginFailed(w http.ResponseWriter, r *http.Request, u *models.User, email, ip string, cause error) {
+	if h.Throttle != nil {
+		f, err := h.Throttle.Failure(r.Context(), email, ip)
+		if err != nil {
+			h.HandleError(w, http.StatusServiceUnavailable, r.URL.Path, err)
+			return
+		}
+		var userID uint
+		if u != nil {
+			userID = u.ID
+		}
+		if f.LockedOut {
+			h.audit(r, "lockout", "user", userID, email,
+				fmt.Sprintf("Cuenta bloqueada %s tras %d intentos de login fallidos (último desde %s)", durationText(f.RetryAfter), f.Attempts, ip))
+		}
+		if f.IPLockedOut {
+			h.audit(r, "suspicious_login", "ip", 0, "",
+				fmt.Sprintf("IP %s bloqueada %s tras %d intentos de login fallidos (último contra %s)", ip, durationText(f.RetryAfter), f.IPAttempts, email))
+		}
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", retryAfterSeconds(f.RetryAfter))
+		}
+	}
+	h.HandleError(w, http.StatusUnauthorized, r.URL.Path, cause)
+}
+
+func (h *AuthHandler) audit(r *http.Request, action, entity string, id uint, email, details string) {
+	if h.Dispatcher == nil {
 		return
 	}
+	if err := h.Dispatcher.EnqueueAudit(action, entity, id, email, details); err != nil && h.ReportAsyncError != nil {
+		h.ReportAsyncError(r.URL.Path, err)
+	}
+}
+
+// retryAfterSeconds redondea hacia arriba para que el cliente no reintente
+// antes de tiempo.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
 
+// issueTokens firma un token de acceso con jti propio y, si hay repositorio,
+// un token de refresco de la familia indicada ("" = nueva sesión).
+func (h *AuthHandler) issueTokens(u *models.User, familyID string) (*api.AuthResponse, error) {
+	jti, err := randomKey()
+	if err != nil {
+		return nil, err
+	}
 	now := time.Now()
+	expiresAt := now.Add(h.AccessTTL)
 	claims := &AuthClaims{
 		Email: u.Email,
 		Role:  u.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(2 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			Issuer:    "alchemist-system",
 		},
 	}
+	if u.AlchemistID != nil {
+		claims.AlchemistID = *u.AlchemistID
+	}
+	var token string
+	if h.Signer != nil {
+		token, err = h.Signer.Sign(claims)
+	} else {
+		token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.JWTSecret))
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp := &api.AuthResponse{Token: token, ExpiresIn: int(h.AccessTTL / time.Second)}
+	if h.RefreshTokens == nil {
+		return resp, nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(raw)
+	if familyID == "" {
+		if familyID, err = randomKey(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := h.RefreshTokens.Save(&models.RefreshToken{
+		UserID:          u.ID,
+		TokenHash:       hashToken(refresh),
+		FamilyID:        familyID,
+		ExpiresAt:       now.Add(h.RefreshTTL),
+		AccessJTI:       jti,
+		AccessExpiresAt: expiresAt,
+	}); err != nil {
+		return nil, err
+	}
+	resp.RefreshToken = refresh
+	return resp, nil
+}
+
+// revokeFamily cierra la sesión completa: sus tokens de refresco y los tokens
+// de acceso que emitieron y siguen vigentes.
+func (h *AuthHandler) revokeFamily(ctx context.Context, familyID string) error {
+	live, err := h.RefreshTokens.RevokeFamily(familyID, time.Now())
+	if err != nil {
+		return err
+	}
+	return h.revokeAccess(ctx, live)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(h.JWTSecret))
+// revokeAccess revoca los tokens de acceso emitidos junto a los tokens de
+// refresco indicados.
+func (h *AuthHandler) revokeAccess(ctx context.Context, live []*models.RefreshToken) error {
+	if h.Revoker == nil {
+		return nil
+	}
+	for _, t := range live {
+		if err := h.Revoker.Revoke(ctx, t.AccessJTI, t.AccessExpiresAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeUserSessions cierra todas las sesiones del usuario, p. ej. tras
+// restablecer su contraseña.
+func (h *AuthHandler) RevokeUserSessions(ctx context.Context, userID uint) error {
+	if h.RefreshTokens == nil {
+		return nil
+	}
+	live, err := h.RefreshTokens.RevokeUser(userID, time.Now())
+	if err != nil {
+		return err
+	}
+	return h.revokeAccess(ctx, live)
+}
+
+// POST /auth/refresh cambia un token de refresco por un par nuevo. Cada token
+// de refresco sirve una sola vez; si se presenta uno ya rotado se asume que
+// ha sido robado y se revoca toda la sesión.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req api.RefreshRequest
+	if !decodeRequest(w, r, &req, h.HandleError) {
+		return
+	}
+	t, err := h.RefreshTokens.FindByHash(hashToken(req.RefreshToken))
 	if err != nil {
 		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
 	}
+	if t == nil || t.RevokedAt != nil {
+		h.HandleError(w, http.StatusUnauthorized, r.URL.Path, errInvalidRefreshToken)
+		return
+	}
+	reused := t.UsedAt != nil
+	if !reused {
+		if time.Now().After(t.ExpiresAt) {
+			h.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("refresh token expired"))
+			return
+		}
+		claimed, err := h.RefreshTokens.MarkUsed(t.ID, time.Now())
+		if err != nil {
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		reused = !claimed
+	}
+	if reused {
+		if err := h.revokeFamily(r.Context(), t.FamilyID); err != nil {
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		h.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("refresh token reuse detected; session revoked"))
+		return
+	}
 
-	resp := api.AuthResponse{Token: tokenString}
+	u, err := h.UserRepository.FindByID(t.UserID)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	// La cuenta se comprueba igual que en Login; si ya no puede entrar se
+	// cierra la sesión completa.
+	if u == nil {
+		if err := h.revokeFamily(r.Context(), t.FamilyID); err != nil {
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		h.HandleError(w, http.StatusUnauthorized, r.URL.Path, errInvalidRefreshToken)
+		return
+	}
+	if blocked := h.accountBlocked(u); blocked != nil {
+		if err := h.revokeFamily(r.Context(), t.FamilyID); err != nil {
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, blocked)
+		return
+	}
+	// Las sesiones abiertas antes de exigir el doble factor no se renuevan:
+	// hay que volver a entrar y darlo de alta.
+	if h.RecoveryCodes != nil && h.twoFactorRequired(u) && !u.TwoFactorEnabled() {
+		if err := h.revokeFamily(r.Context(), t.FamilyID); err != nil {
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		h.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("two-factor authentication setup required; log in again"))
+		return
+	}
+	resp, err := h.issueTokens(u, t.FamilyID)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// POST /auth/logout revoca el token de acceso actual y, si se envía, la
+// sesión de refresco asociada.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req api.LogoutRequest
+	if r.ContentLength != 0 && !decodeRequest(w, r, &req, h.HandleError) {
+		return
+	}
+
+	if req.RefreshToken != "" && h.RefreshTokens != nil {
+		t, err := h.RefreshTokens.FindByHash(hashToken(req.RefreshToken))
+		if err != nil {
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if t != nil {
+			u, err := h.UserRepository.FindByID(t.UserID)
+			if err != nil {
+				h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			if u == nil || h.CurrentUser == nil || u.Email != h.CurrentUser(r) {
+				h.HandleError(w, http.StatusForbidden, r.URL.Path, errors.New("refresh token belongs to another user"))
+				return
+			}
+			if err := h.revokeFamily(r.Context(), t.FamilyID); err != nil {
+				h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+		}
+	}
+
+	if h.Revoker != nil && h.CurrentToken != nil {
+		jti, expiresAt := h.CurrentToken(r)
+		if err := h.Revoker.Revoke(r.Context(), jti, expiresAt); err != nil {
+			h.HandleError(w, http.StatusServiceUnavailable, r.URL.Path, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshRechecksAccount(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.RefreshToken{})
+	now := time.Now()
+	cases := []struct {
+		name       string
+		block      func(*models.User)
+		wantStatus int
+	}{
+		{"cuenta en regla", func(*models.User) {}, http.StatusOK},
+		{"cuenta desactivada", func(u *models.User) { u.DisabledAt = &now }, http.StatusForbidden},
+		{"restablecimiento pendiente", func(u *models.User) { u.PasswordResetRequired = true }, http.StatusForbidden},
+		{"email sin verificar", func(u *models.User) { u.EmailVerifiedAt = nil }, http.StatusForbidden},
+	}
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			u := &models.User{Email: strings.Repeat("x", i+1) + "@example.com", PasswordHash: "x", Role: "alchemist", EmailVerifiedAt: &now}
+			create(t, db, u)
+			h := &AuthHandler{
+				UserRepository:       repository.NewUserRepository(db),
+				RefreshTokens:        repository.NewRefreshTokenRepository(db),
+				JWTSecret:            "secret",
+				AccessTTL:            time.Minute,
+				RefreshTTL:           time.Hour,
+				RequireVerifiedEmail: true,
+				HandleError:          func(w http.ResponseWriter, status int, _ string, err error) { http.Error(w, err.Error(), status) },
+			}
+			// Dos sesiones: solo se cierra la que intenta renovarse.
+			session, err := h.issueTokens(u, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := h.issueTokens(u, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.block(u)
+			if err := db.Save(u).Error; err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token":"`+session.RefreshToken+`"}`))
+			w := httptest.NewRecorder()
+			h.Refresh(w, r)
+			if w.Code != c.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, c.wantStatus, w.Body)
+			}
+
+			revoked := func(raw string) bool {
+				tok, err := h.RefreshTokens.FindByHash(hashToken(raw))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return tok.RevokedAt != nil
+			}
+			if got, want := revoked(session.RefreshToken), c.wantStatus != http.StatusOK; got != want {
+				t.Errorf("session revoked = %v, want %v", got, want)
+			}
+			if revoked(other.RefreshToken) {
+				t.Error("unrelated session revoked")
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// AvailabilityHandler gestiona las ventanas de indisponibilidad (permisos,
+// formación, despliegues) de los alquimistas.
+type AvailabilityHandler struct {
+	Repo             *repository.AvailabilityRepository
+	AlchemistRepo    *repository.AlchemistRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	CurrentRole      func(*http.Request) string
+	CurrentAlchemist func(*http.Request) uint
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+	// HasPermission resuelve permisos del usuario o de la clave de API.
+	HasPermission func(*http.Request, string) bool
+}
+
+func NewAvailabilityHandler(
+	repo *repository.AvailabilityRepository,
+	alchemistRepo *repository.AlchemistRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	currentRole func(*http.Request) string,
+	currentAlchemist func(*http.Request) uint,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *AvailabilityHandler {
+	return &AvailabilityHandler{
+		Repo:             repo,
+		AlchemistRepo:    alchemistRepo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		CurrentRole:      currentRole,
+		CurrentAlchemist: currentAlchemist,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *AvailabilityHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+// canManage permite gestionar la disponibilidad de cualquier alquimista con
+// alchemist:write y, si no, solo la propia.
+func (h *AvailabilityHandler) canManage(r *http.Request, alchemistID uint) bool {
+	if hasPermission(r, h.HasPermission, h.CurrentRole, models.PermAlchemistWrite) {
+		return true
+	}
+	return h.CurrentAlchemist != nil && h.CurrentAlchemist(r) == alchemistID
+}
+
+func availabilityResponse(w *models.AvailabilityWindow) *api.AvailabilityWindowResponseDto {
+	return &api.AvailabilityWindowResponseDto{
+		ID:          int(w.ID),
+		AlchemistID: w.AlchemistID,
+		Kind:        w.Kind,
+		StartsAt:    w.StartsAt.Format(time.RFC3339),
+		EndsAt:      w.EndsAt.Format(time.RFC3339),
+		Notes:       w.Notes,
+		CreatedBy:   w.CreatedBy,
+		CreatedAt:   w.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// describeWindows formatea ventanas de indisponibilidad para mensajes de error.
+func describeWindows(ws []*models.AvailabilityWindow) string {
+	parts := make([]string, 0, len(ws))
+	for _, w := range ws {
+		parts = append(parts, fmt.Sprintf("%s %s–%s", w.Kind, w.StartsAt.Format(time.RFC3339), w.EndsAt.Format(time.RFC3339)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseRange lee los parámetros from/to (RFC3339). Si requireBoth es true
+// ambos son obligatorios.
+func parseRange(r *http.Request, requireBoth bool) (time.Time, time.Time, error) {
+	var from, to time.Time
+	q := r.URL.Query()
+	if requireBoth && (q.Get("from") == "" || q.Get("to") == "") {
+		return from, to, errors.New("from and to are required")
+	}
+	if raw := q.Get("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from: %w", err)
+		}
+		from = t
+	}
+	if raw := q.Get("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to: %w", err)
+		}
+		to = t
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return from, to, errors.New("to must not be before from")
+	}
+	return from, to, nil
+}
+
+func (h *AvailabilityHandler) alchemist(w http.ResponseWriter, r *http.Request) *models.Alchemist {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	a, err := h.AlchemistRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return nil
+	}
+	return a
+}
+
+// GET /alchemists/available?from=&to=
+func (h *AvailabilityHandler) Available(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	from, to, err := parseRange(r, true)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	alchs, err := h.Repo.FindAvailableAlchemists(from, to)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.AlchemistResponseDto, 0, len(alchs))
+	for _, a := range alchs {
+		resp = append(resp, alchemistResponse(a))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /alchemists/{id}/availability[?from=&to=]
+func (h *AvailabilityHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	from, to, err := parseRange(r, false)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	ws, err := h.Repo.FindByAlchemist(a.ID, from, to)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.AvailabilityWindowResponseDto, 0, len(ws))
+	for _, win := range ws {
+		resp = append(resp, availabilityResponse(win))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /alchemists/{id}/availability registra una ventana de indisponibilidad.
+// Los supervisores pueden hacerlo para cualquiera; cada alquimista, para sí.
+func (h *AvailabilityHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	if !h.canManage(r, a.ID) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("alchemists can only manage their own availability"))
+		return
+	}
+
+	var req api.AvailabilityWindowRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	startsAt, err := time.Parse(time.RFC3339, req.StartsAt)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("invalid starts_at: %w", err))
+		return
+	}
+	endsAt, err := time.Parse(time.RFC3339, req.EndsAt)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("invalid ends_at: %w", err))
+		return
+	}
+	overlapping, err := h.Repo.FindOverlapping(a.ID, startsAt, endsAt)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	for _, o := range overlapping {
+		// Ventanas contiguas (una termina cuando empieza la otra) no chocan.
+		if o.StartsAt.Before(endsAt) {
+			h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("window overlaps existing availability: %s", describeWindows([]*models.AvailabilityWindow{o})))
+			return
+		}
+	}
+
+	win := &models.AvailabilityWindow{
+		AlchemistID: a.ID,
+		Kind:        req.Kind,
+		StartsAt:    startsAt,
+		EndsAt:      endsAt,
+		Notes:       req.Notes,
+		CreatedBy:   h.userEmail(r),
+	}
+	if win, err = h.Repo.Save(win); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("create", "availability", win.ID, h.userEmail(r), fmt.Sprintf("Indisponibilidad (%s) del alquimista %d", win.Kind, a.ID)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": availabilityResponse(win)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// DELETE /alchemists/{id}/availability/{windowId}
+func (h *AvailabilityHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	if !h.canManage(r, a.ID) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("alchemists can only manage their own availability"))
+		return
+	}
+	windowID, err := strconv.Atoi(mux.Vars(r)["windowId"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	win, err := h.Repo.FindById(a.ID, windowID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if win == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("availability window not found"))
+		return
+	}
+	if err := h.Repo.Delete(win); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete", "availability", win.ID, h.userEmail(r), "Eliminación de ventana de indisponibilidad"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/ical"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CalendarHandler publica el calendario iCalendar de cada alquimista. Los
+// clientes de calendario no envían cabeceras de autorización, por eso el feed
+// se protege con un token propio en la URL.
+type CalendarHandler struct {
+	Repo              *repository.CalendarFeedRepository
+	AlchemistRepo     *repository.AlchemistRepository
+	MissionRepo       *repository.MissionRepository
+	TransmutationRepo *repository.TransmutationRepository
+	Dispatcher        AsyncDispatcher
+	CurrentUser       func(*http.Request) string
+	ReportAsyncError  func(string, error)
+	HandleErr         func(http.ResponseWriter, int, string, error)
+	Log               func(int, string, time.Time)
+	// Cada alquimista obtiene su propio enlace; con alchemist:write se
+	// gestiona el de cualquiera.
+	CurrentRole      func(*http.Request) string
+	CurrentAlchemist func(*http.Request) uint
+	HasPermission    func(*http.Request, string) bool
+}
+
+func NewCalendarHandler(
+	repo *repository.CalendarFeedRepository,
+	alchemistRepo *repository.AlchemistRepository,
+	missionRepo *repository.MissionRepository,
+	transmutationRepo *repository.TransmutationRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *CalendarHandler {
+	return &CalendarHandler{
+		Repo:              repo,
+		AlchemistRepo:     alchemistRepo,
+		MissionRepo:       missionRepo,
+		TransmutationRepo: transmutationRepo,
+		Dispatcher:        dispatcher,
+		CurrentUser:       currentUser,
+		ReportAsyncError:  reportAsyncError,
+		HandleErr:         handleErr,
+		Log:               log,
+	}
+}
+
+func (h *CalendarHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (h *CalendarHandler) alchemist(w http.ResponseWriter, r *http.Request) *models.Alchemist {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	a, err := h.AlchemistRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return nil
+	}
+	return a
+}
+
+// canManage permite rotar el token de cualquier alquimista con
+// alchemist:write y, si no, solo el propio.
+func (h *CalendarHandler) canManage(r *http.Request, alchemistID uint) bool {
+	if hasPermission(r, h.HasPermission, h.CurrentRole, models.PermAlchemistWrite) {
+		return true
+	}
+	return h.CurrentAlchemist != nil && h.CurrentAlchemist(r) == alchemistID
+}
+
+// POST /alchemists/{id}/calendar-token genera (o rota) el token del feed. El
+// token anterior deja de funcionar.
+func (h *CalendarHandler) RotateToken(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	if !h.canManage(r, a.ID) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("alchemists can only manage their own calendar token"))
+		return
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	feed, err := h.Repo.FindByAlchemist(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if feed == nil {
+		feed = &models.CalendarFeed{AlchemistID: a.ID}
+	}
+	feed.TokenHash = hashToken(token)
+	if _, err := h.Repo.Save(feed); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("rotate_token", "calendar_feed", feed.ID, h.userEmail(r), fmt.Sprintf("Nuevo token de calendario para el alquimista %d", a.ID)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	resp := &api.CalendarTokenResponseDto{
+		AlchemistID: a.ID,
+		Token:       token,
+		URL:         fmt.Sprintf("%s://%s/alchemists/%d/calendar.ics?token=%s", scheme, r.Host, a.ID, token),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// GET /alchemists/{id}/calendar.ics?token=...
+func (h *CalendarHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+
+	feed, err := h.Repo.FindByAlchemist(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if feed == nil || token == "" ||
+		subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(feed.TokenHash)) != 1 {
+		h.HandleErr(w, http.StatusUnauthorized, r.URL.Path, errors.New("invalid calendar token"))
+		return
+	}
+
+	missions, err := h.MissionRepo.FindDueByAssignee(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	transmutations, err := h.TransmutationRepo.FindScheduledByAlchemist(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+
+	// El .ics solo se regenera cuando cambia alguno de los registros que lo
+	// componen; así DTSTAMP y el ETag se mantienen estables entre consultas.
+	version := calendarVersion(a, missions, transmutations)
+	if version != feed.Version || feed.GeneratedAt == nil {
+		now := time.Now().UTC()
+		cal := buildCalendar(a, missions, transmutations)
+		feed.Content = cal.Encode(now)
+		feed.Version = version
+		feed.GeneratedAt = &now
+		if _, err := h.Repo.Save(feed); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+	}
+
+	etag := `"` + feed.Version + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", feed.GeneratedAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		h.Log(http.StatusNotModified, r.URL.Path, start)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="alchemist-%d.ics"`, a.ID))
+	w.Write([]byte(feed.Content))
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+func calendarVersion(a *models.Alchemist, missions []*models.Mission, transmutations []*models.Transmutation) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "a:%d:%d;", a.ID, a.UpdatedAt.UnixNano())
+	for _, m := range missions {
+		fmt.Fprintf(hash, "m:%d:%d;", m.ID, m.UpdatedAt.UnixNano())
+	}
+	for _, t := range transmutations {
+		fmt.Fprintf(hash, "t:%d:%d;", t.ID, t.UpdatedAt.UnixNano())
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func buildCalendar(a *models.Alchemist, missions []*models.Mission, transmutations []*models.Transmutation) *ical.Calendar {
+	cal := &ical.Calendar{
+		ProdID: "-//Alchemist System//Calendario de misiones//ES",
+		Name:   "Misiones de " + a.Name,
+	}
+	for _, m := range missions {
+		status := "CONFIRMED"
+		if m.Status == "cancelada" {
+			status = "CANCELLED"
+		}
+		cal.Events = append(cal.Events, ical.Event{
+			UID:          fmt.Sprintf("mission-%d@alchemist-system", m.ID),
+			Summary:      "Fecha límite: " + m.Title,
+			Description:  strings.TrimPrefix(fmt.Sprintf("%s\nDificultad: %s\nEstado: %s", m.Description, m.Difficulty, m.Status), "\n"),
+			Categories:   []string{"Misión"},
+			Start:        *m.DueDate,
+			LastModified: m.UpdatedAt,
+			Status:       status,
+		})
+	}
+	for _, t := range transmutations {
+		cal.Events = append(cal.Events, ical.Event{
+			UID:          fmt.Sprintf("transmutation-%d@alchemist-system", t.ID),
+			Summary:      fmt.Sprintf("Transmutación #%d", t.ID),
+			Description:  fmt.Sprintf("Fórmula: %s\nMaterial: %d\nEstado: %s", t.Formula, t.MaterialID, t.Status),
+			Categories:   []string{"Transmutación"},
+			Start:        *t.ScheduledAt,
+			LastModified: t.UpdatedAt,
+			Status:       "CONFIRMED",
+		})
+	}
+	return cal
+}
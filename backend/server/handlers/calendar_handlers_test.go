@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCalendarRotateTokenOwnership(t *testing.T) {
+	db := newTestDB(t, &models.Alchemist{}, &models.CalendarFeed{})
+	ed := &models.Alchemist{Name: "Ed", Age: 15}
+	al := &models.Alchemist{Name: "Al", Age: 14}
+	create(t, db, ed)
+	create(t, db, al)
+
+	cases := []struct {
+		name       string
+		role       string
+		session    uint
+		target     uint
+		wantStatus int
+	}{
+		{"alquimista, su propio enlace", "alchemist", ed.ID, ed.ID, http.StatusCreated},
+		{"alquimista, enlace de otro", "alchemist", ed.ID, al.ID, http.StatusForbidden},
+		{"sin alquimista vinculado", "alchemist", 0, ed.ID, http.StatusForbidden},
+		{"supervisor, enlace de otro", "supervisor", 0, al.ID, http.StatusCreated},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := NewCalendarHandler(
+				repository.NewCalendarFeedRepository(db),
+				repository.NewAlchemistRepository(db),
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				func(w http.ResponseWriter, status int, _ string, err error) { http.Error(w, err.Error(), status) },
+				func(int, string, time.Time) {},
+			)
+			h.CurrentRole = func(*http.Request) string { return c.role }
+			h.CurrentAlchemist = func(*http.Request) uint { return c.session }
+			r := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", nil), map[string]string{"id": strconv.Itoa(int(c.target))})
+			w := httptest.NewRecorder()
+			h.RotateToken(w, r)
+			if w.Code != c.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, c.wantStatus, w.Body)
+			}
+		})
+	}
+}
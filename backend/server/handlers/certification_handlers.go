@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CertificationHandler gestiona las licencias y certificaciones de cada
+// alquimista bajo /alchemists/{id}/certifications.
+type CertificationHandler struct {
+	Repo             *repository.CertificationRepository
+	AlchemistRepo    *repository.AlchemistRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+}
+
+func NewCertificationHandler(
+	repo *repository.CertificationRepository,
+	alchemistRepo *repository.AlchemistRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *CertificationHandler {
+	return &CertificationHandler{
+		Repo:             repo,
+		AlchemistRepo:    alchemistRepo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *CertificationHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func (h *CertificationHandler) alchemist(w http.ResponseWriter, r *http.Request) *models.Alchemist {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	a, err := h.AlchemistRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return nil
+	}
+	return a
+}
+
+func (h *CertificationHandler) certification(w http.ResponseWriter, r *http.Request, a *models.Alchemist) *models.Certification {
+	certID, err := strconv.Atoi(mux.Vars(r)["certId"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	c, err := h.Repo.FindById(a.ID, certID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if c == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("certification not found"))
+		return nil
+	}
+	return c
+}
+
+func certificationResponse(c *models.Certification, now time.Time) *api.CertificationResponseDto {
+	resp := &api.CertificationResponseDto{
+		ID:               int(c.ID),
+		AlchemistID:      c.AlchemistID,
+		Kind:             c.Kind,
+		Name:             c.Name,
+		Number:           c.Number,
+		IssuingAuthority: c.IssuingAuthority,
+		IssuedAt:         c.IssuedAt.Format(time.RFC3339),
+		ExpiresAt:        c.ExpiresAt.Format(time.RFC3339),
+		Status:           c.Status(now),
+		RevokedBy:        c.RevokedBy,
+		RevocationReason: c.RevocationReason,
+		CreatedAt:        c.CreatedAt.Format(time.RFC3339),
+	}
+	if c.RevokedAt != nil {
+		resp.RevokedAt = c.RevokedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// GET /alchemists/{id}/certifications
+func (h *CertificationHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	cs, err := h.Repo.FindByAlchemist(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	now := time.Now()
+	resp := make([]*api.CertificationResponseDto, 0, len(cs))
+	for _, c := range cs {
+		resp = append(resp, certificationResponse(c, now))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /alchemists/{id}/certifications
+func (h *CertificationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+
+	var req api.CertificationRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = models.CertificationKindLicense
+	}
+	expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	issuedAt := time.Now()
+	if req.IssuedAt != "" {
+		if issuedAt, err = time.Parse(time.RFC3339, req.IssuedAt); err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+	}
+	if !expiresAt.After(issuedAt) {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("expires_at must be after issued_at"))
+		return
+	}
+
+	c := &models.Certification{
+		AlchemistID:      a.ID,
+		Kind:             req.Kind,
+		Name:             req.Name,
+		Number:           req.Number,
+		IssuingAuthority: req.IssuingAuthority,
+		IssuedAt:         issuedAt,
+		ExpiresAt:        expiresAt,
+	}
+	c, err = h.Repo.Save(c)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("create", "certification", c.ID, h.userEmail(r), fmt.Sprintf("Certificación %q emitida al alquimista %d", c.Name, a.ID)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": certificationResponse(c, time.Now())})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// PUT /alchemists/{id}/certifications/{certId}
+func (h *CertificationHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	c := h.certification(w, r, a)
+	if c == nil {
+		return
+	}
+
+	var req api.CertificationEditRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	if req.Kind != nil {
+		c.Kind = *req.Kind
+	}
+	if req.Name != nil {
+		c.Name = *req.Name
+	}
+	if req.Number != nil {
+		c.Number = *req.Number
+	}
+	if req.IssuingAuthority != nil {
+		c.IssuingAuthority = *req.IssuingAuthority
+	}
+	if req.IssuedAt != nil {
+		issuedAt, err := time.Parse(time.RFC3339, *req.IssuedAt)
+		if err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+		c.IssuedAt = issuedAt
+	}
+	if req.ExpiresAt != nil {
+		expiresAt, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+		c.ExpiresAt = expiresAt
+	}
+	if !c.ExpiresAt.After(c.IssuedAt) {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("expires_at must be after issued_at"))
+		return
+	}
+
+	c, err := h.Repo.Save(c)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("update", "certification", c.ID, h.userEmail(r), "Actualización de certificación"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": certificationResponse(c, time.Now())})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// POST /alchemists/{id}/certifications/{certId}/revoke
+func (h *CertificationHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	c := h.certification(w, r, a)
+	if c == nil {
+		return
+	}
+	if c.RevokedAt != nil {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("certification already revoked"))
+		return
+	}
+
+	var req api.CertificationRevokeRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	now := time.Now()
+	c.RevokedAt = &now
+	c.RevokedBy = h.userEmail(r)
+	c.RevocationReason = req.Reason
+	c, err := h.Repo.Save(c)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("revoke", "certification", c.ID, h.userEmail(r), "Certificación revocada: "+req.Reason); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": certificationResponse(c, now)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// DELETE /alchemists/{id}/certifications/{certId}
+func (h *CertificationHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	c := h.certification(w, r, a)
+	if c == nil {
+		return
+	}
+	if err := h.Repo.Delete(c); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete", "certification", c.ID, h.userEmail(r), "Eliminación de certificación"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
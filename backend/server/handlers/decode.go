@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"encoding/json"
+	"net/http"
+)
+
+// decodeRequest lee el cuerpo JSON en dst y aplica sus reglas de validación.
+// Responde 400 si el JSON es inválido y 422 con los errores por campo si no
+// supera la validación; en ambos casos devuelve false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, dst any, handleErr func(http.ResponseWriter, int, string, error)) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		handleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return false
+	}
+	if err := api.Validate(dst); err != nil {
+		handleErr(w, http.StatusUnprocessableEntity, r.URL.Path, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DivisionHandler gestiona divisiones, su supervisor y la pertenencia de los
+// alquimistas.
+type DivisionHandler struct {
+	Repo             *repository.DivisionRepository
+	UserRepo         repository.UserRepository
+	AlchemistRepo    *repository.AlchemistRepository
+	Scope            *DivisionScope
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+	// RoleHasPermission indica si un rol concede un permiso; sin él se usan
+	// los roles por defecto.
+	RoleHasPermission func(role, perm string) bool
+}
+
+func NewDivisionHandler(
+	repo *repository.DivisionRepository,
+	userRepo repository.UserRepository,
+	alchemistRepo *repository.AlchemistRepository,
+	scope *DivisionScope,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *DivisionHandler {
+	return &DivisionHandler{
+		Repo:             repo,
+		UserRepo:         userRepo,
+		AlchemistRepo:    alchemistRepo,
+		Scope:            scope,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *DivisionHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func divisionResponse(d *models.Division) *api.DivisionResponseDto {
+	resp := &api.DivisionResponseDto{
+		ID:          int(d.ID),
+		Name:        d.Name,
+		Description: d.Description,
+		ParentID:    d.ParentID,
+		CreatedAt:   d.CreatedAt.Format(time.RFC3339),
+	}
+	if d.Supervisor != nil {
+		resp.SupervisorEmail = d.Supervisor.Email
+	}
+	return resp
+}
+
+func (h *DivisionHandler) division(w http.ResponseWriter, r *http.Request) *models.Division {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	d, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if d == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("division not found"))
+		return nil
+	}
+	return d
+}
+
+func (h *DivisionHandler) roleHas(role, perm string) bool {
+	if h.RoleHasPermission != nil {
+		return h.RoleHasPermission(role, perm)
+	}
+	return slices.Contains(models.DefaultRoles[role], perm)
+}
+
+// supervisor resuelve el email a un usuario cuyo rol puede gestionar misiones
+// ("" = sin supervisor).
+func (h *DivisionHandler) supervisor(email string) (*models.User, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, nil
+	}
+	u, err := h.UserRepo.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || !h.roleHas(u.Role, models.PermMissionWrite) {
+		return nil, fmt.Errorf("%s is not a supervisor", email)
+	}
+	return u, nil
+}
+
+// checkParent valida la división padre: debe existir, estar al alcance del
+// supervisor y no ser la propia división ni una de sus subdivisiones. Los
+// supervisores con alcance limitado no pueden crear divisiones raíz.
+func (h *DivisionHandler) checkParent(r *http.Request, self uint, parentID *uint) (int, error) {
+	if parentID == nil {
+		scoped, err := h.Scope.Divisions(r)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if scoped != nil {
+			return http.StatusForbidden, errors.New("only organization-wide supervisors can manage root divisions")
+		}
+		return 0, nil
+	}
+	parent, err := h.Repo.FindById(int(*parentID))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if parent == nil {
+		return http.StatusBadRequest, errors.New("parent division not found")
+	}
+	if err := h.Scope.CheckDivision(r, parentID); err != nil {
+		return scopeStatus(err), err
+	}
+	if self != 0 {
+		subtree, err := h.Repo.WithDescendants(self)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if slices.Contains(subtree, *parentID) {
+			return http.StatusConflict, errors.New("a division cannot report to itself or to one of its subdivisions")
+		}
+	}
+	return 0, nil
+}
+
+// GET /divisions
+func (h *DivisionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ds, err := h.Repo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.DivisionResponseDto, 0, len(ds))
+	for _, d := range ds {
+		resp = append(resp, divisionResponse(d))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /divisions/{id}
+func (h *DivisionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	d := h.division(w, r)
+	if d == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": divisionResponse(d)})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /divisions
+func (h *DivisionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.DivisionRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if status, err := h.checkParent(r, 0, req.ParentID); err != nil {
+		h.HandleErr(w, status, r.URL.Path, err)
+		return
+	}
+	sup, err := h.supervisor(req.SupervisorEmail)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	existing, err := h.Repo.FindByName(req.Name)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if existing != nil {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("division %q already exists", req.Name))
+		return
+	}
+
+	d := &models.Division{
+		Name:        req.Name,
+		Description: req.Description,
+		ParentID:    req.ParentID,
+		Supervisor:  sup,
+	}
+	if sup != nil {
+		d.SupervisorID = &sup.ID
+	}
+	if d, err = h.Repo.Save(d); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("create", "division", d.ID, h.userEmail(r), fmt.Sprintf("Creación de la división %q", d.Name)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": divisionResponse(d)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// PUT /divisions/{id}
+func (h *DivisionHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	d := h.division(w, r)
+	if d == nil {
+		return
+	}
+	if err := h.Scope.CheckDivision(r, &d.ID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+	var req api.DivisionEditRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name != d.Name {
+			existing, err := h.Repo.FindByName(name)
+			if err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			if existing != nil {
+				h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("division %q already exists", name))
+				return
+			}
+		}
+		d.Name = name
+	}
+	if req.Description != nil {
+		d.Description = *req.Description
+	}
+	if req.ParentID != nil {
+		parentID := req.ParentID
+		if *parentID == 0 {
+			parentID = nil
+		}
+		if status, err := h.checkParent(r, d.ID, parentID); err != nil {
+			h.HandleErr(w, status, r.URL.Path, err)
+			return
+		}
+		d.ParentID = parentID
+	}
+	if req.SupervisorEmail != nil {
+		sup, err := h.supervisor(*req.SupervisorEmail)
+		if err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+		d.Supervisor, d.SupervisorID = sup, nil
+		if sup != nil {
+			d.SupervisorID = &sup.ID
+		}
+	}
+
+	if _, err := h.Repo.Save(d); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("update", "division", d.ID, h.userEmail(r), "Actualización de división"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": divisionResponse(d)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// DELETE /divisions/{id}. Solo se eliminan divisiones sin miembros ni subdivisiones.
+func (h *DivisionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	d := h.division(w, r)
+	if d == nil {
+		return
+	}
+	if err := h.Scope.CheckDivision(r, &d.ID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+	members, err := h.Repo.CountMembers(d.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	children, err := h.Repo.CountChildren(d.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if members > 0 || children > 0 {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("division still has %d members and %d subdivisions", members, children))
+		return
+	}
+	if err := h.Repo.Delete(d); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete", "division", d.ID, h.userEmail(r), fmt.Sprintf("Eliminación de la división %q", d.Name)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /divisions/{id}/members
+func (h *DivisionHandler) Members(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	d := h.division(w, r)
+	if d == nil {
+		return
+	}
+	alchs, err := h.Repo.FindMembers(d.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.AlchemistResponseDto, 0, len(alchs))
+	for _, a := range alchs {
+		resp = append(resp, alchemistResponse(a))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+func (h *DivisionHandler) member(w http.ResponseWriter, r *http.Request) *models.Alchemist {
+	id, err := strconv.Atoi(mux.Vars(r)["alchemistId"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	a, err := h.AlchemistRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return nil
+	}
+	return a
+}
+
+// PUT /divisions/{id}/members/{alchemistId} mueve al alquimista a la división.
+// El supervisor debe tener alcance sobre la división de destino y la de origen.
+func (h *DivisionHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	d := h.division(w, r)
+	if d == nil {
+		return
+	}
+	a := h.member(w, r)
+	if a == nil {
+		return
+	}
+	if err := h.Scope.CheckDivision(r, &d.ID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+	if err := h.Scope.CheckDivision(r, a.DivisionID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+	previous := a.DivisionID
+	if err := h.Repo.SetMembership(a.ID, &d.ID); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	a.DivisionID = &d.ID
+	if h.Dispatcher != nil {
+		details := fmt.Sprintf("Alquimista %d asignado a la división %q", a.ID, d.Name)
+		if previous != nil && *previous != d.ID {
+			details += fmt.Sprintf(" (antes en la división %d)", *previous)
+		}
+		if err := h.Dispatcher.EnqueueAudit("add_member", "division", d.ID, h.userEmail(r), details); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": alchemistResponse(a)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// DELETE /divisions/{id}/members/{alchemistId} deja al alquimista sin división.
+func (h *DivisionHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	d := h.division(w, r)
+	if d == nil {
+		return
+	}
+	a := h.member(w, r)
+	if a == nil {
+		return
+	}
+	if a.DivisionID == nil || *a.DivisionID != d.ID {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist is not a member of this division"))
+		return
+	}
+	if err := h.Scope.CheckDivision(r, &d.ID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+	if err := h.Repo.SetMembership(a.ID, nil); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("remove_member", "division", d.ID, h.userEmail(r), fmt.Sprintf("Alquimista %d retirado de la división %q", a.ID, d.Name)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /alchemists/{id}/reporting-line devuelve la división del alquimista y
+// sus ascendientes, de la más cercana a la raíz.
+func (h *DivisionHandler) ReportingLine(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	a, err := h.AlchemistRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return
+	}
+	resp := []*api.DivisionResponseDto{}
+	if a.DivisionID != nil {
+		chain, err := h.Repo.FindChain(*a.DivisionID)
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		for _, d := range chain {
+			resp = append(resp, divisionResponse(d))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
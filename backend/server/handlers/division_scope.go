@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"errors"
+	"net/http"
+	"slices"
+)
+
+var errOutsideDivision = errors.New("resource belongs to a division outside your supervision")
+
+// DivisionScope limita a cada usuario a los recursos de las divisiones que
+// dirige y de sus subdivisiones, sea cual sea su rol: las rutas ya exigen el
+// permiso de escritura correspondiente. Solo quien tiene division:all actúa
+// sobre toda la organización; quien no dirige ninguna división no alcanza
+// ninguna, y los recursos sin división (o misiones sin asignar) quedan fuera
+// del alcance limitado. Un alquimista siempre alcanza sus propios recursos.
+// Un *DivisionScope nil no aplica restricciones.
+type DivisionScope struct {
+	Repo          *repository.DivisionRepository
+	UserRepo      repository.UserRepository
+	AlchemistRepo *repository.AlchemistRepository
+	CurrentUser   func(*http.Request) string
+	CurrentRole   func(*http.Request) string
+	// HasPermission resuelve permisos del usuario o de la clave de API.
+	HasPermission func(*http.Request, string) bool
+}
+
+func NewDivisionScope(
+	repo *repository.DivisionRepository,
+	userRepo repository.UserRepository,
+	alchemistRepo *repository.AlchemistRepository,
+	currentUser func(*http.Request) string,
+	currentRole func(*http.Request) string,
+) *DivisionScope {
+	return &DivisionScope{
+		Repo:          repo,
+		UserRepo:      userRepo,
+		AlchemistRepo: alchemistRepo,
+		CurrentUser:   currentUser,
+		CurrentRole:   currentRole,
+	}
+}
+
+func (s *DivisionScope) global(r *http.Request) bool {
+	return s == nil || hasPermission(r, s.HasPermission, s.CurrentRole, models.PermDivisionAll)
+}
+
+func (s *DivisionScope) user(r *http.Request) (*models.User, error) {
+	if s.CurrentUser == nil {
+		return nil, nil
+	}
+	return s.UserRepo.FindByEmail(s.CurrentUser(r))
+}
+
+// Divisions devuelve las divisiones que puede gestionar el usuario de la
+// petición, empezando por las que dirige directamente. nil significa sin
+// restricción; una lista vacía, ninguna división.
+func (s *DivisionScope) Divisions(r *http.Request) ([]uint, error) {
+	if s.global(r) {
+		return nil, nil
+	}
+	u, err := s.user(r)
+	if err != nil || u == nil {
+		return []uint{}, err
+	}
+	ids, err := s.Repo.FindSupervisedIDs(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ids == nil {
+		ids = []uint{}
+	}
+	return ids, nil
+}
+
+// CheckDivision devuelve errOutsideDivision si la división (nil = ninguna)
+// queda fuera del alcance del usuario.
+func (s *DivisionScope) CheckDivision(r *http.Request, divisionID *uint) error {
+	ids, err := s.Divisions(r)
+	if err != nil {
+		return err
+	}
+	if ids != nil && (divisionID == nil || !slices.Contains(ids, *divisionID)) {
+		return errOutsideDivision
+	}
+	return nil
+}
+
+// CheckAlchemist aplica CheckDivision a la división del alquimista. 0 es un
+// recurso sin alquimista (p. ej. una misión sin asignar).
+func (s *DivisionScope) CheckAlchemist(r *http.Request, alchemistID uint) error {
+	if s.global(r) {
+		return nil
+	}
+	if alchemistID == 0 {
+		return s.CheckDivision(r, nil)
+	}
+	u, err := s.user(r)
+	if err != nil {
+		return err
+	}
+	if u != nil && u.AlchemistID != nil && *u.AlchemistID == alchemistID {
+		return nil
+	}
+	a, err := s.AlchemistRepo.FindById(int(alchemistID))
+	if err != nil || a == nil {
+		return err
+	}
+	return s.CheckDivision(r, a.DivisionID)
+}
+
+// scopeStatus traduce los errores de DivisionScope a códigos HTTP.
+func scopeStatus(err error) int {
+	if errors.Is(err, errOutsideDivision) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB abre una base SQLite en memoria propia del test.
+func newTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func create(t *testing.T, db *gorm.DB, v any) {
+	t.Helper()
+	if err := db.Create(v).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func asUser(email string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Test-User", email)
+	return r
+}
+
+func TestDivisionScope(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.Division{}, &models.Alchemist{})
+
+	// El jefe de división tiene un rol personalizado: el alcance no depende
+	// del nombre del rol.
+	lead := &models.User{Email: "lead@example.com", PasswordHash: "x", Role: "coordinator"}
+	admin := &models.User{Email: "admin@example.com", PasswordHash: "x", Role: "admin"}
+	leaderless := &models.User{Email: "sup@example.com", PasswordHash: "x", Role: "supervisor"}
+	for _, u := range []*models.User{lead, admin, leaderless} {
+		create(t, db, u)
+	}
+
+	root := &models.Division{Name: "Norte", SupervisorID: &lead.ID}
+	create(t, db, root)
+	child := &models.Division{Name: "Norte-Forja", ParentID: &root.ID}
+	other := &models.Division{Name: "Sur"}
+	create(t, db, child)
+	create(t, db, other)
+
+	inChild := &models.Alchemist{Name: "Ed", Age: 15, DivisionID: &child.ID}
+	inOther := &models.Alchemist{Name: "Al", Age: 14, DivisionID: &other.ID}
+	unassigned := &models.Alchemist{Name: "Izumi", Age: 35}
+	for _, a := range []*models.Alchemist{inChild, inOther, unassigned} {
+		create(t, db, a)
+	}
+	// Un alquimista alcanza sus propios recursos aunque no dirija nada.
+	self := &models.User{Email: "al@example.com", PasswordHash: "x", Role: "alchemist", AlchemistID: &inOther.ID}
+	create(t, db, self)
+
+	users := repository.NewUserRepository(db)
+	scope := NewDivisionScope(
+		repository.NewDivisionRepository(db),
+		users,
+		repository.NewAlchemistRepository(db),
+		func(r *http.Request) string { return r.Header.Get("X-Test-User") },
+		func(r *http.Request) string {
+			u, _ := users.FindByEmail(r.Header.Get("X-Test-User"))
+			if u == nil {
+				return ""
+			}
+			return u.Role
+		},
+	)
+	// Las claves de API tienen sus propios permisos.
+	scope.HasPermission = func(r *http.Request, perm string) bool {
+		if r.Header.Get("X-Test-User") == "apikey:global" {
+			return perm == models.PermDivisionAll
+		}
+		u, _ := users.FindByEmail(r.Header.Get("X-Test-User"))
+		return u != nil && slices.Contains(models.DefaultRoles[u.Role], perm)
+	}
+
+	t.Run("divisions", func(t *testing.T) {
+		ids, err := scope.Divisions(asUser(lead.Email))
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(ids)
+		if want := []uint{root.ID, child.ID}; !slices.Equal(ids, want) {
+			t.Errorf("lead divisions = %v, want %v", ids, want)
+		}
+		for _, email := range []string{admin.Email, "apikey:global"} {
+			ids, err := scope.Divisions(asUser(email))
+			if err != nil || ids != nil {
+				t.Errorf("%q: got %v, %v; want unrestricted", email, ids, err)
+			}
+		}
+		for _, email := range []string{leaderless.Email, self.Email, "apikey:ci", ""} {
+			ids, err := scope.Divisions(asUser(email))
+			if err != nil || ids == nil || len(ids) != 0 {
+				t.Errorf("%q: got %v, %v; want an empty scope", email, ids, err)
+			}
+		}
+		var none *DivisionScope
+		if ids, err := none.Divisions(asUser(lead.Email)); ids != nil || err != nil {
+			t.Errorf("nil scope: got %v, %v", ids, err)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		user      string
+		alchemist uint
+		wantErr   error
+	}{
+		{"subdivisión propia", lead.Email, inChild.ID, nil},
+		{"otra división", lead.Email, inOther.ID, errOutsideDivision},
+		{"alquimista sin división", lead.Email, unassigned.ID, errOutsideDivision},
+		{"misión sin asignar", lead.Email, 0, errOutsideDivision},
+		{"supervisor sin división", leaderless.Email, inChild.ID, errOutsideDivision},
+		{"supervisor sin división, alquimista sin división", leaderless.Email, unassigned.ID, errOutsideDivision},
+		{"alquimista, recurso propio", self.Email, inOther.ID, nil},
+		{"alquimista, recurso ajeno", self.Email, inChild.ID, errOutsideDivision},
+		{"alcance global", admin.Email, inOther.ID, nil},
+		{"alcance global, sin división", admin.Email, unassigned.ID, nil},
+		{"alcance global, sin asignar", admin.Email, 0, nil},
+		{"clave de API con alcance global", "apikey:global", inOther.ID, nil},
+		{"clave de API sin alcance global", "apikey:ci", inOther.ID, errOutsideDivision},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := scope.CheckAlchemist(asUser(tt.user), tt.alchemist)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckAlchemist = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && scopeStatus(err) != http.StatusForbidden {
+				t.Errorf("scopeStatus = %d, want 403", scopeStatus(err))
+			}
+		})
+	}
+
+	t.Run("recursos sin división", func(t *testing.T) {
+		if err := scope.CheckDivision(asUser(lead.Email), nil); !errors.Is(err, errOutsideDivision) {
+			t.Errorf("lead: CheckDivision(nil) = %v, want %v", err, errOutsideDivision)
+		}
+		if err := scope.CheckDivision(asUser(admin.Email), nil); err != nil {
+			t.Errorf("admin: CheckDivision(nil) = %v, want nil", err)
+		}
+	})
+}
+
+func TestSupervisorRoleIsDivisionScoped(t *testing.T) {
+	if slices.Contains(models.DefaultRoles["supervisor"], models.PermDivisionAll) {
+		t.Error("supervisor role grants division:all by default")
+	}
+	if !slices.Contains(models.DefaultRoles["admin"], models.PermDivisionAll) {
+		t.Error("admin role lacks division:all")
+	}
+}
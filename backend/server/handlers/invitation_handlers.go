@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+const DefaultInvitationTTL = 72 * time.Hour
+
+var (
+	errInvalidInvitation = errors.New("invalid or expired invitation code")
+	errInvitationEmail   = errors.New("invitation code was issued for a different email")
+)
+
+// InviteClaims son los datos firmados dentro de un código de invitación. El ID
+// (jti) enlaza con el registro que impide reutilizarlo.
+type InviteClaims struct {
+	Role  string `json:"role"`
+	Email string `json:"email,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// inviteKey deriva la clave de firma de las invitaciones del secreto JWT, de
+// modo que un código de invitación nunca sea aceptado como token de sesión.
+func inviteKey(secret string) []byte {
+	sum := sha256.Sum256([]byte("invitation:" + secret))
+	return sum[:]
+}
+
+func signInvitation(secret string, inv *models.Invitation) (string, error) {
+	claims := &InviteClaims{
+		Role:  inv.Role,
+		Email: inv.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        inv.CodeID,
+			IssuedAt:  jwt.NewNumericDate(inv.CreatedAt),
+			ExpiresAt: jwt.NewNumericDate(inv.ExpiresAt),
+			Issuer:    "alchemist-system",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(inviteKey(secret))
+}
+
+// redeemableInvitation verifica la firma del código y que la invitación siga
+// vigente (ni usada, ni revocada, ni caducada) para el email dado.
+func redeemableInvitation(repo *repository.InvitationRepository, secret, code, email string) (*models.Invitation, error) {
+	claims := &InviteClaims{}
+	_, err := jwt.ParseWithClaims(code, claims, func(t *jwt.Token) (interface{}, error) {
+		return inviteKey(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, errInvalidInvitation
+	}
+	inv, err := repo.FindByCodeID(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || inv.UsedAt != nil || time.Now().After(inv.ExpiresAt) {
+		return nil, errInvalidInvitation
+	}
+	if inv.Email != "" && !strings.EqualFold(inv.Email, email) {
+		return nil, errInvitationEmail
+	}
+	return inv, nil
+}
+
+// InvitationHandler permite a los supervisores emitir y revocar códigos de
+// invitación de un solo uso para registrar cuentas con un rol concreto.
+type InvitationHandler struct {
+	Repo             *repository.InvitationRepository
+	RoleRepo         *repository.RoleRepository
+	Secret           string
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+}
+
+func NewInvitationHandler(
+	repo *repository.InvitationRepository,
+	roleRepo *repository.RoleRepository,
+	secret string,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *InvitationHandler {
+	return &InvitationHandler{
+		Repo:             repo,
+		RoleRepo:         roleRepo,
+		Secret:           secret,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *InvitationHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func invitationResponse(inv *models.Invitation) *api.InvitationResponseDto {
+	resp := &api.InvitationResponseDto{
+		ID:        int(inv.ID),
+		Role:      inv.Role,
+		Email:     inv.Email,
+		ExpiresAt: inv.ExpiresAt.Format(time.RFC3339),
+		CreatedBy: inv.CreatedBy,
+		UsedBy:    inv.UsedBy,
+		CreatedAt: inv.CreatedAt.Format(time.RFC3339),
+	}
+	if inv.UsedAt != nil {
+		resp.UsedAt = inv.UsedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// GET /auth/invitations
+func (h *InvitationHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	xs, err := h.Repo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.InvitationResponseDto, 0, len(xs))
+	for _, inv := range xs {
+		resp = append(resp, invitationResponse(inv))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /auth/invitations. El código solo se muestra en esta respuesta.
+func (h *InvitationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.InvitationRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	if h.RoleRepo != nil {
+		role, err := h.RoleRepo.FindByName(req.Role)
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if role == nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("role %q does not exist", req.Role))
+			return
+		}
+	}
+	ttl := DefaultInvitationTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	codeID, err := randomKey()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	now := time.Now()
+	inv := &models.Invitation{
+		CodeID:    codeID,
+		Role:      req.Role,
+		Email:     strings.TrimSpace(req.Email),
+		ExpiresAt: now.Add(ttl),
+		CreatedBy: h.userEmail(r),
+	}
+	if inv, err = h.Repo.Save(inv); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	code, err := signInvitation(h.Secret, inv)
+	if err != nil {
+		h.Repo.Delete(inv)
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		details := fmt.Sprintf("Invitación con rol %q", inv.Role)
+		if inv.Email != "" {
+			details += " para " + inv.Email
+		}
+		if err := h.Dispatcher.EnqueueAudit("create", "invitation", inv.ID, h.userEmail(r), details); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	resp := invitationResponse(inv)
+	resp.Code = code
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// DELETE /auth/invitations/{id} revoca una invitación pendiente.
+func (h *InvitationHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	inv, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if inv == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("invitation not found"))
+		return
+	}
+	if inv.UsedAt != nil {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("invitation has already been used"))
+		return
+	}
+	if err := h.Repo.Delete(inv); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete", "invitation", inv.ID, h.userEmail(r), "Revocación de invitación"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
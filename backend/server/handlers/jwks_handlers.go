@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"backend-avanzada/keyring"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// JWKSHandler publica las claves públicas con las que otros servicios pueden
+// verificar nuestros tokens.
+type JWKSHandler struct {
+	Keys *keyring.Keyring
+	Log  func(int, string, time.Time)
+}
+
+func NewJWKSHandler(keys *keyring.Keyring, log func(int, string, time.Time)) *JWKSHandler {
+	return &JWKSHandler{Keys: keys, Log: log}
+}
+
+// GET /.well-known/jwks.json
+func (h *JWKSHandler) Get(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	w.Header().Set("Content-Type", "application/json")
+	// Caché corta para que los verificadores vean pronto una clave nueva.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.Keys.JWKS())
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
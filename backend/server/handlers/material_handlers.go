@@ -100,12 +100,7 @@ func (h *MaterialHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 func (h *MaterialHandler) Create(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	var req api.MaterialRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
-		return
-	}
-	if req.Name == "" {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("name required"))
+	if !decodeRequest(w, r, &req, h.HandleErr) {
 		return
 	}
 	m := &models.Material{
@@ -184,8 +179,7 @@ func (h *MaterialHandler) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req api.MaterialEditRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleErr) {
 		return
 	}
 
@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// mentionPattern reconoce menciones del tipo "@ana@laboratorio.com".
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@([A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// MissionCommentHandler gestiona los comentarios de una misión y su historial
+// de actividad.
+type MissionCommentHandler struct {
+	Repo             *repository.MissionCommentRepository
+	MissionRepo      *repository.MissionRepository
+	TaskRepo         *repository.MissionTaskRepository
+	AuditRepo        *repository.AuditRepository
+	UserRepo         repository.UserRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	CurrentRole      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+	// HasPermission resuelve permisos del usuario o de la clave de API.
+	HasPermission func(*http.Request, string) bool
+}
+
+func NewMissionCommentHandler(
+	repo *repository.MissionCommentRepository,
+	missionRepo *repository.MissionRepository,
+	taskRepo *repository.MissionTaskRepository,
+	auditRepo *repository.AuditRepository,
+	userRepo repository.UserRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	currentRole func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *MissionCommentHandler {
+	return &MissionCommentHandler{
+		Repo:             repo,
+		MissionRepo:      missionRepo,
+		TaskRepo:         taskRepo,
+		AuditRepo:        auditRepo,
+		UserRepo:         userRepo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		CurrentRole:      currentRole,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *MissionCommentHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+// canModerate permite borrar comentarios ajenos a quien gestiona misiones.
+func (h *MissionCommentHandler) canModerate(r *http.Request) bool {
+	return hasPermission(r, h.HasPermission, h.CurrentRole, models.PermMissionWrite)
+}
+
+func (h *MissionCommentHandler) mission(w http.ResponseWriter, r *http.Request) *models.Mission {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	m, err := h.MissionRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if m == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("mission not found"))
+		return nil
+	}
+	return m
+}
+
+func (h *MissionCommentHandler) comment(w http.ResponseWriter, r *http.Request, m *models.Mission) *models.MissionComment {
+	commentID, err := strconv.Atoi(mux.Vars(r)["commentId"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	c, err := h.Repo.FindById(m.ID, commentID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if c == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("comment not found"))
+		return nil
+	}
+	return c
+}
+
+// resolveMentions busca los usuarios mencionados en el cuerpo. Las menciones a
+// correos que no existen se ignoran.
+func (h *MissionCommentHandler) resolveMentions(body string) ([]models.MissionCommentMention, error) {
+	var mentions []models.MissionCommentMention
+	seen := map[string]struct{}{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(strings.TrimRight(match[1], "."))
+		if _, ok := seen[email]; ok {
+			continue
+		}
+		seen[email] = struct{}{}
+		u, err := h.UserRepo.FindByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			continue
+		}
+		mentions = append(mentions, models.MissionCommentMention{UserID: u.ID, Email: u.Email})
+	}
+	return mentions, nil
+}
+
+func missionCommentResponse(c *models.MissionComment) *api.MissionCommentResponseDto {
+	resp := &api.MissionCommentResponseDto{
+		ID:          int(c.ID),
+		MissionID:   c.MissionID,
+		ParentID:    c.ParentID,
+		AuthorEmail: c.AuthorEmail,
+		Body:        c.Body,
+		Mentions:    make([]string, 0, len(c.Mentions)),
+		CreatedAt:   c.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   c.UpdatedAt.Format(time.RFC3339),
+	}
+	for _, m := range c.Mentions {
+		resp.Mentions = append(resp.Mentions, m.Email)
+	}
+	return resp
+}
+
+// GET /missions/{id}/comments devuelve los comentarios como hilos.
+func (h *MissionCommentHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	cs, err := h.Repo.FindByMission(m.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+
+	byID := make(map[uint]*api.MissionCommentResponseDto, len(cs))
+	for _, c := range cs {
+		byID[c.ID] = missionCommentResponse(c)
+	}
+	roots := make([]*api.MissionCommentResponseDto, 0, len(cs))
+	for _, c := range cs {
+		node := byID[c.ID]
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok {
+				parent.Replies = append(parent.Replies, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": roots})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /missions/{id}/comments
+func (h *MissionCommentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+
+	var req api.MissionCommentRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	if req.ParentID != nil {
+		parent, err := h.Repo.FindById(m.ID, int(*req.ParentID))
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if parent == nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("parent comment not found in this mission"))
+			return
+		}
+	}
+
+	mentions, err := h.resolveMentions(req.Body)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+
+	c := &models.MissionComment{
+		MissionID:   m.ID,
+		ParentID:    req.ParentID,
+		AuthorEmail: h.userEmail(r),
+		Body:        req.Body,
+		Mentions:    mentions,
+	}
+	c, err = h.Repo.Save(c)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		details := fmt.Sprintf("Comentario en la misión %d", m.ID)
+		if len(c.Mentions) > 0 {
+			emails := make([]string, 0, len(c.Mentions))
+			for _, mention := range c.Mentions {
+				emails = append(emails, mention.Email)
+			}
+			details += "; menciones: " + strings.Join(emails, ", ")
+		}
+		if err := h.Dispatcher.EnqueueAudit("create", "mission_comment", c.ID, h.userEmail(r), details); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": missionCommentResponse(c)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// PUT /missions/{id}/comments/{commentId} (solo el autor)
+func (h *MissionCommentHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	c := h.comment(w, r, m)
+	if c == nil {
+		return
+	}
+	if c.AuthorEmail != h.userEmail(r) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("only the author can edit a comment"))
+		return
+	}
+
+	var req api.MissionCommentEditRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	if req.Body != nil {
+		c.Body = *req.Body
+	}
+
+	mentions, err := h.resolveMentions(c.Body)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	c.Mentions = nil
+	c, err = h.Repo.Save(c)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if err := h.Repo.ReplaceMentions(c, mentions); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("update", "mission_comment", c.ID, h.userEmail(r), "Edición de comentario"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": missionCommentResponse(c)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// DELETE /missions/{id}/comments/{commentId} (autor o supervisor)
+func (h *MissionCommentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	c := h.comment(w, r, m)
+	if c == nil {
+		return
+	}
+	if c.AuthorEmail != h.userEmail(r) && !h.canModerate(r) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("only the author or a supervisor can delete a comment"))
+		return
+	}
+
+	if err := h.Repo.Delete(c); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete", "mission_comment", c.ID, h.userEmail(r), "Eliminación de comentario"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /missions/{id}/timeline combina comentarios, cambios de estado y
+// auditorías relacionadas en un único historial cronológico. Las auditorías
+// solo se incluyen si el usuario puede leerlas (audit:read), igual que en
+// /audits.
+func (h *MissionCommentHandler) Timeline(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+
+	type event struct {
+		at    time.Time
+		entry *api.MissionTimelineEntryDto
+	}
+	var events []event
+
+	comments, err := h.Repo.FindByMission(m.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	for _, c := range comments {
+		summary := "Comentario"
+		if c.ParentID != nil {
+			summary = fmt.Sprintf("Respuesta al comentario %d", *c.ParentID)
+		}
+		events = append(events, event{c.CreatedAt, &api.MissionTimelineEntryDto{
+			Type:    "comment",
+			At:      c.CreatedAt.Format(time.RFC3339),
+			Actor:   c.AuthorEmail,
+			Summary: summary,
+			Comment: missionCommentResponse(c),
+		}})
+	}
+
+	changes, err := h.MissionRepo.FindStatusChanges(m.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	for _, sc := range changes {
+		events = append(events, event{sc.CreatedAt, &api.MissionTimelineEntryDto{
+			Type:       "status_change",
+			At:         sc.CreatedAt.Format(time.RFC3339),
+			Actor:      sc.ChangedBy,
+			Summary:    fmt.Sprintf("Estado cambiado de %q a %q", sc.FromStatus, sc.ToStatus),
+			FromStatus: sc.FromStatus,
+			ToStatus:   sc.ToStatus,
+		}})
+	}
+
+	if h.AuditRepo != nil && hasPermission(r, h.HasPermission, h.CurrentRole, models.PermAuditRead) {
+		audits, err := h.AuditRepo.FindByEntity("mission", m.ID)
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if h.TaskRepo != nil {
+			taskIDs, err := h.TaskRepo.FindIDsByMission(m.ID)
+			if err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			taskAudits, err := h.AuditRepo.FindByEntity("mission_task", taskIDs...)
+			if err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			audits = append(audits, taskAudits...)
+		}
+		for _, a := range audits {
+			events = append(events, event{a.CreatedAt, &api.MissionTimelineEntryDto{
+				Type:    "audit",
+				At:      a.CreatedAt.Format(time.RFC3339),
+				Actor:   a.UserEmail,
+				Summary: a.Details,
+				Audit: &api.AuditResponseDto{
+					ID:        int(a.ID),
+					Action:    a.Action,
+					Entity:    a.Entity,
+					EntityID:  a.EntityID,
+					UserEmail: a.UserEmail,
+					Details:   a.Details,
+					CreatedAt: a.CreatedAt.Format(time.RFC3339),
+				},
+			}})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.Before(events[j].at) })
+	resp := make([]*api.MissionTimelineEntryDto, 0, len(events))
+	for _, e := range events {
+		resp = append(resp, e.entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
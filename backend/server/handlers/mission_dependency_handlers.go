@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// MissionDependencyHandler gestiona las relaciones "bloquea / bloqueada por"
+// entre misiones.
+type MissionDependencyHandler struct {
+	Repo             *repository.MissionDependencyRepository
+	MissionRepo      *repository.MissionRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+}
+
+func NewMissionDependencyHandler(
+	repo *repository.MissionDependencyRepository,
+	missionRepo *repository.MissionRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *MissionDependencyHandler {
+	return &MissionDependencyHandler{
+		Repo:             repo,
+		MissionRepo:      missionRepo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *MissionDependencyHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func (h *MissionDependencyHandler) mission(w http.ResponseWriter, r *http.Request) *models.Mission {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	m, err := h.MissionRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if m == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("mission not found"))
+		return nil
+	}
+	return m
+}
+
+// missionGraph es el grafo de dependencias en memoria.
+type missionGraph struct {
+	dependsOn map[uint][]uint // misión -> prerrequisitos
+	blocks    map[uint][]uint // prerrequisito -> misiones que bloquea
+}
+
+func newMissionGraph(deps []*models.MissionDependency) *missionGraph {
+	g := &missionGraph{dependsOn: map[uint][]uint{}, blocks: map[uint][]uint{}}
+	for _, d := range deps {
+		g.dependsOn[d.MissionID] = append(g.dependsOn[d.MissionID], d.DependsOnID)
+		g.blocks[d.DependsOnID] = append(g.blocks[d.DependsOnID], d.MissionID)
+	}
+	return g
+}
+
+// reaches indica si desde "from" se llega a "to" siguiendo prerrequisitos.
+func (g *missionGraph) reaches(from, to uint) bool {
+	seen := map[uint]bool{}
+	stack := []uint{from}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == to {
+			return true
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		stack = append(stack, g.dependsOn[n]...)
+	}
+	return false
+}
+
+// walk recorre el grafo en anchura y devuelve la profundidad mínima de cada
+// nodo alcanzable (sin incluir el inicial).
+func walk(start uint, next map[uint][]uint) map[uint]int {
+	depth := map[uint]int{}
+	queue := []uint{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, m := range next[n] {
+			if _, ok := depth[m]; ok || m == start {
+				continue
+			}
+			depth[m] = depth[n] + 1
+			queue = append(queue, m)
+		}
+	}
+	return depth
+}
+
+// longestChain devuelve la cadena más larga que parte de start siguiendo next.
+func longestChain(start uint, next map[uint][]uint, memo map[uint][]uint) []uint {
+	if chain, ok := memo[start]; ok {
+		return chain
+	}
+	var best []uint
+	for _, m := range next[start] {
+		if chain := longestChain(m, next, memo); len(chain) > len(best) {
+			best = chain
+		}
+	}
+	chain := append([]uint{start}, best...)
+	memo[start] = chain
+	return chain
+}
+
+func (h *MissionDependencyHandler) nodes(depths map[uint]int) ([]api.MissionGraphNodeDto, error) {
+	ids := make([]uint, 0, len(depths))
+	for id := range depths {
+		ids = append(ids, id)
+	}
+	ms, err := h.MissionRepo.FindByIds(ids)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]api.MissionGraphNodeDto, 0, len(ms))
+	for _, m := range ms {
+		nodes = append(nodes, api.MissionGraphNodeDto{ID: m.ID, Title: m.Title, Status: m.Status, Depth: depths[m.ID]})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Depth != nodes[j].Depth {
+			return nodes[i].Depth < nodes[j].Depth
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes, nil
+}
+
+// GET /missions/{id}/dependencies
+func (h *MissionDependencyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	deps, err := h.Repo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	g := newMissionGraph(deps)
+
+	direct := func(ids []uint) map[uint]int {
+		out := map[uint]int{}
+		for _, id := range ids {
+			out[id] = 1
+		}
+		return out
+	}
+	blockedBy, err := h.nodes(direct(g.dependsOn[m.ID]))
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	blocks, err := h.nodes(direct(g.blocks[m.ID]))
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+
+	resp := &api.MissionDependenciesResponseDto{MissionID: m.ID, BlockedBy: blockedBy, Blocks: blocks}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /missions/{id}/dependencies registra que la misión depende de otra.
+func (h *MissionDependencyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+
+	var req api.MissionDependencyRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	if req.DependsOnID == m.ID {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("a mission cannot depend on itself"))
+		return
+	}
+	prereq, err := h.MissionRepo.FindById(int(req.DependsOnID))
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if prereq == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("prerequisite mission not found"))
+		return
+	}
+
+	existing, err := h.Repo.Find(m.ID, prereq.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if existing != nil {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("dependency already exists"))
+		return
+	}
+
+	deps, err := h.Repo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if newMissionGraph(deps).reaches(prereq.ID, m.ID) {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path,
+			fmt.Errorf("dependency would create a cycle: mission %d already depends on mission %d", prereq.ID, m.ID))
+		return
+	}
+
+	d, err := h.Repo.Save(&models.MissionDependency{MissionID: m.ID, DependsOnID: prereq.ID})
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		details := fmt.Sprintf("La misión %d ahora depende de la misión %d", m.ID, prereq.ID)
+		if err := h.Dispatcher.EnqueueAudit("create", "mission_dependency", d.ID, h.userEmail(r), details); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	resp := api.MissionGraphEdgeDto{From: prereq.ID, To: m.ID}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// DELETE /missions/{id}/dependencies/{dependsOnId}
+func (h *MissionDependencyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	dependsOnID, err := strconv.Atoi(mux.Vars(r)["dependsOnId"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	d, err := h.Repo.Find(m.ID, uint(dependsOnID))
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if d == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("dependency not found"))
+		return
+	}
+	if err := h.Repo.Delete(d); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		details := fmt.Sprintf("La misión %d ya no depende de la misión %d", m.ID, dependsOnID)
+		if err := h.Dispatcher.EnqueueAudit("delete", "mission_dependency", d.ID, h.userEmail(r), details); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /missions/{id}/graph devuelve el DAG de prerrequisitos (upstream) y
+// dependientes (downstream) junto con la ruta crítica, es decir, la cadena de
+// misiones más larga que pasa por la misión consultada.
+func (h *MissionDependencyHandler) Graph(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	deps, err := h.Repo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	g := newMissionGraph(deps)
+
+	upDepths := walk(m.ID, g.dependsOn)
+	downDepths := walk(m.ID, g.blocks)
+	upstream, err := h.nodes(upDepths)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	downstream, err := h.nodes(downDepths)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+
+	inGraph := map[uint]bool{m.ID: true}
+	for id := range upDepths {
+		inGraph[id] = true
+	}
+	for id := range downDepths {
+		inGraph[id] = true
+	}
+	edges := make([]api.MissionGraphEdgeDto, 0)
+	for _, d := range deps {
+		if inGraph[d.MissionID] && inGraph[d.DependsOnID] {
+			edges = append(edges, api.MissionGraphEdgeDto{From: d.DependsOnID, To: d.MissionID})
+		}
+	}
+
+	before := longestChain(m.ID, g.dependsOn, map[uint][]uint{})
+	after := longestChain(m.ID, g.blocks, map[uint][]uint{})
+	path := make([]uint, 0, len(before)+len(after)-1)
+	for i := len(before) - 1; i >= 0; i-- {
+		path = append(path, before[i])
+	}
+	path = append(path, after[1:]...)
+
+	resp := &api.MissionGraphResponseDto{
+		MissionID:          m.ID,
+		Upstream:           upstream,
+		Downstream:         downstream,
+		Edges:              edges,
+		CriticalPath:       path,
+		CriticalPathLength: len(path),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
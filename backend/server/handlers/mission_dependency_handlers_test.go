@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"slices"
+	"testing"
+)
+
+// edges construye dependencias "misión -> prerrequisito".
+func edges(pairs ...[2]uint) []*models.MissionDependency {
+	deps := make([]*models.MissionDependency, 0, len(pairs))
+	for _, p := range pairs {
+		deps = append(deps, &models.MissionDependency{MissionID: p[0], DependsOnID: p[1]})
+	}
+	return deps
+}
+
+func TestMissionGraphReaches(t *testing.T) {
+	// 2 depende de 1, 3 de 2, 4 de 2 y de 3; 5 está suelta.
+	g := newMissionGraph(edges([2]uint{2, 1}, [2]uint{3, 2}, [2]uint{4, 2}, [2]uint{4, 3}))
+
+	tests := []struct {
+		from, to uint
+		want     bool
+	}{
+		{2, 1, true},
+		{4, 1, true},
+		{3, 1, true},
+		{1, 4, false},
+		{2, 3, false},
+		{5, 1, false},
+		{1, 1, true},
+	}
+	for _, tt := range tests {
+		if got := g.reaches(tt.from, tt.to); got != tt.want {
+			t.Errorf("reaches(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// Añadir "mission depende de prereq" cierra un ciclo si prereq ya alcanza
+// mission, que es la comprobación que hace Create.
+func TestMissionGraphCycleDetection(t *testing.T) {
+	tests := []struct {
+		name            string
+		deps            []*models.MissionDependency
+		mission, prereq uint
+		wantCycle       bool
+	}{
+		{"grafo vacío", nil, 2, 1, false},
+		{"ciclo directo", edges([2]uint{2, 1}), 1, 2, true},
+		{"ciclo largo", edges([2]uint{2, 1}, [2]uint{3, 2}, [2]uint{4, 3}), 1, 4, true},
+		{"diamante sin ciclo", edges([2]uint{2, 1}, [2]uint{3, 1}, [2]uint{4, 2}), 4, 3, false},
+		{"arista paralela", edges([2]uint{3, 2}, [2]uint{2, 1}), 3, 1, false},
+		{"ramas separadas", edges([2]uint{2, 1}, [2]uint{4, 3}), 3, 2, false},
+		{"a través de un diamante", edges([2]uint{2, 1}, [2]uint{3, 1}, [2]uint{4, 2}, [2]uint{4, 3}), 1, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newMissionGraph(tt.deps).reaches(tt.prereq, tt.mission); got != tt.wantCycle {
+				t.Errorf("cycle = %v, want %v", got, tt.wantCycle)
+			}
+		})
+	}
+}
+
+func TestMissionGraphWalk(t *testing.T) {
+	g := newMissionGraph(edges([2]uint{2, 1}, [2]uint{3, 2}, [2]uint{3, 1}, [2]uint{4, 3}))
+
+	// Profundidad mínima hacia los prerrequisitos de 4.
+	got := walk(4, g.dependsOn)
+	want := map[uint]int{3: 1, 2: 2, 1: 2}
+	if len(got) != len(want) {
+		t.Fatalf("walk = %v, want %v", got, want)
+	}
+	for id, d := range want {
+		if got[id] != d {
+			t.Errorf("depth[%d] = %d, want %d", id, got[id], d)
+		}
+	}
+
+	chain := longestChain(1, g.blocks, map[uint][]uint{})
+	if want := []uint{1, 2, 3, 4}; !slices.Equal(chain, want) {
+		t.Errorf("longestChain = %v, want %v", chain, want)
+	}
+}
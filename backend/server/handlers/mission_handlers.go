@@ -4,10 +4,13 @@ import (
 	"backend-avanzada/api"
 	"backend-avanzada/models"
 	"backend-avanzada/repository"
+	"backend-avanzada/storage"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -15,15 +18,26 @@ import (
 
 type MissionHandler struct {
 	Repo             *repository.MissionRepository
+	TaskRepo         *repository.MissionTaskRepository
+	SkillRepo        *repository.SkillRepository
+	AvailabilityRepo *repository.AvailabilityRepository
 	Dispatcher       AsyncDispatcher
 	CurrentUser      func(*http.Request) string
 	ReportAsyncError func(string, error)
 	HandleErr        func(http.ResponseWriter, int, string, error)
 	Log              func(int, string, time.Time)
+	// Scope restringe a los supervisores de división a sus propios recursos.
+	Scope *DivisionScope
+	// Store, si está configurado, permite borrar los ficheros de los adjuntos
+	// de una misión eliminada.
+	Store storage.BlobStore
 }
 
 func NewMissionHandler(
 	repo *repository.MissionRepository,
+	taskRepo *repository.MissionTaskRepository,
+	skillRepo *repository.SkillRepository,
+	availabilityRepo *repository.AvailabilityRepository,
 	dispatcher AsyncDispatcher,
 	currentUser func(*http.Request) string,
 	reportAsyncError func(string, error),
@@ -32,6 +46,9 @@ func NewMissionHandler(
 ) *MissionHandler {
 	return &MissionHandler{
 		Repo:             repo,
+		TaskRepo:         taskRepo,
+		SkillRepo:        skillRepo,
+		AvailabilityRepo: availabilityRepo,
 		Dispatcher:       dispatcher,
 		CurrentUser:      currentUser,
 		ReportAsyncError: reportAsyncError,
@@ -47,6 +64,56 @@ func (h *MissionHandler) userEmail(r *http.Request) string {
 	return ""
 }
 
+// parseOptionalTime interpreta una fecha RFC3339; la cadena vacía es nil.
+func parseOptionalTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// assigneeConflict comprueba que el alquimista esté disponible desde ahora
+// hasta la fecha límite de la misión (o en este instante si no tiene).
+func (h *MissionHandler) assigneeConflict(alchemistID uint, dueDate *time.Time) (string, error) {
+	if h.AvailabilityRepo == nil || alchemistID == 0 {
+		return "", nil
+	}
+	from := time.Now()
+	to := from
+	if dueDate != nil && dueDate.After(from) {
+		to = *dueDate
+	}
+	ws, err := h.AvailabilityRepo.FindOverlapping(alchemistID, from, to)
+	if err != nil || len(ws) == 0 {
+		return "", err
+	}
+	return "alchemist is unavailable during the mission: " + describeWindows(ws), nil
+}
+
+func missionResponse(m *models.Mission) *api.MissionResponseDto {
+	resp := &api.MissionResponseDto{
+		ID:          int(m.ID),
+		Title:       m.Title,
+		Description: m.Description,
+		Difficulty:  m.Difficulty,
+		Status:      m.Status,
+		AssignedTo:  m.AssignedTo,
+		TemplateID:  m.TemplateID,
+		CreatedAt:   m.CreatedAt.Format(time.RFC3339),
+	}
+	if m.DueDate != nil {
+		resp.DueDate = m.DueDate.Format(time.RFC3339)
+	}
+	for _, mm := range m.Materials {
+		resp.Materials = append(resp.Materials, api.MissionMaterialDto{MaterialID: mm.MaterialID, Quantity: mm.Quantity})
+	}
+	return resp
+}
+
 func (h *MissionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	ms, err := h.Repo.FindAll()
@@ -57,15 +124,7 @@ func (h *MissionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 
 	resp := make([]*api.MissionResponseDto, 0, len(ms))
 	for _, m := range ms {
-		resp = append(resp, &api.MissionResponseDto{
-			ID:          int(m.ID),
-			Title:       m.Title,
-			Description: m.Description,
-			Difficulty:  m.Difficulty,
-			Status:      m.Status,
-			AssignedTo:  m.AssignedTo,
-			CreatedAt:   m.CreatedAt.Format(time.RFC3339),
-		})
+		resp = append(resp, missionResponse(m))
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{"data": resp})
@@ -90,15 +149,7 @@ func (h *MissionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := &api.MissionResponseDto{
-		ID:          int(m.ID),
-		Title:       m.Title,
-		Description: m.Description,
-		Difficulty:  m.Difficulty,
-		Status:      m.Status,
-		AssignedTo:  m.AssignedTo,
-		CreatedAt:   m.CreatedAt.Format(time.RFC3339),
-	}
+	resp := missionResponse(m)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{"data": resp})
 	h.Log(http.StatusOK, r.URL.Path, start)
@@ -107,13 +158,28 @@ func (h *MissionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 func (h *MissionHandler) Create(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	var req api.MissionRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	dueDate, err := parseOptionalTime(req.DueDate)
+	if err != nil {
 		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
 		return
 	}
 
-	if req.Title == "" {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("title required"))
+	if err := h.Scope.CheckAlchemist(r, req.AssignedTo); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+
+	conflict, err := h.assigneeConflict(req.AssignedTo, dueDate)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if conflict != "" {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New(conflict))
 		return
 	}
 
@@ -123,8 +189,9 @@ func (h *MissionHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Difficulty:  req.Difficulty,
 		Status:      "pendiente",
 		AssignedTo:  req.AssignedTo,
+		DueDate:     dueDate,
 	}
-	m, err := h.Repo.Save(m)
+	m, err = h.Repo.Save(m)
 	if err != nil {
 		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
@@ -135,15 +202,7 @@ func (h *MissionHandler) Create(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	resp := &api.MissionResponseDto{
-		ID:          int(m.ID),
-		Title:       m.Title,
-		Description: m.Description,
-		Difficulty:  m.Difficulty,
-		Status:      m.Status,
-		AssignedTo:  m.AssignedTo,
-		CreatedAt:   m.CreatedAt.Format(time.RFC3339),
-	}
+	resp := missionResponse(m)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]any{"data": resp})
@@ -166,11 +225,26 @@ func (h *MissionHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("mission not found"))
 		return
 	}
+	if err := h.Scope.CheckAlchemist(r, m.AssignedTo); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
 
-	if err := h.Repo.Delete(m); err != nil {
+	// El repositorio borra también subtareas, materiales, requisitos,
+	// dependencias, comentarios, historial y adjuntos; los ficheros de los
+	// adjuntos se eliminan después de confirmar el borrado.
+	attachments, err := h.Repo.Delete(m)
+	if err != nil {
 		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
 	}
+	if h.Store != nil {
+		for _, a := range attachments {
+			if err := removeAttachmentBlobs(h.Store, a); err != nil {
+				h.ReportAsyncError(r.URL.Path, err)
+			}
+		}
+	}
 	if h.Dispatcher != nil {
 		if err := h.Dispatcher.EnqueueAudit("delete", "mission", m.ID, h.userEmail(r), "Eliminación de misión"); err != nil {
 			h.ReportAsyncError(r.URL.Path, err)
@@ -197,12 +271,19 @@ func (h *MissionHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// La misión pertenece a la división de su responsable.
+	if err := h.Scope.CheckAlchemist(r, m.AssignedTo); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+
 	var req api.MissionEditRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleErr) {
 		return
 	}
 
+	var statusChange *models.MissionStatusChange
+	previousAssignee := m.AssignedTo
 	if req.Title != nil {
 		m.Title = *req.Title
 	}
@@ -213,32 +294,103 @@ func (h *MissionHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		m.Difficulty = *req.Difficulty
 	}
 	if req.Status != nil {
+		if *req.Status != m.Status && (*req.Status == "en_progreso" || *req.Status == "completada") {
+			open, err := h.Repo.FindOpenPrerequisites(m.ID)
+			if err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			if len(open) > 0 {
+				ids := make([]string, 0, len(open))
+				for _, p := range open {
+					ids = append(ids, strconv.Itoa(int(p.ID)))
+				}
+				h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("mission is blocked by open missions: %s", strings.Join(ids, ", ")))
+				return
+			}
+		}
+		if *req.Status == "completada" && m.Status != "completada" && h.TaskRepo != nil {
+			open, err := h.TaskRepo.CountOpenMandatory(m.ID)
+			if err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			if open > 0 {
+				h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("mission has %d open mandatory tasks", open))
+				return
+			}
+		}
+		if *req.Status != m.Status {
+			statusChange = &models.MissionStatusChange{
+				MissionID:  m.ID,
+				FromStatus: m.Status,
+				ToStatus:   *req.Status,
+				ChangedBy:  h.userEmail(r),
+			}
+		}
 		m.Status = *req.Status
 	}
 	if req.AssignedTo != nil {
+		if *req.AssignedTo != m.AssignedTo {
+			if err := h.Scope.CheckAlchemist(r, *req.AssignedTo); err != nil {
+				h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+				return
+			}
+		}
+		if *req.AssignedTo != 0 && *req.AssignedTo != m.AssignedTo && h.SkillRepo != nil {
+			reqs, err := h.SkillRepo.FindRequirements(models.SkillRequirementMission, m.ID)
+			if err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			unmet, err := h.SkillRepo.Unmet(*req.AssignedTo, reqs)
+			if err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			if len(unmet) > 0 {
+				h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("alchemist does not meet mission skill requirements: %s", describeUnmet(unmet)))
+				return
+			}
+		}
 		m.AssignedTo = *req.AssignedTo
 	}
+	if req.DueDate != nil {
+		if m.DueDate, err = parseOptionalTime(*req.DueDate); err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+	}
+	if req.AssignedTo != nil && *req.AssignedTo != previousAssignee {
+		conflict, err := h.assigneeConflict(m.AssignedTo, m.DueDate)
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if conflict != "" {
+			h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New(conflict))
+			return
+		}
+	}
 
 	m, err = h.Repo.Save(m)
 	if err != nil {
 		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
 	}
+	if statusChange != nil {
+		if err := h.Repo.SaveStatusChange(statusChange); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+	}
 	if h.Dispatcher != nil {
 		if err := h.Dispatcher.EnqueueAudit("update", "mission", m.ID, h.userEmail(r), "Actualización de misión"); err != nil {
 			h.ReportAsyncError(r.URL.Path, err)
 		}
 	}
 
-	resp := &api.MissionResponseDto{
-		ID:          int(m.ID),
-		Title:       m.Title,
-		Description: m.Description,
-		Difficulty:  m.Difficulty,
-		Status:      m.Status,
-		AssignedTo:  m.AssignedTo,
-		CreatedAt:   m.CreatedAt.Format(time.RFC3339),
-	}
+	resp := missionResponse(m)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]any{"data": resp})
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// MissionTaskHandler gestiona las subtareas anidadas bajo /missions/{id}/tasks.
+type MissionTaskHandler struct {
+	Repo             *repository.MissionTaskRepository
+	MissionRepo      *repository.MissionRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+	// HasPermission resuelve permisos del usuario o de la clave de API.
+	HasPermission func(*http.Request, string) bool
+	// CurrentAlchemist identifica al alquimista vinculado a la sesión.
+	CurrentAlchemist func(*http.Request) uint
+}
+
+func NewMissionTaskHandler(
+	repo *repository.MissionTaskRepository,
+	missionRepo *repository.MissionRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *MissionTaskHandler {
+	return &MissionTaskHandler{
+		Repo:             repo,
+		MissionRepo:      missionRepo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *MissionTaskHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+// mission obtiene la misión padre de la ruta; si no existe responde el error y
+// devuelve nil.
+func (h *MissionTaskHandler) mission(w http.ResponseWriter, r *http.Request) *models.Mission {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	m, err := h.MissionRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if m == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("mission not found"))
+		return nil
+	}
+	return m
+}
+
+// task obtiene la subtarea {taskId} de la misión dada; si no existe responde el
+// error y devuelve nil.
+func (h *MissionTaskHandler) task(w http.ResponseWriter, r *http.Request, m *models.Mission) *models.MissionTask {
+	taskID, err := strconv.Atoi(mux.Vars(r)["taskId"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	t, err := h.Repo.FindById(m.ID, taskID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if t == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("task not found"))
+		return nil
+	}
+	return t
+}
+
+var (
+	errTaskStatusOnly  = errors.New("only the task status can be changed without task:write")
+	errTaskNotAssigned = errors.New("task is not assigned to you")
+)
+
+// checkTaskEdit limita a quien no tiene task:write (los alquimistas) a cambiar
+// el estado de las subtareas asignadas a él: título, orden u obligatoriedad
+// quedan para quien gestiona la misión, así nadie puede saltarse la regla de
+// completado desmarcando una tarea obligatoria.
+func checkTaskEdit(req *api.MissionTaskEditRequestDto, t *models.MissionTask, canWrite bool, alchemistID uint) error {
+	if canWrite {
+		return nil
+	}
+	if req.Title != nil || req.Description != nil || req.AssignedTo != nil || req.Position != nil || req.Mandatory != nil {
+		return errTaskStatusOnly
+	}
+	if alchemistID == 0 || t.AssignedTo != alchemistID {
+		return errTaskNotAssigned
+	}
+	return nil
+}
+
+func (h *MissionTaskHandler) currentAlchemist(r *http.Request) uint {
+	if h.CurrentAlchemist != nil {
+		return h.CurrentAlchemist(r)
+	}
+	return 0
+}
+
+func missionTaskResponse(t *models.MissionTask) *api.MissionTaskResponseDto {
+	return &api.MissionTaskResponseDto{
+		ID:          int(t.ID),
+		MissionID:   t.MissionID,
+		Title:       t.Title,
+		Description: t.Description,
+		AssignedTo:  t.AssignedTo,
+		Status:      t.Status,
+		Position:    t.Position,
+		Mandatory:   t.Mandatory,
+		CreatedAt:   t.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// GET /missions/{id}/tasks
+func (h *MissionTaskHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	ts, err := h.Repo.FindByMission(m.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.MissionTaskResponseDto, 0, len(ts))
+	for _, t := range ts {
+		resp = append(resp, missionTaskResponse(t))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /missions/{id}/tasks/{taskId}
+func (h *MissionTaskHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	t := h.task(w, r, m)
+	if t == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": missionTaskResponse(t)})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /missions/{id}/tasks
+func (h *MissionTaskHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+
+	var req api.MissionTaskRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	t := &models.MissionTask{
+		MissionID:   m.ID,
+		Title:       req.Title,
+		Description: req.Description,
+		AssignedTo:  req.AssignedTo,
+		Status:      "pendiente",
+		Mandatory:   true,
+	}
+	if req.Mandatory != nil {
+		t.Mandatory = *req.Mandatory
+	}
+	if req.Position != nil {
+		t.Position = *req.Position
+	} else {
+		pos, err := h.Repo.NextPosition(m.ID)
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		t.Position = pos
+	}
+
+	t, err := h.Repo.Save(t)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("create", "mission_task", t.ID, h.userEmail(r), "Subtarea agregada a la misión "+strconv.Itoa(int(m.ID))); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": missionTaskResponse(t)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// PUT /missions/{id}/tasks/{taskId}. Sin task:write solo se puede cambiar el
+// estado de una subtarea propia.
+func (h *MissionTaskHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	t := h.task(w, r, m)
+	if t == nil {
+		return
+	}
+
+	var req api.MissionTaskEditRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	canWrite := hasPermission(r, h.HasPermission, nil, models.PermTaskWrite)
+	if err := checkTaskEdit(&req, t, canWrite, h.currentAlchemist(r)); err != nil {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, err)
+		return
+	}
+
+	if req.Title != nil {
+		t.Title = *req.Title
+	}
+	if req.Description != nil {
+		t.Description = *req.Description
+	}
+	if req.AssignedTo != nil {
+		t.AssignedTo = *req.AssignedTo
+	}
+	if req.Status != nil {
+		t.Status = *req.Status
+	}
+	if req.Position != nil {
+		t.Position = *req.Position
+	}
+	if req.Mandatory != nil {
+		t.Mandatory = *req.Mandatory
+	}
+
+	t, err := h.Repo.Save(t)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("update", "mission_task", t.ID, h.userEmail(r), "Actualización de subtarea"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": missionTaskResponse(t)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// DELETE /missions/{id}/tasks/{taskId}
+func (h *MissionTaskHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	m := h.mission(w, r)
+	if m == nil {
+		return
+	}
+	t := h.task(w, r, m)
+	if t == nil {
+		return
+	}
+
+	if err := h.Repo.Delete(t); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete", "mission_task", t.ID, h.userEmail(r), "Eliminación de subtarea"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"errors"
+	"testing"
+)
+
+func TestCheckTaskEdit(t *testing.T) {
+	str := func(s string) *string { return &s }
+	no := false
+	pos := 3
+	other := uint(9)
+	task := &models.MissionTask{AssignedTo: 4, Mandatory: true}
+
+	tests := []struct {
+		name      string
+		req       api.MissionTaskEditRequestDto
+		canWrite  bool
+		alchemist uint
+		want      error
+	}{
+		{"gestor cambia cualquier campo", api.MissionTaskEditRequestDto{Mandatory: &no, Title: str("x")}, true, 0, nil},
+		{"asignado cambia el estado", api.MissionTaskEditRequestDto{Status: str("completada")}, false, 4, nil},
+		{"asignado no desmarca obligatoria", api.MissionTaskEditRequestDto{Mandatory: &no}, false, 4, errTaskStatusOnly},
+		{"asignado no cambia el título", api.MissionTaskEditRequestDto{Title: str("x"), Status: str("en_progreso")}, false, 4, errTaskStatusOnly},
+		{"asignado no reordena", api.MissionTaskEditRequestDto{Position: &pos}, false, 4, errTaskStatusOnly},
+		{"asignado no reasigna", api.MissionTaskEditRequestDto{AssignedTo: &other}, false, 4, errTaskStatusOnly},
+		{"tarea de otro alquimista", api.MissionTaskEditRequestDto{Status: str("completada")}, false, 5, errTaskNotAssigned},
+		{"sesión sin alquimista", api.MissionTaskEditRequestDto{Status: str("completada")}, false, 0, errTaskNotAssigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkTaskEdit(&tt.req, task, tt.canWrite, tt.alchemist); !errors.Is(err, tt.want) {
+				t.Errorf("checkTaskEdit = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissionTaskStatusValidation(t *testing.T) {
+	for _, status := range []string{"pendiente", "en_progreso", "completada"} {
+		if err := api.Validate(&api.MissionTaskEditRequestDto{Status: &status}); err != nil {
+			t.Errorf("%q rejected: %v", status, err)
+		}
+	}
+	for _, status := range []string{"", "hecha", "COMPLETADA"} {
+		if err := api.Validate(&api.MissionTaskEditRequestDto{Status: &status}); err == nil {
+			t.Errorf("%q accepted", status)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/recurrence"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type MissionTemplateHandler struct {
+	Repo             *repository.MissionTemplateRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+}
+
+func NewMissionTemplateHandler(
+	repo *repository.MissionTemplateRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *MissionTemplateHandler {
+	return &MissionTemplateHandler{
+		Repo:             repo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *MissionTemplateHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func missionTemplateResponse(t *models.MissionTemplate) *api.MissionTemplateResponseDto {
+	resp := &api.MissionTemplateResponseDto{
+		ID:          int(t.ID),
+		Title:       t.Title,
+		Description: t.Description,
+		Difficulty:  t.Difficulty,
+		AssignedTo:  t.AssignedTo,
+		Materials:   make([]api.MissionTemplateMaterialDto, 0, len(t.Materials)),
+		Tasks:       make([]api.MissionTemplateTaskDto, 0, len(t.Tasks)),
+		Recurrence:  t.Recurrence,
+		StartsAt:    t.StartsAt.Format(time.RFC3339),
+		Active:      t.Active,
+		CreatedAt:   t.CreatedAt.Format(time.RFC3339),
+	}
+	if t.NextRunAt != nil {
+		resp.NextRunAt = t.NextRunAt.Format(time.RFC3339)
+	}
+	if t.LastRunAt != nil {
+		resp.LastRunAt = t.LastRunAt.Format(time.RFC3339)
+	}
+	for _, m := range t.Materials {
+		resp.Materials = append(resp.Materials, api.MissionTemplateMaterialDto{MaterialID: m.MaterialID, Quantity: m.Quantity})
+	}
+	for _, task := range t.Tasks {
+		mandatory := task.Mandatory
+		resp.Tasks = append(resp.Tasks, api.MissionTemplateTaskDto{
+			Title:       task.Title,
+			Description: task.Description,
+			AssignedTo:  task.AssignedTo,
+			Mandatory:   &mandatory,
+		})
+	}
+	return resp
+}
+
+func templateMaterials(xs []api.MissionTemplateMaterialDto) ([]models.MissionTemplateMaterial, error) {
+	out := make([]models.MissionTemplateMaterial, 0, len(xs))
+	for _, x := range xs {
+		if x.MaterialID == 0 {
+			return nil, errors.New("material_id required")
+		}
+		out = append(out, models.MissionTemplateMaterial{MaterialID: x.MaterialID, Quantity: x.Quantity})
+	}
+	return out, nil
+}
+
+func templateTasks(xs []api.MissionTemplateTaskDto) ([]models.MissionTemplateTask, error) {
+	out := make([]models.MissionTemplateTask, 0, len(xs))
+	for i, x := range xs {
+		if x.Title == "" {
+			return nil, errors.New("task title required")
+		}
+		mandatory := true
+		if x.Mandatory != nil {
+			mandatory = *x.Mandatory
+		}
+		out = append(out, models.MissionTemplateTask{
+			Title:       x.Title,
+			Description: x.Description,
+			AssignedTo:  x.AssignedTo,
+			Position:    i,
+			Mandatory:   mandatory,
+		})
+	}
+	return out, nil
+}
+
+// schedule recalcula NextRunAt a partir de la regla y el ancla de la plantilla.
+func schedule(t *models.MissionTemplate, now time.Time) error {
+	if t.Recurrence == "" {
+		t.NextRunAt = nil
+		return nil
+	}
+	rule, err := recurrence.Parse(t.Recurrence)
+	if err != nil {
+		return err
+	}
+	t.Recurrence = rule.String()
+	next := rule.After(t.StartsAt, now.Add(-time.Nanosecond))
+	t.NextRunAt = &next
+	return nil
+}
+
+// GET /mission-templates
+func (h *MissionTemplateHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ts, err := h.Repo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.MissionTemplateResponseDto, 0, len(ts))
+	for _, t := range ts {
+		resp = append(resp, missionTemplateResponse(t))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /mission-templates/{id}
+func (h *MissionTemplateHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	t, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if t == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("mission template not found"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": missionTemplateResponse(t)})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /mission-templates
+func (h *MissionTemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.MissionTemplateRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	materials, err := templateMaterials(req.Materials)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	tasks, err := templateTasks(req.Tasks)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+
+	now := time.Now()
+	t := &models.MissionTemplate{
+		Title:       req.Title,
+		Description: req.Description,
+		Difficulty:  req.Difficulty,
+		AssignedTo:  req.AssignedTo,
+		Recurrence:  req.Recurrence,
+		StartsAt:    now,
+		Active:      true,
+		Materials:   materials,
+		Tasks:       tasks,
+	}
+	if req.StartsAt != "" {
+		startsAt, err := time.Parse(time.RFC3339, req.StartsAt)
+		if err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+		t.StartsAt = startsAt
+	}
+	if req.Active != nil {
+		t.Active = *req.Active
+	}
+	if err := schedule(t, now); err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+
+	t, err = h.Repo.Save(t)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("create", "mission_template", t.ID, h.userEmail(r), "Creación de plantilla de misión"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": missionTemplateResponse(t)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// PUT /mission-templates/{id}
+func (h *MissionTemplateHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	t, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if t == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("mission template not found"))
+		return
+	}
+
+	var req api.MissionTemplateEditRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	if req.Title != nil {
+		t.Title = *req.Title
+	}
+	if req.Description != nil {
+		t.Description = *req.Description
+	}
+	if req.Difficulty != nil {
+		t.Difficulty = *req.Difficulty
+	}
+	if req.AssignedTo != nil {
+		t.AssignedTo = *req.AssignedTo
+	}
+	// Al reactivar se recalcula la próxima ejecución: la guardada puede haber
+	// quedado en el pasado y dispararía de inmediato.
+	reactivated := req.Active != nil && *req.Active && !t.Active
+	if req.Active != nil {
+		t.Active = *req.Active
+	}
+	if req.Materials != nil {
+		if t.Materials, err = templateMaterials(*req.Materials); err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+	}
+	if req.Tasks != nil {
+		if t.Tasks, err = templateTasks(*req.Tasks); err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+	}
+	if req.Recurrence != nil || req.StartsAt != nil || reactivated {
+		if req.Recurrence != nil {
+			t.Recurrence = *req.Recurrence
+		}
+		if req.StartsAt != nil {
+			startsAt, err := time.Parse(time.RFC3339, *req.StartsAt)
+			if err != nil {
+				h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+				return
+			}
+			t.StartsAt = startsAt
+		}
+		if err := schedule(t, time.Now()); err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+	}
+
+	t, err = h.Repo.Save(t)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("update", "mission_template", t.ID, h.userEmail(r), "Actualización de plantilla de misión"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": missionTemplateResponse(t)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// DELETE /mission-templates/{id}
+func (h *MissionTemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	t, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if t == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("mission template not found"))
+		return
+	}
+	if err := h.Repo.Delete(t); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete", "mission_template", t.ID, h.userEmail(r), "Eliminación de plantilla de misión"); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /mission-templates/{id}/instantiate crea una misión a demanda sin
+// alterar la programación de la plantilla.
+func (h *MissionTemplateHandler) Instantiate(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	t, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if t == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("mission template not found"))
+		return
+	}
+
+	m, err := h.Repo.Instantiate(t, time.Now(), nil)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("create", "mission", m.ID, h.userEmail(r), "Misión creada desde la plantilla "+strconv.Itoa(int(t.ID))); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": missionResponse(m)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
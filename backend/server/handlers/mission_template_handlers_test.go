@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	stale := now.AddDate(0, -2, 0)
+
+	tests := []struct {
+		name     string
+		tpl      models.MissionTemplate
+		want     *time.Time
+		wantRule string
+		wantErr  bool
+	}{
+		{
+			name: "sin recurrencia",
+			tpl:  models.MissionTemplate{StartsAt: now, NextRunAt: &stale},
+		},
+		{
+			name:    "regla inválida",
+			tpl:     models.MissionTemplate{Recurrence: "FREQ=HOURLY", StartsAt: now},
+			wantErr: true,
+		},
+		{
+			name:     "ancla futura",
+			tpl:      models.MissionTemplate{Recurrence: "freq=weekly", StartsAt: now.Add(48 * time.Hour)},
+			want:     ptr(now.Add(48 * time.Hour)),
+			wantRule: "FREQ=WEEKLY",
+		},
+		{
+			name:     "ancla en este instante",
+			tpl:      models.MissionTemplate{Recurrence: "FREQ=DAILY", StartsAt: now},
+			want:     ptr(now),
+			wantRule: "FREQ=DAILY",
+		},
+		{
+			// Una plantilla reactivada con la próxima ejecución en el pasado
+			// se reprograma a la siguiente repetición futura.
+			name:     "próxima ejecución antigua",
+			tpl:      models.MissionTemplate{Recurrence: "FREQ=MONTHLY", StartsAt: time.Date(2026, 1, 20, 9, 0, 0, 0, time.UTC), NextRunAt: &stale},
+			want:     ptr(time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)),
+			wantRule: "FREQ=MONTHLY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl := tt.tpl
+			err := schedule(&tpl, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.want == nil && tpl.NextRunAt != nil:
+				t.Errorf("NextRunAt = %v, want nil", tpl.NextRunAt)
+			case tt.want != nil && (tpl.NextRunAt == nil || !tpl.NextRunAt.Equal(*tt.want)):
+				t.Errorf("NextRunAt = %v, want %v", tpl.NextRunAt, tt.want)
+			}
+			if tt.wantRule != "" && tpl.Recurrence != tt.wantRule {
+				t.Errorf("Recurrence = %q, want %q", tpl.Recurrence, tt.wantRule)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
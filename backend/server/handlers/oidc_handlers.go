@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/oidc"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	oidcCookie   = "oidc_login"
+	oidcStateTTL = 10 * time.Minute
+)
+
+var (
+	errInvalidOIDCState = errors.New("invalid or expired login state; start again")
+	errOIDCLinkPending  = errors.New("account link with the identity provider is pending administrator approval")
+)
+
+// OIDCRoleMapping asigna Role a quien traiga Value en la claim de roles del
+// proveedor (p. ej. un grupo). Gana la primera que encaje.
+type OIDCRoleMapping struct {
+	Value string
+	Role  string
+}
+
+// oidcStateClaims viajan firmadas en una cookie entre el inicio del login y
+// la vuelta del proveedor. El verificador PKCE no sale nunca en la URL.
+type oidcStateClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+func (h *AuthHandler) oidcCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     "/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.OIDC.RedirectURL(), "https://"),
+		// Lax para que la cookie acompañe a la redirección de vuelta.
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// GET /auth/oidc/login redirige al proveedor de identidad.
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	var values [3]string
+	for i := range values {
+		v, err := oidc.RandomString(32)
+		if err != nil {
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	target, err := h.OIDC.AuthCodeURL(r.Context(), state, nonce, oidc.Challenge(verifier))
+	if err != nil {
+		h.HandleError(w, http.StatusBadGateway, r.URL.Path, err)
+		return
+	}
+	now := time.Now()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &oidcStateClaims{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcStateTTL)),
+		},
+	}).SignedString(accountKey("oidc", h.JWTSecret))
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	http.SetCookie(w, h.oidcCookie(signed, int(oidcStateTTL/time.Second)))
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// oidcState recupera el estado de la cookie (y la borra) y comprueba que
+// corresponde al state devuelto por el proveedor.
+func (h *AuthHandler) oidcState(w http.ResponseWriter, r *http.Request) (*oidcStateClaims, error) {
+	c, err := r.Cookie(oidcCookie)
+	if err != nil {
+		return nil, errInvalidOIDCState
+	}
+	http.SetCookie(w, h.oidcCookie("", -1))
+	claims := &oidcStateClaims{}
+	_, err = jwt.ParseWithClaims(c.Value, claims, func(t *jwt.Token) (interface{}, error) {
+		return accountKey("oidc", h.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, errInvalidOIDCState
+	}
+	state := r.URL.Query().Get("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(claims.State)) != 1 {
+		return nil, errInvalidOIDCState
+	}
+	return claims, nil
+}
+
+// oidcRole traduce las claims del proveedor a un rol local. matched indica
+// si lo decidió una de las reglas y no el rol por defecto.
+func (h *AuthHandler) oidcRole(claims oidc.Claims) (role string, matched bool) {
+	values := claims.Strings(h.OIDCRoleClaim)
+	for _, m := range h.OIDCRoleMappings {
+		if slices.Contains(values, m.Value) {
+			return m.Role, true
+		}
+	}
+	return h.OIDCDefaultRole, false
+}
+
+// oidcRoleExists comprueba que el rol decidido por el proveedor exista, por si
+// se borró después de arrancar.
+func (h *AuthHandler) oidcRoleExists(name string) (bool, error) {
+	if h.RoleRepository == nil {
+		_, ok := models.DefaultRoles[name]
+		return ok, nil
+	}
+	role, err := h.RoleRepository.FindByName(name)
+	return role != nil, err
+}
+
+// GET /auth/oidc/callback completa el login a la vuelta del proveedor: crea
+// la cuenta la primera vez y, si el usuario tiene doble factor, lo exige
+// igual que el login con contraseña.
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	state, err := h.oidcState(w, r)
+	if err != nil {
+		h.HandleError(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	if code := q.Get("error"); code != "" {
+		h.HandleError(w, http.StatusUnauthorized, r.URL.Path, &oidc.Error{Code: code, Description: q.Get("error_description")})
+		return
+	}
+	if q.Get("code") == "" {
+		h.HandleError(w, http.StatusBadRequest, r.URL.Path, errors.New("missing authorization code"))
+		return
+	}
+
+	claims, err := h.OIDC.Exchange(r.Context(), q.Get("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		status := http.StatusBadGateway
+		var providerErr *oidc.Error
+		if errors.As(err, &providerErr) || errors.Is(err, oidc.ErrInvalidIDToken) {
+			status = http.StatusUnauthorized
+		}
+		h.HandleError(w, status, r.URL.Path, err)
+		return
+	}
+
+	role, matched := h.oidcRole(claims)
+	if role == "" {
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, errors.New("identity provider account is not mapped to any role"))
+		return
+	}
+	exists, err := h.oidcRoleExists(role)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if !exists {
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, fmt.Errorf("identity provider account is mapped to role %q, which does not exist", role))
+		return
+	}
+	u, status, err := h.oidcUser(r, claims, role, matched)
+	if err != nil {
+		h.HandleError(w, status, r.URL.Path, err)
+		return
+	}
+	if u.Disabled() {
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, errors.New("account is disabled"))
+		return
+	}
+	if h.RequireVerifiedEmail && u.EmailVerifiedAt == nil {
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, errors.New("email address is not verified"))
+		return
+	}
+
+	var resp *api.AuthResponse
+	if h.RecoveryCodes != nil && (u.TwoFactorEnabled() || h.twoFactorRequired(u)) {
+		resp, err = h.mfaChallengeResponse(u)
+	} else {
+		resp, err = h.issueTokens(u, "")
+	}
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if h.OIDCPostLoginURL == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	// En el fragmento los tokens no llegan al servidor del frontend ni quedan
+	// en sus logs.
+	http.Redirect(w, r, h.OIDCPostLoginURL+"#"+oidcFragment(resp), http.StatusFound)
+}
+
+func oidcFragment(resp *api.AuthResponse) string {
+	v := url.Values{}
+	if resp.MFAToken != "" {
+		v.Set("mfa_token", resp.MFAToken)
+		v.Set("mfa_required", strconv.FormatBool(resp.MFARequired))
+		v.Set("mfa_setup_required", strconv.FormatBool(resp.MFASetupRequired))
+		return v.Encode()
+	}
+	v.Set("token", resp.Token)
+	if resp.RefreshToken != "" {
+		v.Set("refresh_token", resp.RefreshToken)
+	}
+	v.Set("expires_in", strconv.Itoa(resp.ExpiresIn))
+	return v.Encode()
+}
+
+// oidcUser devuelve la cuenta local de la identidad: la ya vinculada o una
+// nueva. Si el email ya es de una cuenta local no se vincula sin más (quien
+// controle el proveedor podría quedarse con ella): la petición queda pendiente
+// hasta que un supervisor la confirme. Si una regla fija el rol, se actualiza
+// en cada login de una cuenta vinculada.
+func (h *AuthHandler) oidcUser(r *http.Request, claims oidc.Claims, role string, matched bool) (*models.User, int, error) {
+	subject := claims.Subject()
+	u, err := h.UserRepository.FindByOIDCSubject(subject)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if u == nil {
+		email := strings.TrimSpace(claims.Email())
+		if email == "" {
+			return nil, http.StatusForbidden, errors.New("identity provider did not supply an email address")
+		}
+		existing, err := h.UserRepository.FindByEmail(email)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if existing == nil {
+			return h.createOIDCUser(r, claims, email, role)
+		}
+		status, err := h.requestOIDCLink(r, existing, claims)
+		return nil, status, err
+	}
+
+	if matched && u.Role != role {
+		previous := u.Role
+		u.Role = role
+		if _, err := h.UserRepository.Save(u); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		// Los tokens emitidos llevan el rol anterior.
+		if err := h.RevokeUserSessions(r.Context(), u.ID); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		h.audit(r, "update", "user", u.ID, u.Email, fmt.Sprintf("Cambio de rol de %s según el proveedor de identidad: %q → %q", u.Email, previous, u.Role))
+	}
+	return u, 0, nil
+}
+
+// requestOIDCLink deja pendiente de confirmar la vinculación de la identidad
+// con la cuenta local del mismo email. Devuelve el estado con que responder.
+func (h *AuthHandler) requestOIDCLink(r *http.Request, u *models.User, claims oidc.Claims) (int, error) {
+	if !claims.EmailVerified() {
+		return http.StatusConflict, errors.New("email already registered; the identity provider must verify it before linking")
+	}
+	if u.OIDCSubject != nil {
+		return http.StatusConflict, errors.New("email already registered with another identity")
+	}
+	subject := claims.Subject()
+	if u.OIDCPendingSubject == nil || *u.OIDCPendingSubject != subject {
+		u.OIDCPendingSubject = &subject
+		if _, err := h.UserRepository.Save(u); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		h.audit(r, "update", "user", u.ID, u.Email, fmt.Sprintf("Solicitud de vinculación de %s con el proveedor de identidad, pendiente de confirmar", u.Email))
+	}
+	return http.StatusForbidden, errOIDCLinkPending
+}
+
+// createOIDCUser da de alta la cuenta de una identidad nueva. La contraseña
+// es aleatoria: solo se puede entrar por SSO salvo que se restablezca.
+func (h *AuthHandler) createOIDCUser(r *http.Request, claims oidc.Claims, email, role string) (*models.User, int, error) {
+	password, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	subject := claims.Subject()
+	u := &models.User{
+		Email:        email,
+		PasswordHash: string(hash),
+		Role:         role,
+		OIDCSubject:  &subject,
+	}
+	if claims.EmailVerified() {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+	if _, err := h.UserRepository.Save(u); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	h.audit(r, "create", "user", u.ID, u.Email, fmt.Sprintf("Alta de %s (rol %s) mediante el proveedor de identidad", u.Email, u.Role))
+	if u.EmailVerifiedAt == nil && h.OnRegister != nil {
+		h.OnRegister(r, u)
+	}
+	return u, 0, nil
+}
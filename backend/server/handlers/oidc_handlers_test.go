@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/oidc"
+	"backend-avanzada/repository"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+func signOIDCState(t *testing.T, secret, state string, ttl time.Duration) string {
+	t.Helper()
+	now := time.Now()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &oidcStateClaims{
+		State:    state,
+		Nonce:    "nonce",
+		Verifier: "verifier",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}).SignedString(accountKey("oidc", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOIDCState(t *testing.T) {
+	h := &AuthHandler{JWTSecret: "secret", OIDC: oidc.New(oidc.Config{RedirectURL: "https://app/auth/oidc/callback"})}
+	valid := signOIDCState(t, "secret", "abc", time.Minute)
+	cases := []struct {
+		name   string
+		cookie string
+		state  string
+		ok     bool
+	}{
+		{"válido", valid, "abc", true},
+		{"sin cookie", "", "abc", false},
+		{"state distinto", valid, "abd", false},
+		{"sin state", valid, "", false},
+		{"firmada con otra clave", signOIDCState(t, "other", "abc", time.Minute), "abc", false},
+		{"caducada", signOIDCState(t, "secret", "abc", -time.Minute), "abc", false},
+		{"manipulada", valid + "x", "abc", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?state="+c.state, nil)
+			if c.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: oidcCookie, Value: c.cookie})
+			}
+			w := httptest.NewRecorder()
+			claims, err := h.oidcState(w, r)
+			if c.ok {
+				if err != nil || claims.Verifier != "verifier" || claims.Nonce != "nonce" {
+					t.Fatalf("oidcState = (%+v, %v), want the stored state", claims, err)
+				}
+			} else if !errors.Is(err, errInvalidOIDCState) {
+				t.Fatalf("oidcState error = %v, want %v", err, errInvalidOIDCState)
+			}
+			// La cookie se borra siempre que llega, valga o no.
+			if c.cookie != "" {
+				cookies := w.Result().Cookies()
+				if len(cookies) != 1 || cookies[0].MaxAge >= 0 || !cookies[0].Secure {
+					t.Errorf("cookie not cleared: %+v", cookies)
+				}
+			}
+		})
+	}
+}
+
+func TestOIDCUserLinkRequiresConfirmation(t *testing.T) {
+	db := newTestDB(t, &models.User{})
+	local := &models.User{Email: "ana@example.com", PasswordHash: "x", Role: "alchemist"}
+	create(t, db, local)
+	repo := repository.NewUserRepository(db)
+	h := &AuthHandler{UserRepository: repo}
+	claims := oidc.Claims{"sub": "idp-1", "email": "ana@example.com", "email_verified": true, "groups": []any{"admins"}}
+	r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback", nil)
+
+	// Sin email verificado por el proveedor ni siquiera queda pendiente.
+	unverified := oidc.Claims{"sub": "idp-1", "email": "ana@example.com"}
+	if _, status, _ := h.oidcUser(r, unverified, "supervisor", true); status != http.StatusConflict {
+		t.Errorf("unverified email status = %d, want %d", status, http.StatusConflict)
+	}
+
+	// Con email verificado la vinculación queda pendiente y no se entra ni
+	// cambia el rol.
+	u, status, err := h.oidcUser(r, claims, "supervisor", true)
+	if u != nil || status != http.StatusForbidden || !errors.Is(err, errOIDCLinkPending) {
+		t.Fatalf("oidcUser = (%v, %d, %v), want pending link", u, status, err)
+	}
+	got, _ := repo.FindByID(local.ID)
+	if got.OIDCSubject != nil || got.OIDCPendingSubject == nil || *got.OIDCPendingSubject != "idp-1" || got.Role != "alchemist" {
+		t.Fatalf("user after login = subject %v pending %v role %s", got.OIDCSubject, got.OIDCPendingSubject, got.Role)
+	}
+
+	// Un supervisor confirma la vinculación.
+	users := NewUserHandler(repo, nil, nil, nil, nil, func(w http.ResponseWriter, status int, _ string, _ error) {
+		w.WriteHeader(status)
+	}, func(int, string, time.Time) {})
+	confirm := func() int {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", nil), map[string]string{"id": strconv.Itoa(int(local.ID))})
+		w := httptest.NewRecorder()
+		users.ConfirmOIDCLink(w, req)
+		return w.Code
+	}
+	if code := confirm(); code != http.StatusOK {
+		t.Fatalf("confirm = %d, want %d", code, http.StatusOK)
+	}
+	if code := confirm(); code != http.StatusConflict {
+		t.Errorf("second confirm = %d, want %d", code, http.StatusConflict)
+	}
+
+	// Ya vinculada, entra y la regla fija el rol.
+	u, _, err = h.oidcUser(r, claims, "supervisor", true)
+	if err != nil || u == nil || u.ID != local.ID || u.Role != "supervisor" {
+		t.Fatalf("oidcUser after confirm = (%v, %v)", u, err)
+	}
+
+	// Otra identidad con el mismo email no puede pedir la cuenta.
+	other := oidc.Claims{"sub": "idp-2", "email": "ana@example.com", "email_verified": true}
+	if _, status, _ := h.oidcUser(r, other, "supervisor", true); status != http.StatusConflict {
+		t.Errorf("second identity status = %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestOIDCUserCreatesNewAccount(t *testing.T) {
+	db := newTestDB(t, &models.User{})
+	h := &AuthHandler{UserRepository: repository.NewUserRepository(db)}
+	r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback", nil)
+	claims := oidc.Claims{"sub": "idp-1", "email": "new@example.com", "email_verified": true}
+	u, _, err := h.oidcUser(r, claims, "alchemist", false)
+	if err != nil || u == nil || u.OIDCSubject == nil || *u.OIDCSubject != "idp-1" || u.EmailVerifiedAt == nil {
+		t.Fatalf("oidcUser = (%+v, %v), want a new linked account", u, err)
+	}
+	again, _, err := h.oidcUser(r, claims, "alchemist", false)
+	if err != nil || again.ID != u.ID {
+		t.Fatalf("second login = (%v, %v), want the same account", again, err)
+	}
+}
+
+func TestOIDCRoleExists(t *testing.T) {
+	db := newTestDB(t, &models.Role{}, &models.RolePermission{})
+	roles := repository.NewRoleRepository(db)
+	if _, err := roles.Save(&models.Role{Name: "auditor"}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name  string
+		repo  *repository.RoleRepository
+		role  string
+		exist bool
+	}{
+		{"rol creado", roles, "auditor", true},
+		{"rol borrado o mal escrito", roles, "superviser", false},
+		{"sin repositorio, rol por defecto", nil, "supervisor", true},
+		{"sin repositorio, rol desconocido", nil, "auditor", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := &AuthHandler{RoleRepository: c.repo}
+			got, err := h.oidcRoleExists(c.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.exist {
+				t.Errorf("oidcRoleExists(%q) = %v, want %v", c.role, got, c.exist)
+			}
+		})
+	}
+}
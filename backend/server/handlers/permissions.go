@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"net/http"
+	"slices"
+)
+
+// hasPermission consulta el verificador de permisos del servidor (usuario o
+// clave de API) y, si el handler no lo tiene, los permisos por defecto del
+// rol de la petición.
+func hasPermission(r *http.Request, check func(*http.Request, string) bool, role func(*http.Request) string, perm string) bool {
+	if check != nil {
+		return check(r, perm)
+	}
+	return role != nil && slices.Contains(models.DefaultRoles[role(r)], perm)
+}
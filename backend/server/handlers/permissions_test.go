@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHasPermission(t *testing.T) {
+	role := func(name string) func(*http.Request) string {
+		return func(*http.Request) string { return name }
+	}
+	// Verificador que solo concede lo que figura en la lista, como una clave
+	// de API o un rol personalizado.
+	grants := func(perms ...string) func(*http.Request, string) bool {
+		return func(_ *http.Request, perm string) bool {
+			for _, p := range perms {
+				if p == perm {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	tests := []struct {
+		name  string
+		check func(*http.Request, string) bool
+		role  func(*http.Request) string
+		perm  string
+		want  bool
+	}{
+		{"supervisor por defecto", nil, role("supervisor"), models.PermAlchemistWrite, true},
+		{"alquimista por defecto sin override", nil, role("alchemist"), models.PermAlchemistWrite, false},
+		{"alquimista por defecto con su propio permiso", nil, role("alchemist"), models.PermAvailabilityWrite, true},
+		{"rol desconocido sin verificador", nil, role("auditor"), models.PermMissionWrite, false},
+		{"sin rol ni verificador", nil, nil, models.PermMissionWrite, false},
+		{"rol personalizado con el permiso", grants(models.PermSkillWrite), role("auditor"), models.PermSkillWrite, true},
+		{"el verificador manda sobre el nombre del rol", grants(), role("supervisor"), models.PermPromotionReview, false},
+		{"clave de API con el permiso", grants(models.PermTransmutationAny), role(""), models.PermTransmutationAny, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if got := hasPermission(r, tt.check, tt.role, tt.perm); got != tt.want {
+				t.Errorf("hasPermission(%s) = %v, want %v", tt.perm, got, tt.want)
+			}
+		})
+	}
+}
+
+// Cada override de "actuar sobre otros" depende de un permiso concreto y no
+// del nombre del rol.
+func TestOverridesFollowPermissions(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	none := func(*http.Request, string) bool { return false }
+	all := func(*http.Request, string) bool { return true }
+	supervisor := func(*http.Request) string { return "supervisor" }
+
+	overrides := []struct {
+		name string
+		can  func(check func(*http.Request, string) bool) bool
+	}{
+		{"availability", func(c func(*http.Request, string) bool) bool {
+			return (&AvailabilityHandler{HasPermission: c, CurrentRole: supervisor}).canManage(r, 1)
+		}},
+		{"skills", func(c func(*http.Request, string) bool) bool {
+			return (&SkillHandler{HasPermission: c, CurrentRole: supervisor}).canActForOthers(r)
+		}},
+		{"promotions", func(c func(*http.Request, string) bool) bool {
+			return (&PromotionHandler{HasPermission: c, CurrentRole: supervisor}).canActForOthers(r)
+		}},
+		{"attachments", func(c func(*http.Request, string) bool) bool {
+			return (&AttachmentHandler{HasPermission: c, CurrentRole: supervisor}).can(r, entityPermission(models.AttachmentMission))
+		}},
+		{"comments", func(c func(*http.Request, string) bool) bool {
+			return (&MissionCommentHandler{HasPermission: c, CurrentRole: supervisor}).canModerate(r)
+		}},
+		{"transmutations", func(c func(*http.Request, string) bool) bool {
+			return (&TransmutationHandler{HasPermission: c, CurrentRole: supervisor}).canCreateForAnyone(r)
+		}},
+	}
+	for _, o := range overrides {
+		t.Run(o.name, func(t *testing.T) {
+			if o.can(none) {
+				t.Error("supervisor role without the permission was allowed")
+			}
+			if !o.can(all) {
+				t.Error("holder of the permission was denied")
+			}
+		})
+	}
+}
+
+func TestEntityPermission(t *testing.T) {
+	if got := entityPermission(models.AttachmentTransmutation); got != models.PermTransmutationWrite {
+		t.Errorf("transmutation attachments: got %s", got)
+	}
+	if got := entityPermission(models.AttachmentMission); got != models.PermMissionWrite {
+		t.Errorf("mission attachments: got %s", got)
+	}
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultWeeklyCapacityHours = 40
+	DefaultMissionHours        = 8
+	DefaultTransmutationHours  = 2
+	planningWeek               = 7 * 24 * time.Hour
+)
+
+// PlanningHandler calcula la carga de trabajo comprometida de cada alquimista
+// frente a su capacidad semanal.
+type PlanningHandler struct {
+	AlchemistRepo     *repository.AlchemistRepository
+	MissionRepo       *repository.MissionRepository
+	TransmutationRepo *repository.TransmutationRepository
+	AvailabilityRepo  *repository.AvailabilityRepository
+	Scope             *DivisionScope
+	HandleErr         func(http.ResponseWriter, int, string, error)
+	Log               func(int, string, time.Time)
+	// Estimaciones configurables (ver NewPlanningHandler para los valores por defecto).
+	WeeklyCapacityHours float64
+	HoursByDifficulty   map[string]float64
+	MissionHours        float64 // Dificultad desconocida o vacía
+	TransmutationHours  float64
+}
+
+func NewPlanningHandler(
+	alchemistRepo *repository.AlchemistRepository,
+	missionRepo *repository.MissionRepository,
+	transmutationRepo *repository.TransmutationRepository,
+	availabilityRepo *repository.AvailabilityRepository,
+	scope *DivisionScope,
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *PlanningHandler {
+	return &PlanningHandler{
+		AlchemistRepo:       alchemistRepo,
+		MissionRepo:         missionRepo,
+		TransmutationRepo:   transmutationRepo,
+		AvailabilityRepo:    availabilityRepo,
+		Scope:               scope,
+		HandleErr:           handleErr,
+		Log:                 log,
+		WeeklyCapacityHours: DefaultWeeklyCapacityHours,
+		HoursByDifficulty:   map[string]float64{"baja": 4, "media": 12, "alta": 24},
+		MissionHours:        DefaultMissionHours,
+		TransmutationHours:  DefaultTransmutationHours,
+	}
+}
+
+func (h *PlanningHandler) missionEstimate(difficulty string) float64 {
+	if hours, ok := h.HoursByDifficulty[strings.ToLower(strings.TrimSpace(difficulty))]; ok {
+		return hours
+	}
+	return h.MissionHours
+}
+
+// missionShare devuelve la fracción de la misión que debe completarse antes de
+// "to" suponiendo un ritmo uniforme hasta su fecha límite. Las misiones sin
+// fecha límite, vencidas o que vencen dentro del periodo cuentan enteras.
+func missionShare(m *models.Mission, from, to, now time.Time) float64 {
+	if m.DueDate == nil || !m.DueDate.After(to) {
+		return 1
+	}
+	start := from
+	if now.After(start) {
+		start = now
+	}
+	if !to.After(start) {
+		return 0
+	}
+	return float64(to.Sub(start)) / float64(m.DueDate.Sub(start))
+}
+
+// unavailable suma la duración de las ventanas dentro de [from, to] sin contar
+// dos veces los solapamientos. ws debe estar ordenado por StartsAt.
+func unavailable(ws []*models.AvailabilityWindow, from, to time.Time) time.Duration {
+	var total time.Duration
+	var cursor time.Time
+	for _, w := range ws {
+		start, end := w.StartsAt, w.EndsAt
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if start.Before(cursor) {
+			start = cursor
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+			cursor = end
+		}
+	}
+	return total
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// GET /planning/capacity?from=&to=[&division_id=][&overloaded=true]
+// Sin parámetros cubre la semana que empieza ahora.
+func (h *PlanningHandler) Capacity(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	from, to, err := parseRange(r, false)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	now := time.Now()
+	if from.IsZero() {
+		from = now
+		if !to.IsZero() && to.Before(from) {
+			from = to.Add(-planningWeek)
+		}
+	}
+	if to.IsZero() {
+		to = from.Add(planningWeek)
+	}
+	if !to.After(from) {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("to must be after from"))
+		return
+	}
+
+	var divisions []uint
+	if raw := r.URL.Query().Get("division_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id <= 0 {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("invalid division_id"))
+			return
+		}
+		if h.Scope != nil {
+			if divisions, err = h.Scope.Repo.WithDescendants(uint(id)); err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+		} else {
+			divisions = []uint{uint(id)}
+		}
+	}
+	// Los supervisores de división solo ven a su gente.
+	scoped, err := h.Scope.Divisions(r)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	visible := func(a *models.Alchemist) bool {
+		if divisions != nil && (a.DivisionID == nil || !slices.Contains(divisions, *a.DivisionID)) {
+			return false
+		}
+		return scoped == nil || (a.DivisionID != nil && slices.Contains(scoped, *a.DivisionID))
+	}
+
+	alchs, err := h.AlchemistRepo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	missions, err := h.MissionRepo.FindOpenAssigned()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	queued, err := h.TransmutationRepo.FindQueued()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	windows := map[uint][]*models.AvailabilityWindow{}
+	if h.AvailabilityRepo != nil {
+		ws, err := h.AvailabilityRepo.FindAllOverlapping(from, to)
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		for _, win := range ws {
+			windows[win.AlchemistID] = append(windows[win.AlchemistID], win)
+		}
+	}
+
+	byID := map[uint]*api.AlchemistCapacityDto{}
+	rows := make([]*api.AlchemistCapacityDto, 0, len(alchs))
+	for _, a := range alchs {
+		if !visible(a) {
+			continue
+		}
+		weekly := a.WeeklyCapacityHours
+		if weekly == 0 {
+			weekly = h.WeeklyCapacityHours
+		}
+		off := unavailable(windows[a.ID], from, to)
+		row := &api.AlchemistCapacityDto{
+			AlchemistID:         a.ID,
+			Name:                a.Name,
+			DivisionID:          a.DivisionID,
+			WeeklyCapacityHours: weekly,
+			UnavailableHours:    round2(off.Hours()),
+			CapacityHours:       round2(weekly * float64(to.Sub(from)-off) / float64(planningWeek)),
+			OpenMissions:        []api.PlannedMissionDto{},
+		}
+		byID[a.ID] = row
+		rows = append(rows, row)
+	}
+
+	for _, m := range missions {
+		row := byID[m.AssignedTo]
+		if row == nil {
+			continue
+		}
+		hours := round2(h.missionEstimate(m.Difficulty) * missionShare(m, from, to, now))
+		if hours == 0 {
+			continue
+		}
+		planned := api.PlannedMissionDto{ID: int(m.ID), Title: m.Title, Difficulty: m.Difficulty, Hours: hours}
+		if m.DueDate != nil {
+			planned.DueDate = m.DueDate.Format(time.RFC3339)
+		}
+		row.OpenMissions = append(row.OpenMissions, planned)
+		row.MissionHours += hours
+	}
+	for _, t := range queued {
+		row := byID[t.AlchemistID]
+		// Las programadas después del periodo no consumen capacidad en él.
+		if row == nil || (t.ScheduledAt != nil && t.ScheduledAt.After(to)) {
+			continue
+		}
+		row.QueuedTransmutations++
+		row.TransmutationHours += h.TransmutationHours
+	}
+
+	overloadedOnly := r.URL.Query().Get("overloaded") == "true"
+	resp := &api.CapacityResponseDto{
+		From:       from.Format(time.RFC3339),
+		To:         to.Format(time.RFC3339),
+		Alchemists: make([]*api.AlchemistCapacityDto, 0, len(rows)),
+	}
+	for _, row := range rows {
+		row.MissionHours = round2(row.MissionHours)
+		row.TransmutationHours = round2(row.TransmutationHours)
+		row.CommittedHours = round2(row.MissionHours + row.TransmutationHours)
+		if row.CapacityHours > 0 {
+			row.Utilization = round2(row.CommittedHours / row.CapacityHours)
+		}
+		row.Overloaded = row.CommittedHours > row.CapacityHours
+		if row.Overloaded {
+			resp.Overloaded++
+		} else if overloadedOnly {
+			continue
+		}
+		resp.Alchemists = append(resp.Alchemists, row)
+	}
+	// Primero los más cargados; quien tiene trabajo sin capacidad va arriba.
+	load := func(row *api.AlchemistCapacityDto) float64 {
+		if row.CapacityHours == 0 && row.CommittedHours > 0 {
+			return math.Inf(1)
+		}
+		return row.Utilization
+	}
+	sort.SliceStable(resp.Alchemists, func(i, j int) bool {
+		return load(resp.Alchemists[i]) > load(resp.Alchemists[j])
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// PromotionHandler implementa el flujo de ascensos: escala de rangos,
+// solicitudes con justificación, aprobación por supervisores, sugerencias de
+// elegibilidad e historial de rangos.
+type PromotionHandler struct {
+	Repo              *repository.PromotionRepository
+	AlchemistRepo     *repository.AlchemistRepository
+	MissionRepo       *repository.MissionRepository
+	TransmutationRepo *repository.TransmutationRepository
+	Dispatcher        AsyncDispatcher
+	CurrentUser       func(*http.Request) string
+	CurrentRole       func(*http.Request) string
+	CurrentAlchemist  func(*http.Request) uint
+	ReportAsyncError  func(string, error)
+	HandleErr         func(http.ResponseWriter, int, string, error)
+	Log               func(int, string, time.Time)
+	// HasPermission resuelve permisos del usuario o de la clave de API.
+	HasPermission func(*http.Request, string) bool
+}
+
+func NewPromotionHandler(
+	repo *repository.PromotionRepository,
+	alchemistRepo *repository.AlchemistRepository,
+	missionRepo *repository.MissionRepository,
+	transmutationRepo *repository.TransmutationRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	currentRole func(*http.Request) string,
+	currentAlchemist func(*http.Request) uint,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *PromotionHandler {
+	return &PromotionHandler{
+		Repo:              repo,
+		AlchemistRepo:     alchemistRepo,
+		MissionRepo:       missionRepo,
+		TransmutationRepo: transmutationRepo,
+		Dispatcher:        dispatcher,
+		CurrentUser:       currentUser,
+		CurrentRole:       currentRole,
+		CurrentAlchemist:  currentAlchemist,
+		ReportAsyncError:  reportAsyncError,
+		HandleErr:         handleErr,
+		Log:               log,
+	}
+}
+
+func (h *PromotionHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+// canActForOthers indica si se puede actuar en nombre de cualquier alquimista
+// y no solo del propio.
+func (h *PromotionHandler) canActForOthers(r *http.Request) bool {
+	return hasPermission(r, h.HasPermission, h.CurrentRole, models.PermPromotionReview)
+}
+
+func (h *PromotionHandler) audit(r *http.Request, action string, id uint, details string) {
+	if h.Dispatcher == nil {
+		return
+	}
+	if err := h.Dispatcher.EnqueueAudit(action, "alchemist", id, h.userEmail(r), details); err != nil {
+		h.ReportAsyncError(r.URL.Path, err)
+	}
+}
+
+func promotionResponse(p *models.PromotionRequest) *api.PromotionResponseDto {
+	resp := &api.PromotionResponseDto{
+		ID:                       int(p.ID),
+		AlchemistID:              p.AlchemistID,
+		FromRank:                 p.FromRank,
+		ToRank:                   p.ToRank,
+		Justification:            p.Justification,
+		RequestedBy:              p.RequestedBy,
+		Status:                   p.Status,
+		CompletedMissions:        p.CompletedMissions,
+		SuccessfulTransmutations: p.SuccessfulTransmutations,
+		Eligible:                 p.Eligible,
+		ReviewedBy:               p.ReviewedBy,
+		ReviewComment:            p.ReviewComment,
+		CreatedAt:                p.CreatedAt.Format(time.RFC3339),
+	}
+	if p.ReviewedAt != nil {
+		resp.ReviewedAt = p.ReviewedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// eligibility calcula los méritos de cada alquimista respecto al siguiente
+// peldaño de la escala.
+func (h *PromotionHandler) eligibility(alchs []*models.Alchemist) ([]*api.PromotionEligibilityDto, error) {
+	ids := make([]uint, 0, len(alchs))
+	for _, a := range alchs {
+		ids = append(ids, a.ID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	missions, err := h.MissionRepo.CountCompletedByAssignee(ids...)
+	if err != nil {
+		return nil, err
+	}
+	transmutations, err := h.TransmutationRepo.CountCompletedByAlchemist(ids...)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := h.Repo.PendingAlchemistIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*api.PromotionEligibilityDto, 0, len(alchs))
+	for _, a := range alchs {
+		e := &api.PromotionEligibilityDto{
+			AlchemistID:              a.ID,
+			Name:                     a.Name,
+			CurrentRank:              a.Rank,
+			CompletedMissions:        missions[a.ID],
+			SuccessfulTransmutations: transmutations[a.ID],
+			PendingRequest:           pending[a.ID],
+		}
+		if next, ok := models.NextRank(a.Rank); ok {
+			e.NextRank = next.Name
+			e.RequiredMissions = next.MinCompletedMissions
+			e.RequiredTransmutations = next.MinSuccessfulTransmutations
+			e.Eligible = e.CompletedMissions >= next.MinCompletedMissions &&
+				e.SuccessfulTransmutations >= next.MinSuccessfulTransmutations
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func (h *PromotionHandler) alchemist(w http.ResponseWriter, r *http.Request) *models.Alchemist {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	a, err := h.AlchemistRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return nil
+	}
+	return a
+}
+
+func (h *PromotionHandler) promotion(w http.ResponseWriter, r *http.Request) *models.PromotionRequest {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	p, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if p == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("promotion request not found"))
+		return nil
+	}
+	return p
+}
+
+// GET /ranks
+func (h *PromotionHandler) Ladder(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	resp := make([]api.RankDto, 0, len(models.RankLadder))
+	for i, rank := range models.RankLadder {
+		resp = append(resp, api.RankDto{
+			Name:                        rank.Name,
+			Position:                    i + 1,
+			MinCompletedMissions:        rank.MinCompletedMissions,
+			MinSuccessfulTransmutations: rank.MinSuccessfulTransmutations,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /alchemists/{id}/eligibility
+func (h *PromotionHandler) Eligibility(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	es, err := h.eligibility([]*models.Alchemist{a})
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": es[0]})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /promotions/suggestions lista los alquimistas que ya cumplen los
+// méritos del siguiente rango y no tienen una solicitud abierta.
+func (h *PromotionHandler) Suggestions(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	alchs, err := h.AlchemistRepo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	es, err := h.eligibility(alchs)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := []*api.PromotionEligibilityDto{}
+	for _, e := range es {
+		if e.Eligible && !e.PendingRequest {
+			resp = append(resp, e)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /promotions[?status=pendiente]
+func (h *PromotionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ps, err := h.Repo.FindAll(r.URL.Query().Get("status"))
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.PromotionResponseDto, 0, len(ps))
+	for _, p := range ps {
+		resp = append(resp, promotionResponse(p))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /alchemists/{id}/promotions
+func (h *PromotionHandler) GetByAlchemist(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	ps, err := h.Repo.FindByAlchemist(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.PromotionResponseDto, 0, len(ps))
+	for _, p := range ps {
+		resp = append(resp, promotionResponse(p))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /alchemists/{id}/promotions abre una solicitud de ascenso al siguiente
+// peldaño. Puede hacerlo el propio alquimista o un supervisor.
+func (h *PromotionHandler) Request(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	if !h.canActForOthers(r) && (h.CurrentAlchemist == nil || h.CurrentAlchemist(r) != a.ID) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("alchemists can only request their own promotion"))
+		return
+	}
+
+	var req api.PromotionRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	next, ok := models.NextRank(a.Rank)
+	if !ok {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("alchemist already holds the highest rank"))
+		return
+	}
+	if req.ToRank != "" && req.ToRank != next.Name {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("promotions go one rank at a time: next rank is %q", next.Name))
+		return
+	}
+
+	pending, err := h.Repo.FindPending(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if pending != nil {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("promotion request %d is already pending", pending.ID))
+		return
+	}
+
+	es, err := h.eligibility([]*models.Alchemist{a})
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	p := &models.PromotionRequest{
+		AlchemistID:              a.ID,
+		FromRank:                 a.Rank,
+		ToRank:                   next.Name,
+		Justification:            req.Justification,
+		RequestedBy:              h.userEmail(r),
+		Status:                   models.PromotionPending,
+		CompletedMissions:        es[0].CompletedMissions,
+		SuccessfulTransmutations: es[0].SuccessfulTransmutations,
+		Eligible:                 es[0].Eligible,
+	}
+	if p, err = h.Repo.Save(p); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "request_promotion", a.ID, fmt.Sprintf("Solicitud de ascenso %d a %s", p.ID, p.ToRank))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": promotionResponse(p)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+func (h *PromotionHandler) review(w http.ResponseWriter, r *http.Request, approve bool) {
+	start := time.Now()
+	p := h.promotion(w, r)
+	if p == nil {
+		return
+	}
+	if p.Status != models.PromotionPending {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("promotion request is already %s", p.Status))
+		return
+	}
+	reviewer := h.userEmail(r)
+	if reviewer == p.RequestedBy {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("a promotion request cannot be reviewed by its requester"))
+		return
+	}
+
+	var req api.PromotionReviewRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	now := time.Now()
+	if approve {
+		if err := h.Repo.Approve(p, reviewer, req.Comment, now); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, repository.ErrRankChanged) {
+				status = http.StatusConflict
+			}
+			h.HandleErr(w, status, r.URL.Path, err)
+			return
+		}
+		h.audit(r, "approve_promotion", p.AlchemistID, fmt.Sprintf("Ascenso de %q a %q (solicitud %d)", p.FromRank, p.ToRank, p.ID))
+	} else {
+		if req.Comment == "" {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("comment required when rejecting"))
+			return
+		}
+		p.Status = models.PromotionRejected
+		p.ReviewedBy = reviewer
+		p.ReviewedAt = &now
+		p.ReviewComment = req.Comment
+		if _, err := h.Repo.Save(p); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		h.audit(r, "reject_promotion", p.AlchemistID, fmt.Sprintf("Solicitud de ascenso %d rechazada", p.ID))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": promotionResponse(p)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// POST /promotions/{id}/approve
+func (h *PromotionHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, true)
+}
+
+// POST /promotions/{id}/reject
+func (h *PromotionHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, false)
+}
+
+// GET /alchemists/{id}/rank-history
+func (h *PromotionHandler) History(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	changes, err := h.Repo.FindRankHistory(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]api.RankChangeResponseDto, 0, len(changes))
+	for _, c := range changes {
+		resp = append(resp, api.RankChangeResponseDto{
+			FromRank:           c.FromRank,
+			ToRank:             c.ToRank,
+			PromotionRequestID: c.PromotionRequestID,
+			ChangedBy:          c.ChangedBy,
+			Reason:             c.Reason,
+			CreatedAt:          c.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RoleHandler gestiona los roles y los permisos que conceden.
+type RoleHandler struct {
+	Repo             *repository.RoleRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	CurrentRole      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+	// OnChange se llama tras modificar un rol (p. ej. para invalidar cachés).
+	OnChange func()
+}
+
+func NewRoleHandler(
+	repo *repository.RoleRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	currentRole func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *RoleHandler {
+	return &RoleHandler{
+		Repo:             repo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		CurrentRole:      currentRole,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *RoleHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func (h *RoleHandler) changed() {
+	if h.OnChange != nil {
+		h.OnChange()
+	}
+}
+
+func roleResponse(role *models.Role) *api.RoleResponseDto {
+	perms := role.PermissionNames()
+	slices.Sort(perms)
+	return &api.RoleResponseDto{
+		ID:          int(role.ID),
+		Name:        role.Name,
+		Description: role.Description,
+		System:      role.System,
+		Permissions: perms,
+		CreatedAt:   role.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func rolePermissions(perms []string) []models.RolePermission {
+	out := make([]models.RolePermission, 0, len(perms))
+	for _, p := range perms {
+		out = append(out, models.RolePermission{Permission: p})
+	}
+	return out
+}
+
+func (h *RoleHandler) role(w http.ResponseWriter, r *http.Request) *models.Role {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	role, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if role == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("role not found"))
+		return nil
+	}
+	return role
+}
+
+// GET /permissions
+func (h *RoleHandler) Permissions(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": models.Permissions})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /roles
+func (h *RoleHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	roles, err := h.Repo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.RoleResponseDto, 0, len(roles))
+	for _, role := range roles {
+		resp = append(resp, roleResponse(role))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /roles/{id}
+func (h *RoleHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	role := h.role(w, r)
+	if role == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": roleResponse(role)})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /roles
+func (h *RoleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.RoleRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	existing, err := h.Repo.FindByName(req.Name)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if existing != nil {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("role %q already exists", req.Name))
+		return
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: rolePermissions(req.Permissions),
+	}
+	if role, err = h.Repo.Save(role); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.changed()
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("create", "role", role.ID, h.userEmail(r), fmt.Sprintf("Creación del rol %q", role.Name)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": roleResponse(role)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// PUT /roles/{id}. El nombre no cambia porque los usuarios lo referencian.
+func (h *RoleHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	role := h.role(w, r)
+	if role == nil {
+		return
+	}
+	var req api.RoleEditRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		// Evita que quien administra los roles se quede sin poder hacerlo.
+		if h.CurrentRole != nil && h.CurrentRole(r) == role.Name && !slices.Contains(*req.Permissions, models.PermRoleManage) {
+			h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("cannot remove %s from your own role", models.PermRoleManage))
+			return
+		}
+		role.Permissions = rolePermissions(*req.Permissions)
+	}
+	role, err := h.Repo.Save(role)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.changed()
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("update", "role", role.ID, h.userEmail(r), fmt.Sprintf("Actualización del rol %q", role.Name)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": roleResponse(role)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// DELETE /roles/{id}. Solo roles propios sin usuarios asignados.
+func (h *RoleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	role := h.role(w, r)
+	if role == nil {
+		return
+	}
+	if role.System {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("role %q is a system role", role.Name))
+		return
+	}
+	users, err := h.Repo.CountUsers(role.Name)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if users > 0 {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("role %q is assigned to %d users", role.Name, users))
+		return
+	}
+	if err := h.Repo.Delete(role); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.changed()
+	if h.Dispatcher != nil {
+		if err := h.Dispatcher.EnqueueAudit("delete", "role", role.ID, h.userEmail(r), fmt.Sprintf("Eliminación del rol %q", role.Name)); err != nil {
+			h.ReportAsyncError(r.URL.Path, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,579 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// SkillHandler gestiona el catálogo de habilidades, el nivel de cada
+// alquimista y los requisitos de habilidad de misiones y materiales.
+type SkillHandler struct {
+	Repo             *repository.SkillRepository
+	AlchemistRepo    *repository.AlchemistRepository
+	MissionRepo      *repository.MissionRepository
+	MaterialRepo     *repository.MaterialRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	CurrentRole      func(*http.Request) string
+	CurrentAlchemist func(*http.Request) uint
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+	// HasPermission resuelve permisos del usuario o de la clave de API.
+	HasPermission func(*http.Request, string) bool
+}
+
+func NewSkillHandler(
+	repo *repository.SkillRepository,
+	alchemistRepo *repository.AlchemistRepository,
+	missionRepo *repository.MissionRepository,
+	materialRepo *repository.MaterialRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	currentRole func(*http.Request) string,
+	currentAlchemist func(*http.Request) uint,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *SkillHandler {
+	return &SkillHandler{
+		Repo:             repo,
+		AlchemistRepo:    alchemistRepo,
+		MissionRepo:      missionRepo,
+		MaterialRepo:     materialRepo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		CurrentRole:      currentRole,
+		CurrentAlchemist: currentAlchemist,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *SkillHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+// canActForOthers indica si se puede actuar en nombre de cualquier alquimista
+// y no solo del propio.
+func (h *SkillHandler) canActForOthers(r *http.Request) bool {
+	return hasPermission(r, h.HasPermission, h.CurrentRole, models.PermSkillWrite)
+}
+
+func (h *SkillHandler) audit(r *http.Request, action, entity string, id uint, details string) {
+	if h.Dispatcher == nil {
+		return
+	}
+	if err := h.Dispatcher.EnqueueAudit(action, entity, id, h.userEmail(r), details); err != nil {
+		h.ReportAsyncError(r.URL.Path, err)
+	}
+}
+
+func normalizeSkillName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func skillResponse(s *models.Skill) *api.SkillResponseDto {
+	return &api.SkillResponseDto{
+		ID:          int(s.ID),
+		Name:        s.Name,
+		Description: s.Description,
+		CreatedAt:   s.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func alchemistSkillResponse(s *models.AlchemistSkill) *api.AlchemistSkillResponseDto {
+	resp := &api.AlchemistSkillResponseDto{
+		AlchemistID:  s.AlchemistID,
+		SkillID:      s.SkillID,
+		Skill:        s.Skill.Name,
+		Level:        s.Level,
+		Endorsements: make([]api.SkillEndorsementDto, 0, len(s.Endorsements)),
+		UpdatedBy:    s.UpdatedBy,
+		UpdatedAt:    s.UpdatedAt.Format(time.RFC3339),
+	}
+	for _, e := range s.Endorsements {
+		resp.Endorsements = append(resp.Endorsements, api.SkillEndorsementDto{
+			EndorsedBy: e.EndorsedBy,
+			Level:      e.Level,
+			Comment:    e.Comment,
+			CreatedAt:  e.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	resp.Endorsed = len(resp.Endorsements) > 0
+	return resp
+}
+
+func requirementsResponse(entity string, entityID uint, reqs []*models.SkillRequirement) *api.SkillRequirementsResponseDto {
+	resp := &api.SkillRequirementsResponseDto{
+		Entity:   entity,
+		EntityID: entityID,
+		Skills:   make([]api.SkillRequirementDto, 0, len(reqs)),
+	}
+	for _, req := range reqs {
+		resp.Skills = append(resp.Skills, api.SkillRequirementDto{
+			SkillID:  req.SkillID,
+			Skill:    req.Skill.Name,
+			MinLevel: req.MinLevel,
+		})
+	}
+	return resp
+}
+
+// describeUnmet formatea los requisitos incumplidos para los mensajes de error.
+func describeUnmet(unmet []*models.SkillRequirement) string {
+	parts := make([]string, 0, len(unmet))
+	for _, req := range unmet {
+		name := req.Skill.Name
+		if name == "" {
+			name = "#" + strconv.Itoa(int(req.SkillID))
+		}
+		parts = append(parts, fmt.Sprintf("%s >= %d", name, req.MinLevel))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *SkillHandler) skill(w http.ResponseWriter, r *http.Request, key string) *models.Skill {
+	id, err := strconv.Atoi(mux.Vars(r)[key])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	s, err := h.Repo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if s == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("skill not found"))
+		return nil
+	}
+	return s
+}
+
+func (h *SkillHandler) alchemist(w http.ResponseWriter, r *http.Request) *models.Alchemist {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	a, err := h.AlchemistRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return nil
+	}
+	return a
+}
+
+// ===================== Catálogo =====================
+
+// GET /skills
+func (h *SkillHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	skills, err := h.Repo.FindAll()
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.SkillResponseDto, 0, len(skills))
+	for _, s := range skills {
+		resp = append(resp, skillResponse(s))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// POST /skills
+func (h *SkillHandler) Create(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req api.SkillRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	name := normalizeSkillName(req.Name)
+	existing, err := h.Repo.FindByName(name)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if existing != nil {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("skill %q already exists", name))
+		return
+	}
+
+	s, err := h.Repo.Save(&models.Skill{Name: name, Description: req.Description})
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "create", "skill", s.ID, "Nueva habilidad en el catálogo: "+s.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": skillResponse(s)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// PUT /skills/{id}
+func (h *SkillHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	s := h.skill(w, r, "id")
+	if s == nil {
+		return
+	}
+	var req api.SkillEditRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	if req.Name != nil {
+		name := normalizeSkillName(*req.Name)
+		if name == "" {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("name required"))
+			return
+		}
+		if name != s.Name {
+			existing, err := h.Repo.FindByName(name)
+			if err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			if existing != nil {
+				h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("skill %q already exists", name))
+				return
+			}
+		}
+		s.Name = name
+	}
+	if req.Description != nil {
+		s.Description = *req.Description
+	}
+
+	s, err := h.Repo.Save(s)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "update", "skill", s.ID, "Actualización de habilidad")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": skillResponse(s)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// DELETE /skills/{id}
+func (h *SkillHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	s := h.skill(w, r, "id")
+	if s == nil {
+		return
+	}
+	if err := h.Repo.Delete(s); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "delete", "skill", s.ID, "Eliminación de habilidad: "+s.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ===================== Niveles por alquimista =====================
+
+// GET /alchemists/{id}/skills
+func (h *SkillHandler) GetByAlchemist(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	skills, err := h.Repo.FindByAlchemist(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.AlchemistSkillResponseDto, 0, len(skills))
+	for _, s := range skills {
+		resp = append(resp, alchemistSkillResponse(s))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// PUT /alchemists/{id}/skills/{skillId} fija el nivel. Los supervisores pueden
+// hacerlo para cualquiera; cada alquimista puede declarar el suyo propio.
+func (h *SkillHandler) SetLevel(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	if !h.canActForOthers(r) && (h.CurrentAlchemist == nil || h.CurrentAlchemist(r) != a.ID) {
+		h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("alchemists can only declare their own skills"))
+		return
+	}
+	skill := h.skill(w, r, "skillId")
+	if skill == nil {
+		return
+	}
+
+	var req api.AlchemistSkillRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	s, err := h.Repo.FindAlchemistSkill(a.ID, skill.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	status := http.StatusAccepted
+	if s == nil {
+		s = &models.AlchemistSkill{AlchemistID: a.ID, SkillID: skill.ID}
+		status = http.StatusCreated
+	}
+	changed := s.Level != req.Level
+	s.Level = req.Level
+	s.UpdatedBy = h.userEmail(r)
+	s.Skill = *skill
+	if s, err = h.Repo.SaveAlchemistSkill(s, changed); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "set_skill", "alchemist", a.ID, fmt.Sprintf("Nivel %d en %s", s.Level, skill.Name))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"data": alchemistSkillResponse(s)})
+	h.Log(status, r.URL.Path, start)
+}
+
+// DELETE /alchemists/{id}/skills/{skillId}
+func (h *SkillHandler) RemoveLevel(w http.ResponseWriter, r *http.Request) {
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	skill := h.skill(w, r, "skillId")
+	if skill == nil {
+		return
+	}
+	s, err := h.Repo.FindAlchemistSkill(a.ID, skill.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if s == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist does not have this skill"))
+		return
+	}
+	if err := h.Repo.DeleteAlchemistSkill(s); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "remove_skill", "alchemist", a.ID, "Habilidad retirada: "+skill.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /alchemists/{id}/skills/{skillId}/endorsements
+func (h *SkillHandler) Endorse(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	a := h.alchemist(w, r)
+	if a == nil {
+		return
+	}
+	skill := h.skill(w, r, "skillId")
+	if skill == nil {
+		return
+	}
+	s, err := h.Repo.FindAlchemistSkill(a.ID, skill.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if s == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist does not have this skill"))
+		return
+	}
+
+	var req api.SkillEndorsementRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	email := h.userEmail(r)
+	for _, e := range s.Endorsements {
+		if e.EndorsedBy == email {
+			h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("skill level already endorsed by this supervisor"))
+			return
+		}
+	}
+	e := &models.SkillEndorsement{
+		AlchemistSkillID: s.ID,
+		EndorsedBy:       email,
+		Level:            s.Level,
+		Comment:          req.Comment,
+	}
+	if _, err := h.Repo.SaveEndorsement(e); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	s.Endorsements = append(s.Endorsements, *e)
+	h.audit(r, "endorse_skill", "alchemist", a.ID, fmt.Sprintf("Respaldo de nivel %d en %s", s.Level, skill.Name))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"data": alchemistSkillResponse(s)})
+	h.Log(http.StatusCreated, r.URL.Path, start)
+}
+
+// ===================== Requisitos =====================
+
+// requirementTarget resuelve la misión o el material de la ruta y devuelve su ID.
+func (h *SkillHandler) requirementTarget(w http.ResponseWriter, r *http.Request, entity string) (uint, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return 0, false
+	}
+	var found bool
+	switch entity {
+	case models.SkillRequirementMission:
+		m, ferr := h.MissionRepo.FindById(id)
+		err, found = ferr, m != nil
+	case models.SkillRequirementMaterial:
+		m, ferr := h.MaterialRepo.FindById(id)
+		err, found = ferr, m != nil
+	}
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return 0, false
+	}
+	if !found {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, fmt.Errorf("%s not found", entity))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *SkillHandler) getRequirements(w http.ResponseWriter, r *http.Request, entity string) {
+	start := time.Now()
+	id, ok := h.requirementTarget(w, r, entity)
+	if !ok {
+		return
+	}
+	reqs, err := h.Repo.FindRequirements(entity, id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": requirementsResponse(entity, id, reqs)})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+func (h *SkillHandler) setRequirements(w http.ResponseWriter, r *http.Request, entity string) {
+	start := time.Now()
+	id, ok := h.requirementTarget(w, r, entity)
+	if !ok {
+		return
+	}
+	var req api.SkillRequirementsRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+
+	reqs := make([]*models.SkillRequirement, 0, len(req.Skills))
+	for _, s := range req.Skills {
+		skill, err := h.Repo.FindById(int(s.SkillID))
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if skill == nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("skill %d not found", s.SkillID))
+			return
+		}
+		reqs = append(reqs, &models.SkillRequirement{SkillID: skill.ID, MinLevel: s.MinLevel, Skill: *skill})
+	}
+
+	if err := h.Repo.ReplaceRequirements(entity, id, reqs); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "set_skill_requirements", entity, id, fmt.Sprintf("%d requisitos de habilidad", len(reqs)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"data": requirementsResponse(entity, id, reqs)})
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// GET /missions/{id}/skills
+func (h *SkillHandler) GetMissionRequirements(w http.ResponseWriter, r *http.Request) {
+	h.getRequirements(w, r, models.SkillRequirementMission)
+}
+
+// PUT /missions/{id}/skills
+func (h *SkillHandler) SetMissionRequirements(w http.ResponseWriter, r *http.Request) {
+	h.setRequirements(w, r, models.SkillRequirementMission)
+}
+
+// GET /materials/{id}/skills
+func (h *SkillHandler) GetMaterialRequirements(w http.ResponseWriter, r *http.Request) {
+	h.getRequirements(w, r, models.SkillRequirementMaterial)
+}
+
+// PUT /materials/{id}/skills
+func (h *SkillHandler) SetMaterialRequirements(w http.ResponseWriter, r *http.Request) {
+	h.setRequirements(w, r, models.SkillRequirementMaterial)
+}
+
+// GET /missions/{id}/candidates lista los alquimistas que cumplen todos los
+// requisitos de habilidad de la misión.
+func (h *SkillHandler) MissionCandidates(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id, ok := h.requirementTarget(w, r, models.SkillRequirementMission)
+	if !ok {
+		return
+	}
+	reqs, err := h.Repo.FindRequirements(models.SkillRequirementMission, id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	ids, err := h.Repo.FindQualifiedAlchemistIDs(reqs)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	alchs, err := h.AlchemistRepo.FindByIds(ids)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.AlchemistResponseDto, 0, len(alchs))
+	for _, a := range alchs {
+		resp = append(resp, alchemistResponse(a))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
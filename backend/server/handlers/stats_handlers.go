@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// StatsHandler expone estadísticas de rendimiento por alquimista.
+type StatsHandler struct {
+	Repo          *repository.StatsRepository
+	AlchemistRepo *repository.AlchemistRepository
+	HandleErr     func(http.ResponseWriter, int, string, error)
+	Log           func(int, string, time.Time)
+}
+
+func NewStatsHandler(
+	repo *repository.StatsRepository,
+	alchemistRepo *repository.AlchemistRepository,
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *StatsHandler {
+	return &StatsHandler{
+		Repo:          repo,
+		AlchemistRepo: alchemistRepo,
+		HandleErr:     handleErr,
+		Log:           log,
+	}
+}
+
+// GET /alchemists/{id}/stats
+func (h *StatsHandler) Alchemist(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+	a, err := h.AlchemistRepo.FindById(id)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if a == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("alchemist not found"))
+		return
+	}
+
+	ts, err := h.Repo.Transmutations(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	ms, err := h.Repo.Missions(a.ID, start)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	materials, err := h.Repo.Materials(a.ID)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+
+	resp := &api.AlchemistStatsResponseDto{
+		AlchemistID: a.ID,
+		Name:        a.Name,
+		Transmutations: api.TransmutationStatsDto{
+			Total:                ts.Total,
+			Completed:            ts.Completed,
+			InProgress:           ts.InProgress,
+			Failed:               ts.Failed,
+			Cancelled:            ts.Cancelled,
+			AvgProcessingSeconds: ts.AvgProcessingSeconds,
+		},
+		Missions: api.MissionStatsDto{
+			Assigned:       ms.Assigned,
+			Completed:      ms.Completed,
+			OnTime:         ms.OnTime,
+			Late:           ms.Late,
+			WithoutDueDate: ms.WithoutDueDate,
+			Overdue:        ms.Overdue,
+		},
+		Materials:   make([]api.MaterialConsumptionDto, 0, len(materials)),
+		GeneratedAt: start.UTC().Format(time.RFC3339),
+	}
+	// Las canceladas no terminaron ni bien ni mal: quedan fuera de la tasa.
+	if finished := ts.Completed + ts.Failed; finished > 0 {
+		resp.Transmutations.SuccessRate = float64(ts.Completed) / float64(finished)
+	}
+	for _, m := range materials {
+		resp.Materials = append(resp.Materials, api.MaterialConsumptionDto{
+			MaterialID:      m.MaterialID,
+			Name:            m.Name,
+			Transmutations:  m.Transmutations,
+			MissionQuantity: m.MissionQuantity,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
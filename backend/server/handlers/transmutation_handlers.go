@@ -6,6 +6,7 @@ import (
 	"backend-avanzada/repository"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -15,25 +16,47 @@ import (
 
 type TransmutationHandler struct {
 	Repo             *repository.TransmutationRepository
+	CertRepo         *repository.CertificationRepository
+	SkillRepo        *repository.SkillRepository
+	AvailabilityRepo *repository.AvailabilityRepository
 	Dispatcher       AsyncDispatcher
 	CurrentUser      func(*http.Request) string
+	CurrentRole      func(*http.Request) string
+	CurrentAlchemist func(*http.Request) uint
 	ReportAsyncError func(string, error)
 	Log              func(status int, path string, start time.Time)
 	HandleErr        func(w http.ResponseWriter, statusCode int, path string, cause error)
+	// RequireActiveLicense exige una licencia vigente incluso a los
+	// alquimistas que nunca tuvieron una registrada.
+	RequireActiveLicense bool
+	// Scope restringe a los supervisores de división a sus propios recursos.
+	Scope *DivisionScope
+	// HasPermission resuelve permisos del usuario o de la clave de API.
+	HasPermission func(*http.Request, string) bool
 }
 
 func NewTransmutationHandler(
 	repo *repository.TransmutationRepository,
+	certRepo *repository.CertificationRepository,
+	skillRepo *repository.SkillRepository,
+	availabilityRepo *repository.AvailabilityRepository,
 	dispatcher AsyncDispatcher,
 	currentUser func(*http.Request) string,
+	currentRole func(*http.Request) string,
+	currentAlchemist func(*http.Request) uint,
 	reportAsyncError func(string, error),
 	handleErr func(http.ResponseWriter, int, string, error),
 	log func(int, string, time.Time),
 ) *TransmutationHandler {
 	return &TransmutationHandler{
 		Repo:             repo,
+		CertRepo:         certRepo,
+		SkillRepo:        skillRepo,
+		AvailabilityRepo: availabilityRepo,
 		Dispatcher:       dispatcher,
 		CurrentUser:      currentUser,
+		CurrentRole:      currentRole,
+		CurrentAlchemist: currentAlchemist,
 		ReportAsyncError: reportAsyncError,
 		HandleErr:        handleErr,
 		Log:              log,
@@ -47,47 +70,179 @@ func (h *TransmutationHandler) userEmail(r *http.Request) string {
 	return ""
 }
 
+func transmutationResponse(t *models.Transmutation) *api.TransmutationResponseDto {
+	resp := &api.TransmutationResponseDto{
+		ID:          int(t.ID),
+		AlchemistID: t.AlchemistID,
+		MaterialID:  t.MaterialID,
+		Status:      t.Status,
+		Result:      t.Result,
+		CreatedAt:   t.CreatedAt.Format(time.RFC3339),
+	}
+	if t.ScheduledAt != nil {
+		resp.ScheduledAt = t.ScheduledAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// checkLicense verifica que el alquimista tenga una licencia vigente. Quien
+// tiene licencias registradas pero todas vencidas o revocadas queda bloqueado.
+func (h *TransmutationHandler) checkLicense(alchemistID uint) error {
+	if h.CertRepo == nil {
+		return nil
+	}
+	licenses, err := h.CertRepo.FindLicenses(alchemistID)
+	if err != nil {
+		return err
+	}
+	if len(licenses) == 0 && !h.RequireActiveLicense {
+		return nil
+	}
+	now := time.Now()
+	for _, l := range licenses {
+		if l.Status(now) == models.CertificationActive {
+			return nil
+		}
+	}
+	return errLicenseInactive
+}
+
+// checkAvailability verifica que el alquimista esté disponible en el momento
+// en que se ejecutará la transmutación (ahora si no está programada).
+func (h *TransmutationHandler) checkAvailability(alchemistID uint, scheduledAt *time.Time) (string, error) {
+	if h.AvailabilityRepo == nil {
+		return "", nil
+	}
+	at := time.Now()
+	if scheduledAt != nil {
+		at = *scheduledAt
+	}
+	ws, err := h.AvailabilityRepo.FindOverlapping(alchemistID, at, at)
+	if err != nil || len(ws) == 0 {
+		return "", err
+	}
+	return "alchemist is unavailable at that time: " + describeWindows(ws), nil
+}
+
+// canCreateForAnyone decide por el permiso transmutation:create_any.
+func (h *TransmutationHandler) canCreateForAnyone(r *http.Request) bool {
+	return hasPermission(r, h.HasPermission, h.CurrentRole, models.PermTransmutationAny)
+}
+
+// transmutationStatusFor decide el estado inicial: las programadas para más
+// adelante esperan a que el planificador las libere.
+func transmutationStatusFor(scheduledAt *time.Time, now time.Time) string {
+	if scheduledAt != nil && scheduledAt.After(now) {
+		return "programada"
+	}
+	return "en_proceso"
+}
+
+var errLicenseInactive = errors.New("alchemist has no active license (expired or revoked)")
+
 func (h *TransmutationHandler) Create(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	var req api.TransmutationRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleErr) {
 		return
 	}
+
+	// Los alquimistas solo pueden registrar transmutaciones a su nombre; los
+	// supervisores e integraciones autorizadas pueden hacerlo para cualquiera.
+	if !h.canCreateForAnyone(r) {
+		var own uint
+		if h.CurrentAlchemist != nil {
+			own = h.CurrentAlchemist(r)
+		}
+		if own == 0 {
+			h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("user is not linked to an alchemist profile"))
+			return
+		}
+		if req.AlchemistID == 0 {
+			req.AlchemistID = own
+		}
+		if req.AlchemistID != own {
+			h.HandleErr(w, http.StatusForbidden, r.URL.Path, errors.New("alchemists can only create transmutations for themselves"))
+			return
+		}
+	}
 	if req.AlchemistID == 0 || req.MaterialID == 0 {
 		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("invalid IDs"))
 		return
 	}
+	if err := h.Scope.CheckAlchemist(r, req.AlchemistID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+
+	if err := h.checkLicense(req.AlchemistID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errLicenseInactive) {
+			status = http.StatusForbidden
+		}
+		h.HandleErr(w, status, r.URL.Path, err)
+		return
+	}
+
+	if h.SkillRepo != nil {
+		reqs, err := h.SkillRepo.FindRequirements(models.SkillRequirementMaterial, req.MaterialID)
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		unmet, err := h.SkillRepo.Unmet(req.AlchemistID, reqs)
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if len(unmet) > 0 {
+			h.HandleErr(w, http.StatusForbidden, r.URL.Path, fmt.Errorf("alchemist does not meet material skill requirements: %s", describeUnmet(unmet)))
+			return
+		}
+	}
+
+	scheduledAt, err := parseOptionalTime(req.ScheduledAt)
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return
+	}
+
+	conflict, err := h.checkAvailability(req.AlchemistID, scheduledAt)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if conflict != "" {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New(conflict))
+		return
+	}
 
 	t := &models.Transmutation{
 		AlchemistID: req.AlchemistID,
 		MaterialID:  req.MaterialID,
 		Formula:     req.Formula,
-		Status:      "en_proceso",
+		Status:      transmutationStatusFor(scheduledAt, time.Now()),
+		ScheduledAt: scheduledAt,
 	}
-	t, err := h.Repo.Save(t)
+	t, err = h.Repo.Save(t)
 	if err != nil {
 		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
 		return
 	}
 
 	if h.Dispatcher != nil {
-		if err := h.Dispatcher.EnqueueTransmutationProcessing(t.ID, h.userEmail(r)); err != nil {
+		details := "Transmutación encolada para procesamiento"
+		if t.Status == "programada" {
+			details = "Transmutación programada para " + t.ScheduledAt.Format(time.RFC3339)
+		} else if err := h.Dispatcher.EnqueueTransmutationProcessing(t.ID, h.userEmail(r)); err != nil {
 			h.ReportAsyncError(r.URL.Path, err)
 		}
-		if err := h.Dispatcher.EnqueueAudit("create", "transmutation", t.ID, h.userEmail(r), "Transmutación encolada para procesamiento"); err != nil {
+		if err := h.Dispatcher.EnqueueAudit("create", "transmutation", t.ID, h.userEmail(r), details); err != nil {
 			h.ReportAsyncError(r.URL.Path, err)
 		}
 	}
 
-	resp := &api.TransmutationResponseDto{
-		ID:          int(t.ID),
-		AlchemistID: t.AlchemistID,
-		MaterialID:  t.MaterialID,
-		Status:      t.Status,
-		Result:      t.Result,
-		CreatedAt:   t.CreatedAt.Format(time.RFC3339),
-	}
+	resp := transmutationResponse(t)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{"data": resp})
@@ -103,14 +258,7 @@ func (h *TransmutationHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	}
 	resp := []*api.TransmutationResponseDto{}
 	for _, t := range transmutations {
-		resp = append(resp, &api.TransmutationResponseDto{
-			ID:          int(t.ID),
-			AlchemistID: t.AlchemistID,
-			MaterialID:  t.MaterialID,
-			Status:      t.Status,
-			Result:      t.Result,
-			CreatedAt:   t.CreatedAt.Format(time.RFC3339),
-		})
+		resp = append(resp, transmutationResponse(t))
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"data": resp})
@@ -133,14 +281,7 @@ func (h *TransmutationHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("transmutation not found"))
 		return
 	}
-	resp := &api.TransmutationResponseDto{
-		ID:          int(t.ID),
-		AlchemistID: t.AlchemistID,
-		MaterialID:  t.MaterialID,
-		Status:      t.Status,
-		Result:      t.Result,
-		CreatedAt:   t.CreatedAt.Format(time.RFC3339),
-	}
+	resp := transmutationResponse(t)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"data": resp})
 	h.Log(http.StatusOK, r.URL.Path, start)
@@ -162,6 +303,10 @@ func (h *TransmutationHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("transmutation not found"))
 		return
 	}
+	if err := h.Scope.CheckAlchemist(r, t.AlchemistID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
 
 	if err := h.Repo.Delete(t); err != nil {
 		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
@@ -194,21 +339,52 @@ func (h *TransmutationHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.Scope.CheckAlchemist(r, t.AlchemistID); err != nil {
+		h.HandleErr(w, scopeStatus(err), r.URL.Path, err)
+		return
+	}
+
 	var req api.TransmutationEditRequestDto
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+	if !decodeRequest(w, r, &req, h.HandleErr) {
 		return
 	}
+	previousStatus := t.Status
 
 	if req.Formula != nil {
 		t.Formula = *req.Formula
 	}
 	if req.Status != nil {
+		if *req.Status == "completada" && t.Status != "completada" {
+			now := time.Now()
+			t.CompletedAt = &now
+		}
 		t.Status = *req.Status
 	}
 	if req.Result != nil {
 		t.Result = *req.Result
 	}
+	if req.ScheduledAt != nil {
+		if t.ScheduledAt, err = parseOptionalTime(*req.ScheduledAt); err != nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+			return
+		}
+		if t.ScheduledAt != nil {
+			conflict, err := h.checkAvailability(t.AlchemistID, t.ScheduledAt)
+			if err != nil {
+				h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			if conflict != "" {
+				h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New(conflict))
+				return
+			}
+		}
+	}
+	// Mientras no se haya procesado, el estado sigue a la programación.
+	if t.Status == "en_proceso" || t.Status == "programada" {
+		t.Status = transmutationStatusFor(t.ScheduledAt, time.Now())
+	}
+	release := previousStatus == "programada" && t.Status == "en_proceso"
 
 	t, err = h.Repo.Save(t)
 	if err != nil {
@@ -216,19 +392,17 @@ func (h *TransmutationHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if h.Dispatcher != nil {
+		if release {
+			if err := h.Dispatcher.EnqueueTransmutationProcessing(t.ID, h.userEmail(r)); err != nil {
+				h.ReportAsyncError(r.URL.Path, err)
+			}
+		}
 		if err := h.Dispatcher.EnqueueAudit("update", "transmutation", t.ID, h.userEmail(r), "Transmutación actualizada manualmente"); err != nil {
 			h.ReportAsyncError(r.URL.Path, err)
 		}
 	}
 
-	resp := &api.TransmutationResponseDto{
-		ID:          int(t.ID),
-		AlchemistID: t.AlchemistID,
-		MaterialID:  t.MaterialID,
-		Status:      t.Status,
-		Result:      t.Result,
-		CreatedAt:   t.CreatedAt.Format(time.RFC3339),
-	}
+	resp := transmutationResponse(t)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransmutationStatusFor(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	cases := []struct {
+		name        string
+		scheduledAt *time.Time
+		want        string
+	}{
+		{"sin programar", nil, "en_proceso"},
+		{"programada en el pasado", &past, "en_proceso"},
+		{"programada en el futuro", &future, "programada"},
+	}
+	for _, c := range cases {
+		if got := transmutationStatusFor(c.scheduledAt, now); got != c.want {
+			t.Errorf("%s: status %q, want %q", c.name, got, c.want)
+		}
+	}
+}
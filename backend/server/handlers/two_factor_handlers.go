@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/totp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	mfaPurpose        = "mfa"
+	mfaTokenTTL       = 5 * time.Minute
+	totpIssuer        = "Alchemist System"
+	totpSkew          = 1 // Pasos de desfase de reloj admitidos en cada sentido
+	recoveryCodeCount = 10
+)
+
+var (
+	errInvalidMFAToken      = errors.New("invalid or expired mfa token")
+	errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+)
+
+// signMFAToken emite el token que acredita que la contraseña ya se comprobó y
+// solo falta el segundo factor. No sirve como token de sesión.
+func (h *AuthHandler) signMFAToken(u *models.User) (string, error) {
+	now := time.Now()
+	claims := &AccountClaims{
+		Purpose: mfaPurpose,
+		Email:   u.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(u.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+			Issuer:    "alchemist-system",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(accountKey(mfaPurpose, h.JWTSecret))
+}
+
+func (h *AuthHandler) parseMFAToken(token string) (*models.User, error) {
+	claims := &AccountClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return accountKey(mfaPurpose, h.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || claims.Purpose != mfaPurpose {
+		return nil, errInvalidMFAToken
+	}
+	id, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, errInvalidMFAToken
+	}
+	u, err := h.UserRepository.FindByID(uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || u.Disabled() || !strings.EqualFold(u.Email, claims.Email) {
+		return nil, errInvalidMFAToken
+	}
+	return u, nil
+}
+
+// twoFactorRequired indica si la configuración obliga al usuario a usar el
+// doble factor.
+func (h *AuthHandler) twoFactorRequired(u *models.User) bool {
+	return h.RequireSupervisorTwoFactor && (u.Role == "supervisor" || u.Role == "admin")
+}
+
+// mfaChallenge responde al primer paso del login cuando falta el segundo
+// factor (o darlo de alta, si es obligatorio).
+func (h *AuthHandler) mfaChallenge(w http.ResponseWriter, r *http.Request, u *models.User) {
+	resp, err := h.mfaChallengeResponse(u)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *AuthHandler) mfaChallengeResponse(u *models.User) (*api.AuthResponse, error) {
+	token, err := h.signMFAToken(u)
+	if err != nil {
+		return nil, err
+	}
+	return &api.AuthResponse{
+		MFARequired:      u.TwoFactorEnabled(),
+		MFASetupRequired: !u.TwoFactorEnabled(),
+		MFAToken:         token,
+	}, nil
+}
+
+// normalizeRecoveryCode ignora mayúsculas, guiones y espacios.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// verifySecondFactor acepta un código TOTP no usado antes o un código de
+// recuperación pendiente.
+func (h *AuthHandler) verifySecondFactor(r *http.Request, u *models.User, code string) (bool, error) {
+	if step, ok := totp.Validate(u.TOTPSecret, code, time.Now(), totpSkew); ok {
+		advanced, err := h.UserRepository.AdvanceTOTPStep(u.ID, step)
+		if err != nil || !advanced {
+			return false, err
+		}
+		u.TOTPLastStep = step
+		return true, nil
+	}
+	if h.RecoveryCodes == nil {
+		return false, nil
+	}
+	used, err := h.RecoveryCodes.Consume(u.ID, hashToken(normalizeRecoveryCode(code)), time.Now())
+	if err != nil || !used {
+		return false, err
+	}
+	h.audit(r, "recovery_code", "user", u.ID, u.Email, "Login con código de recuperación del doble factor")
+	return true, nil
+}
+
+// newRecoveryCodes genera y guarda un juego nuevo de códigos de recuperación,
+// invalidando los anteriores. Se muestran una sola vez.
+func (h *AuthHandler) newRecoveryCodes(u *models.User) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(raw)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+	if err := h.RecoveryCodes.Replace(u.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// sessionUser devuelve el usuario de la sesión actual; las claves de API no
+// tienen doble factor propio.
+func (h *AuthHandler) sessionUser(w http.ResponseWriter, r *http.Request) *models.User {
+	var email string
+	if h.CurrentUser != nil {
+		email = h.CurrentUser(r)
+	}
+	u, err := h.UserRepository.FindByEmail(email)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if u == nil {
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, errors.New("two-factor settings require a user session"))
+		return nil
+	}
+	return u
+}
+
+func (h *AuthHandler) mfaUser(w http.ResponseWriter, r *http.Request, token string) *models.User {
+	u, err := h.parseMFAToken(token)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidMFAToken) {
+			status = http.StatusUnauthorized
+		}
+		h.HandleError(w, status, r.URL.Path, err)
+		return nil
+	}
+	return u
+}
+
+// POST /auth/login/2fa completa el login con el segundo factor.
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req api.TwoFactorLoginRequest
+	if !decodeRequest(w, r, &req, h.HandleError) {
+		return
+	}
+	u := h.mfaUser(w, r, req.MFAToken)
+	if u == nil {
+		return
+	}
+	if !u.TwoFactorEnabled() {
+		h.HandleError(w, http.StatusConflict, r.URL.Path, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	ip := ""
+	if h.ClientIP != nil {
+		ip = h.ClientIP(r)
+	}
+	if h.Throttle != nil {
+		wait, err := h.Throttle.Check(r.Context(), u.Email, ip)
+		if err != nil {
+			h.HandleError(w, http.StatusServiceUnavailable, r.URL.Path, err)
+			return
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			h.HandleError(w, http.StatusTooManyRequests, r.URL.Path, errors.New("too many failed login attempts; try again later"))
+			return
+		}
+	}
+	ok, err := h.verifySecondFactor(r, u, req.Code)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if !ok {
+		h.loginFailed(w, r, u, u.Email, ip, errInvalidTwoFactorCode)
+		return
+	}
+	if h.Throttle != nil {
+		if err := h.Throttle.Success(r.Context(), u.Email, ip); err != nil {
+			h.HandleError(w, http.StatusServiceUnavailable, r.URL.Path, err)
+			return
+		}
+	}
+
+	resp, err := h.issueTokens(u, "")
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// setupTwoFactor genera un secreto pendiente de confirmar. Repetirlo antes de
+// confirmar sustituye el anterior.
+func (h *AuthHandler) setupTwoFactor(w http.ResponseWriter, r *http.Request, u *models.User) {
+	if u.TwoFactorEnabled() {
+		h.HandleError(w, http.StatusConflict, r.URL.Path, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	u.TOTPSecret = secret
+	if _, err := h.UserRepository.Save(u); err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": &api.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(totpIssuer, u.Email, secret),
+	}})
+}
+
+// enableTwoFactor confirma el secreto pendiente con un código de la app y
+// devuelve los códigos de recuperación. Responde el error y devuelve nil si
+// no se pudo activar.
+func (h *AuthHandler) enableTwoFactor(w http.ResponseWriter, r *http.Request, u *models.User, code string) []string {
+	if u.TwoFactorEnabled() {
+		h.HandleError(w, http.StatusConflict, r.URL.Path, errors.New("two-factor authentication is already enabled"))
+		return nil
+	}
+	if u.TOTPSecret == "" {
+		h.HandleError(w, http.StatusConflict, r.URL.Path, errors.New("two-factor setup has not been started"))
+		return nil
+	}
+	step, ok := totp.Validate(u.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		h.HandleError(w, http.StatusBadRequest, r.URL.Path, errInvalidTwoFactorCode)
+		return nil
+	}
+	now := time.Now()
+	u.TOTPEnabledAt = &now
+	u.TOTPLastStep = step
+	if _, err := h.UserRepository.Save(u); err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	codes, err := h.newRecoveryCodes(u)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	h.audit(r, "enable", "2fa", u.ID, u.Email, "Activación del doble factor")
+	return codes
+}
+
+// GET /auth/2fa
+func (h *AuthHandler) TwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	u := h.sessionUser(w, r)
+	if u == nil {
+		return
+	}
+	resp := &api.TwoFactorStatusResponse{Enabled: u.TwoFactorEnabled(), Required: h.twoFactorRequired(u)}
+	if u.TwoFactorEnabled() {
+		left, err := h.RecoveryCodes.CountUnused(u.ID)
+		if err != nil {
+			h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		resp.RecoveryCodesLeft = left
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+}
+
+// POST /auth/2fa/setup
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if u := h.sessionUser(w, r); u != nil {
+		h.setupTwoFactor(w, r, u)
+	}
+}
+
+// POST /auth/2fa/enable
+func (h *AuthHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req api.TwoFactorCodeRequest
+	if !decodeRequest(w, r, &req, h.HandleError) {
+		return
+	}
+	u := h.sessionUser(w, r)
+	if u == nil {
+		return
+	}
+	codes := h.enableTwoFactor(w, r, u, req.Code)
+	if codes == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": &api.RecoveryCodesResponse{RecoveryCodes: codes}})
+}
+
+// POST /auth/login/2fa/setup: alta obligatoria durante el login, con el
+// mfa_token del primer paso.
+func (h *AuthHandler) LoginSetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req api.TwoFactorSetupRequest
+	if !decodeRequest(w, r, &req, h.HandleError) {
+		return
+	}
+	if u := h.mfaUser(w, r, req.MFAToken); u != nil {
+		h.setupTwoFactor(w, r, u)
+	}
+}
+
+// POST /auth/login/2fa/enable confirma el alta y completa el login.
+func (h *AuthHandler) LoginEnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req api.TwoFactorEnableRequest
+	if !decodeRequest(w, r, &req, h.HandleError) {
+		return
+	}
+	u := h.mfaUser(w, r, req.MFAToken)
+	if u == nil {
+		return
+	}
+	codes := h.enableTwoFactor(w, r, u, req.Code)
+	if codes == nil {
+		return
+	}
+	resp, err := h.issueTokens(u, "")
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp.RecoveryCodes = codes
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /auth/2fa/recovery-codes sustituye los códigos de recuperación.
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req api.TwoFactorCodeRequest
+	if !decodeRequest(w, r, &req, h.HandleError) {
+		return
+	}
+	u := h.sessionUser(w, r)
+	if u == nil {
+		return
+	}
+	if !u.TwoFactorEnabled() {
+		h.HandleError(w, http.StatusConflict, r.URL.Path, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+	ok, err := h.verifySecondFactor(r, u, req.Code)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if !ok {
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, errInvalidTwoFactorCode)
+		return
+	}
+	codes, err := h.newRecoveryCodes(u)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "update", "2fa", u.ID, u.Email, "Regeneración de códigos de recuperación")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": &api.RecoveryCodesResponse{RecoveryCodes: codes}})
+}
+
+// POST /auth/2fa/disable. Pide contraseña y código; no se permite si el rol
+// exige doble factor.
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req api.TwoFactorDisableRequest
+	if !decodeRequest(w, r, &req, h.HandleError) {
+		return
+	}
+	u := h.sessionUser(w, r)
+	if u == nil {
+		return
+	}
+	if !u.TwoFactorEnabled() {
+		h.HandleError(w, http.StatusConflict, r.URL.Path, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+	if h.twoFactorRequired(u) {
+		h.HandleError(w, http.StatusConflict, r.URL.Path, errors.New("two-factor authentication is mandatory for your role"))
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)) != nil {
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, errors.New("invalid password"))
+		return
+	}
+	ok, err := h.verifySecondFactor(r, u, req.Code)
+	if err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if !ok {
+		h.HandleError(w, http.StatusForbidden, r.URL.Path, errInvalidTwoFactorCode)
+		return
+	}
+
+	u.TOTPSecret = ""
+	u.TOTPEnabledAt = nil
+	if _, err := h.UserRepository.Save(u); err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if err := h.RecoveryCodes.DeleteAll(u.ID); err != nil {
+		h.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "disable", "2fa", u.ID, u.Email, "Desactivación del doble factor")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"backend-avanzada/totp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTwoFactorTest(t *testing.T) (*AuthHandler, *models.User) {
+	t.Helper()
+	db := newTestDB(t, &models.User{}, &models.RecoveryCode{})
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	u := &models.User{Email: "ed@example.com", PasswordHash: "x", Role: "alchemist", TOTPSecret: secret, TOTPEnabledAt: &now}
+	create(t, db, u)
+	return &AuthHandler{
+		UserRepository: repository.NewUserRepository(db),
+		RecoveryCodes:  repository.NewRecoveryCodeRepository(db),
+	}, u
+}
+
+func TestVerifySecondFactorRejectsReplayedTOTP(t *testing.T) {
+	h, u := newTwoFactorTest(t)
+	code, err := totp.Code(u.TOTPSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := h.verifySecondFactor(asUser(u.Email), u, code)
+	if err != nil || !ok {
+		t.Fatalf("first use = (%v, %v), want accepted", ok, err)
+	}
+	ok, err = h.verifySecondFactor(asUser(u.Email), u, code)
+	if err != nil || ok {
+		t.Fatalf("replay = (%v, %v), want rejected", ok, err)
+	}
+
+	// Un código anterior al último aceptado tampoco vale, aunque siga dentro
+	// del margen de reloj.
+	older, _ := totp.Code(u.TOTPSecret, totp.Step(time.Now())-1)
+	if ok, _ := h.verifySecondFactor(asUser(u.Email), u, older); ok {
+		t.Error("older code accepted after a newer one")
+	}
+}
+
+func TestVerifySecondFactorRecoveryCodeSingleUse(t *testing.T) {
+	h, u := newTwoFactorTest(t)
+	codes, err := h.newRecoveryCodes(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// Se admite con otras mayúsculas y sin guiones, pero solo una vez.
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	ok, err := h.verifySecondFactor(asUser(u.Email), u, typed)
+	if err != nil || !ok {
+		t.Fatalf("first use = (%v, %v), want accepted", ok, err)
+	}
+	ok, err = h.verifySecondFactor(asUser(u.Email), u, codes[0])
+	if err != nil || ok {
+		t.Fatalf("second use = (%v, %v), want rejected", ok, err)
+	}
+	if left, _ := h.RecoveryCodes.CountUnused(u.ID); left != recoveryCodeCount-1 {
+		t.Errorf("unused codes = %d, want %d", left, recoveryCodeCount-1)
+	}
+
+	// Regenerar invalida los anteriores.
+	if _, err := h.newRecoveryCodes(u); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := h.verifySecondFactor(asUser(u.Email), u, codes[1]); ok {
+		t.Error("code from a replaced set accepted")
+	}
+
+	// Los códigos son de cada usuario.
+	other := &models.User{}
+	other.ID = u.ID + 1
+	fresh, _ := h.newRecoveryCodes(u)
+	if ok, _ := h.verifySecondFactor(asUser(u.Email), other, fresh[0]); ok {
+		t.Error("another user's recovery code accepted")
+	}
+}
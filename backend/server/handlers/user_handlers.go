@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// UserHandler administra las cuentas de usuario: rol, desactivación y
+// restablecimiento forzado de la contraseña.
+type UserHandler struct {
+	Repo             repository.UserRepository
+	RoleRepo         *repository.RoleRepository
+	Dispatcher       AsyncDispatcher
+	CurrentUser      func(*http.Request) string
+	ReportAsyncError func(string, error)
+	HandleErr        func(http.ResponseWriter, int, string, error)
+	Log              func(int, string, time.Time)
+	// RevokeSessions cierra las sesiones del usuario tras cambiar su rol o
+	// desactivarlo, para que no sigan en uso tokens con el estado anterior.
+	RevokeSessions func(ctx context.Context, userID uint) error
+	// SendPasswordReset envía el enlace para fijar una contraseña nueva.
+	SendPasswordReset func(*http.Request, *models.User) error
+	// OnChange se llama tras activar o desactivar una cuenta (p. ej. para
+	// invalidar cachés).
+	OnChange func(email string)
+}
+
+func NewUserHandler(
+	repo repository.UserRepository,
+	roleRepo *repository.RoleRepository,
+	dispatcher AsyncDispatcher,
+	currentUser func(*http.Request) string,
+	reportAsyncError func(string, error),
+	handleErr func(http.ResponseWriter, int, string, error),
+	log func(int, string, time.Time),
+) *UserHandler {
+	return &UserHandler{
+		Repo:             repo,
+		RoleRepo:         roleRepo,
+		Dispatcher:       dispatcher,
+		CurrentUser:      currentUser,
+		ReportAsyncError: reportAsyncError,
+		HandleErr:        handleErr,
+		Log:              log,
+	}
+}
+
+func (h *UserHandler) userEmail(r *http.Request) string {
+	if h.CurrentUser != nil {
+		return h.CurrentUser(r)
+	}
+	return ""
+}
+
+func (h *UserHandler) audit(r *http.Request, action string, u *models.User, details string) {
+	if h.Dispatcher == nil {
+		return
+	}
+	if err := h.Dispatcher.EnqueueAudit(action, "user", u.ID, h.userEmail(r), details); err != nil {
+		h.ReportAsyncError(r.URL.Path, err)
+	}
+}
+
+func userResponse(u *models.User) *api.UserResponseDto {
+	resp := &api.UserResponseDto{
+		ID:                    int(u.ID),
+		Email:                 u.Email,
+		Role:                  u.Role,
+		AlchemistID:           u.AlchemistID,
+		EmailVerified:         u.EmailVerifiedAt != nil,
+		TwoFactorEnabled:      u.TwoFactorEnabled(),
+		Disabled:              u.Disabled(),
+		PasswordResetRequired: u.PasswordResetRequired,
+		OIDCLinked:            u.OIDCSubject != nil,
+		OIDCLinkPending:       u.OIDCPendingSubject != nil,
+		CreatedAt:             u.CreatedAt.Format(time.RFC3339),
+	}
+	if u.DisabledAt != nil {
+		resp.DisabledAt = u.DisabledAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func (h *UserHandler) user(w http.ResponseWriter, r *http.Request) *models.User {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, err)
+		return nil
+	}
+	u, err := h.Repo.FindByID(uint(id))
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return nil
+	}
+	if u == nil {
+		h.HandleErr(w, http.StatusNotFound, r.URL.Path, errors.New("user not found"))
+		return nil
+	}
+	return u
+}
+
+// notSelf impide que un supervisor se desactive o se cambie el rol a sí
+// mismo: así siempre queda alguien capaz de administrar las cuentas.
+func (h *UserHandler) notSelf(w http.ResponseWriter, r *http.Request, u *models.User, action string) bool {
+	if strings.EqualFold(u.Email, h.userEmail(r)) {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, fmt.Errorf("cannot %s your own account", action))
+		return false
+	}
+	return true
+}
+
+// save guarda el usuario y cierra sus sesiones abiertas.
+func (h *UserHandler) save(w http.ResponseWriter, r *http.Request, u *models.User) bool {
+	if _, err := h.Repo.Save(u); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return false
+	}
+	if h.RevokeSessions != nil {
+		if err := h.RevokeSessions(r.Context(), u.ID); err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return false
+		}
+	}
+	return true
+}
+
+func (h *UserHandler) changed(u *models.User) {
+	if h.OnChange != nil {
+		h.OnChange(u.Email)
+	}
+}
+
+func (h *UserHandler) respond(w http.ResponseWriter, r *http.Request, u *models.User, start time.Time) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": userResponse(u)})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /users[?role=supervisor&status=active|disabled&email=texto]
+func (h *UserHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q := r.URL.Query()
+	filter := repository.UserFilter{
+		Role:  q.Get("role"),
+		Email: strings.TrimSpace(q.Get("email")),
+	}
+	switch q.Get("status") {
+	case "":
+	case "active":
+		disabled := false
+		filter.Disabled = &disabled
+	case "disabled":
+		disabled := true
+		filter.Disabled = &disabled
+	default:
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, errors.New("status must be active or disabled"))
+		return
+	}
+
+	users, err := h.Repo.FindAll(filter)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	resp := make([]*api.UserResponseDto, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, userResponse(u))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": resp})
+	h.Log(http.StatusOK, r.URL.Path, start)
+}
+
+// GET /users/{id}
+func (h *UserHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	u := h.user(w, r)
+	if u == nil {
+		return
+	}
+	h.respond(w, r, u, start)
+}
+
+// PUT /users/{id}/role. Cierra las sesiones del usuario porque los tokens
+// emitidos llevan el rol anterior.
+func (h *UserHandler) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	u := h.user(w, r)
+	if u == nil {
+		return
+	}
+	var req api.UserRoleRequestDto
+	if !decodeRequest(w, r, &req, h.HandleErr) {
+		return
+	}
+	if h.RoleRepo != nil {
+		role, err := h.RoleRepo.FindByName(req.Role)
+		if err != nil {
+			h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+			return
+		}
+		if role == nil {
+			h.HandleErr(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("role %q does not exist", req.Role))
+			return
+		}
+	} else if _, ok := models.DefaultRoles[req.Role]; !ok {
+		h.HandleErr(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("role %q does not exist", req.Role))
+		return
+	}
+	if u.Role == req.Role {
+		h.respond(w, r, u, start)
+		return
+	}
+	if !h.notSelf(w, r, u, "change the role of") {
+		return
+	}
+
+	previous := u.Role
+	u.Role = req.Role
+	if !h.save(w, r, u) {
+		return
+	}
+	h.audit(r, "update", u, fmt.Sprintf("Cambio de rol de %s: %q → %q", u.Email, previous, u.Role))
+	h.respond(w, r, u, start)
+}
+
+// POST /users/{id}/disable. La cuenta no puede iniciar sesión y sus tokens
+// dejan de aceptarse.
+func (h *UserHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	u := h.user(w, r)
+	if u == nil {
+		return
+	}
+	if u.Disabled() {
+		h.respond(w, r, u, start)
+		return
+	}
+	if !h.notSelf(w, r, u, "disable") {
+		return
+	}
+
+	now := time.Now()
+	u.DisabledAt = &now
+	if !h.save(w, r, u) {
+		return
+	}
+	h.changed(u)
+	h.audit(r, "disable", u, fmt.Sprintf("Desactivación de la cuenta %s", u.Email))
+	h.respond(w, r, u, start)
+}
+
+// POST /users/{id}/enable
+func (h *UserHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	u := h.user(w, r)
+	if u == nil {
+		return
+	}
+	if !u.Disabled() {
+		h.respond(w, r, u, start)
+		return
+	}
+
+	u.DisabledAt = nil
+	if _, err := h.Repo.Save(u); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.changed(u)
+	h.audit(r, "enable", u, fmt.Sprintf("Reactivación de la cuenta %s", u.Email))
+	h.respond(w, r, u, start)
+}
+
+// POST /users/{id}/password-reset invalida la contraseña actual, cierra las
+// sesiones y envía al usuario un enlace para elegir otra.
+func (h *UserHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	u := h.user(w, r)
+	if u == nil {
+		return
+	}
+	if u.Disabled() {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("account is disabled"))
+		return
+	}
+
+	u.PasswordResetRequired = true
+	if !h.save(w, r, u) {
+		return
+	}
+	if err := h.SendPasswordReset(r, u); err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	h.audit(r, "password_reset", u, fmt.Sprintf("Restablecimiento de contraseña exigido a %s", u.Email))
+	w.WriteHeader(http.StatusAccepted)
+	h.Log(http.StatusAccepted, r.URL.Path, start)
+}
+
+// POST /users/{id}/oidc-link confirma la vinculación pendiente de la cuenta
+// con la identidad del proveedor que entró con su email.
+func (h *UserHandler) ConfirmOIDCLink(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	u := h.user(w, r)
+	if u == nil {
+		return
+	}
+	if u.OIDCPendingSubject == nil {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("no identity provider link is pending"))
+		return
+	}
+	other, err := h.Repo.FindByOIDCSubject(*u.OIDCPendingSubject)
+	if err != nil {
+		h.HandleErr(w, http.StatusInternalServerError, r.URL.Path, err)
+		return
+	}
+	if other != nil && other.ID != u.ID {
+		h.HandleErr(w, http.StatusConflict, r.URL.Path, errors.New("identity is already linked to another user"))
+		return
+	}
+
+	u.OIDCSubject = u.OIDCPendingSubject
+	u.OIDCPendingSubject = nil
+	if u.EmailVerifiedAt == nil {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+	if !h.save(w, r, u) {
+		return
+	}
+	h.audit(r, "update", u, fmt.Sprintf("Vinculación de %s con el proveedor de identidad confirmada", u.Email))
+	h.respond(w, r, u, start)
+}
+
+// DELETE /users/{id}/oidc-link rechaza la vinculación pendiente o deshace la
+// ya confirmada; la cuenta vuelve a entrar solo con contraseña.
+func (h *UserHandler) RemoveOIDCLink(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	u := h.user(w, r)
+	if u == nil {
+		return
+	}
+	if u.OIDCSubject == nil && u.OIDCPendingSubject == nil {
+		h.respond(w, r, u, start)
+		return
+	}
+
+	linked := u.OIDCSubject != nil
+	u.OIDCSubject = nil
+	u.OIDCPendingSubject = nil
+	if !h.save(w, r, u) {
+		return
+	}
+	if linked {
+		h.audit(r, "update", u, fmt.Sprintf("Desvinculación de %s del proveedor de identidad", u.Email))
+	} else {
+		h.audit(r, "update", u, fmt.Sprintf("Vinculación de %s con el proveedor de identidad rechazada", u.Email))
+	}
+	h.respond(w, r, u, start)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"backend-avanzada/server/handlers"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// redisLoginPrefix agrupa los contadores y bloqueos de intentos de login.
+const redisLoginPrefix = "alchemy:login:"
+
+// loginBackoffBase es la primera espera tras agotar los intentos libres; se
+// duplica con cada fallo posterior.
+const loginBackoffBase = time.Second
+
+// LoginPolicy fija los umbrales de la protección frente a fuerza bruta.
+type LoginPolicy struct {
+	FreeAttempts      int64         // Fallos por email antes de imponer esperas
+	LockoutAttempts   int64         // Fallos por email que bloquean la cuenta
+	IPLockoutAttempts int64         // Fallos desde una IP que la bloquean
+	Lockout           time.Duration // Duración del bloqueo y ventana de conteo
+}
+
+var DefaultLoginPolicy = LoginPolicy{
+	FreeAttempts:      3,
+	LockoutAttempts:   10,
+	IPLockoutAttempts: 50,
+	Lockout:           15 * time.Minute,
+}
+
+// RedisLoginThrottle cuenta los fallos por email y por IP en Redis. Cada
+// contador caduca tras Lockout sin fallos nuevos, a la vez que su bloqueo.
+type RedisLoginThrottle struct {
+	redis  *RedisClient
+	policy LoginPolicy
+}
+
+func NewRedisLoginThrottle(client *RedisClient, policy LoginPolicy) *RedisLoginThrottle {
+	return &RedisLoginThrottle{redis: client, policy: policy}
+}
+
+// emailKey usa un hash para que la clave no dependa de la longitud del email.
+func emailKey(kind, email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return redisLoginPrefix + kind + ":email:" + hex.EncodeToString(sum[:16])
+}
+
+func ipKey(kind, ip string) string {
+	return redisLoginPrefix + kind + ":ip:" + ip
+}
+
+func (t *RedisLoginThrottle) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	wait, err := t.redis.TTL(ctx, emailKey("lock", email))
+	if err != nil || ip == "" {
+		return wait, err
+	}
+	ipWait, err := t.redis.TTL(ctx, ipKey("lock", ip))
+	return max(wait, ipWait), err
+}
+
+func (t *RedisLoginThrottle) Failure(ctx context.Context, email, ip string) (*handlers.LoginFailure, error) {
+	p := t.policy
+	n, err := t.redis.Incr(ctx, emailKey("fail", email), p.Lockout)
+	if err != nil {
+		return nil, err
+	}
+	f := &handlers.LoginFailure{Attempts: n}
+	switch {
+	case n >= p.LockoutAttempts:
+		f.RetryAfter = p.Lockout
+		f.LockedOut = n == p.LockoutAttempts
+	case n > p.FreeAttempts:
+		f.RetryAfter = min(loginBackoffBase<<(n-p.FreeAttempts-1), p.Lockout)
+	}
+	if f.RetryAfter > 0 {
+		if err := t.redis.SetEX(ctx, emailKey("lock", email), "1", f.RetryAfter); err != nil {
+			return nil, err
+		}
+	}
+
+	if ip == "" {
+		return f, nil
+	}
+	if f.IPAttempts, err = t.redis.Incr(ctx, ipKey("fail", ip), p.Lockout); err != nil {
+		return nil, err
+	}
+	if f.IPAttempts >= p.IPLockoutAttempts {
+		f.IPLockedOut = f.IPAttempts == p.IPLockoutAttempts
+		f.RetryAfter = p.Lockout
+		if err := t.redis.SetEX(ctx, ipKey("lock", ip), "1", p.Lockout); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Success reinicia el contador del email. El de la IP se mantiene para que
+// una cuenta válida no sirva para seguir probando otras.
+func (t *RedisLoginThrottle) Success(ctx context.Context, email, ip string) error {
+	return t.redis.Del(ctx, emailKey("fail", email), emailKey("lock", email))
+}
+
+// clientIP devuelve la IP del cliente. Solo se fía de X-Forwarded-For si la
+// configuración indica que hay un proxy de confianza delante. Como el cliente
+// puede enviar la cabecera ya rellena, se recorre de derecha a izquierda y se
+// devuelve la primera dirección que no sea un proxy de confianza: el que
+// conecta y los de trusted_proxies.
+func (s *Server) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !s.Config.TrustProxyHeaders {
+		return host
+	}
+	trusted := parseTrustedProxies(s.Config.TrustedProxies)
+	if len(trusted) > 0 && !isTrustedProxy(trusted, host) {
+		return host
+	}
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// Una entrada mal formada no es de un proxy nuestro: lo anterior
+			// pudo escribirlo el cliente.
+			break
+		}
+		if !isTrustedProxy(trusted, hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+func parseTrustedProxies(xs []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, x := range xs {
+		if p, err := netip.ParsePrefix(x); err == nil {
+			prefixes = append(prefixes, p.Masked())
+		} else if a, err := netip.ParseAddr(x); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	return prefixes
+}
+
+func isTrustedProxy(trusted []netip.Prefix, ip string) bool {
+	a, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	a = a.Unmap()
+	for _, p := range trusted {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"backend-avanzada/config"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis implementa sobre TCP los comandos que usan RedisLoginThrottle
+// (INCR, PEXPIRE, SET EX, PTTL y DEL, con caducidad real) y la cola de
+// tareas (LPUSH).
+type fakeRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	lists   map[string][]string
+}
+
+func newFakeRedis(t *testing.T) (*RedisClient, *fakeRedis) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{values: map[string]string{}, expires: map[string]time.Time{}, lists: map[string][]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return NewRedisClient(ln.Addr().String()), f
+}
+
+// list devuelve una copia de la lista key, de la cabeza a la cola.
+func (f *fakeRedis) list(key string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.lists[key]...)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, f.exec(args))
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "$")))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2) // Con el \r\n final
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) live(key string) bool {
+	if exp, ok := f.expires[key]; ok && !time.Now().Before(exp) {
+		delete(f.values, key)
+		delete(f.expires, key)
+	}
+	_, ok := f.values[key]
+	return ok
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+	}
+	switch strings.ToUpper(args[0]) {
+	case "INCR":
+		n := int64(0)
+		if f.live(key) {
+			n, _ = strconv.ParseInt(f.values[key], 10, 64)
+		}
+		n++
+		f.values[key] = strconv.FormatInt(n, 10)
+		return fmt.Sprintf(":%d\r\n", n)
+	case "PEXPIRE":
+		if !f.live(key) {
+			return ":0\r\n"
+		}
+		ms, _ := strconv.ParseInt(args[2], 10, 64)
+		f.expires[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	case "SET":
+		f.values[key] = args[2]
+		delete(f.expires, key)
+		if len(args) == 5 && strings.EqualFold(args[3], "EX") {
+			s, _ := strconv.ParseInt(args[4], 10, 64)
+			f.expires[key] = time.Now().Add(time.Duration(s) * time.Second)
+		}
+		return "+OK\r\n"
+	case "PTTL":
+		if !f.live(key) {
+			return ":-2\r\n"
+		}
+		exp, ok := f.expires[key]
+		if !ok {
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", time.Until(exp).Milliseconds())
+	case "LPUSH":
+		for _, v := range args[2:] {
+			f.lists[key] = append([]string{v}, f.lists[key]...)
+		}
+		return fmt.Sprintf(":%d\r\n", len(f.lists[key]))
+	case "DEL":
+		n := 0
+		for _, k := range args[1:] {
+			if f.live(k) {
+				delete(f.values, k)
+				delete(f.expires, k)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return "-ERR unknown command\r\n"
+}
+
+var testLoginPolicy = LoginPolicy{
+	FreeAttempts:      3,
+	LockoutAttempts:   8,
+	IPLockoutAttempts: 12,
+	Lockout:           time.Minute,
+}
+
+func TestLoginThrottleBackoffAndLockout(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeRedis(t)
+	th := NewRedisLoginThrottle(client, testLoginPolicy)
+
+	// Tras los intentos libres la espera se duplica en cada fallo hasta el
+	// bloqueo, que solo se notifica una vez.
+	want := []struct {
+		retryAfter time.Duration
+		lockedOut  bool
+	}{
+		{0, false},
+		{0, false},
+		{0, false},
+		{1 * time.Second, false},
+		{2 * time.Second, false},
+		{4 * time.Second, false},
+		{8 * time.Second, false},
+		{time.Minute, true},
+		{time.Minute, false},
+	}
+	for i, w := range want {
+		f, err := th.Failure(ctx, "Ed@Example.com ", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Attempts != int64(i+1) || f.RetryAfter != w.retryAfter || f.LockedOut != w.lockedOut {
+			t.Fatalf("failure %d = %+v, want retry %s locked %v", i+1, f, w.retryAfter, w.lockedOut)
+		}
+	}
+
+	// El email se normaliza: el bloqueo alcanza a cualquier variante.
+	wait, err := th.Check(ctx, "ed@example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 50*time.Second || wait > time.Minute {
+		t.Errorf("Check after lockout = %s, want about %s", wait, time.Minute)
+	}
+	if wait, _ := th.Check(ctx, "al@example.com", ""); wait != 0 {
+		t.Errorf("other account is throttled for %s", wait)
+	}
+
+	// Un login correcto reinicia el contador y levanta la espera.
+	if err := th.Success(ctx, "ed@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := th.Check(ctx, "ed@example.com", ""); wait != 0 {
+		t.Errorf("Check after success = %s, want 0", wait)
+	}
+	f, err := th.Failure(ctx, "ed@example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Attempts != 1 || f.RetryAfter != 0 {
+		t.Errorf("failure after success = %+v, want a fresh counter", f)
+	}
+}
+
+func TestLoginThrottleBackoffCappedAtLockout(t *testing.T) {
+	ctx := context.Background()
+	policy := LoginPolicy{FreeAttempts: 0, LockoutAttempts: 100, IPLockoutAttempts: 1000, Lockout: 5 * time.Second}
+	client, _ := newFakeRedis(t)
+	th := NewRedisLoginThrottle(client, policy)
+	var last time.Duration
+	for i := 0; i < 10; i++ {
+		f, err := th.Failure(ctx, "ed@example.com", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = f.RetryAfter
+		if last > policy.Lockout {
+			t.Fatalf("failure %d waits %s, more than the lockout", i+1, last)
+		}
+	}
+	if last != policy.Lockout {
+		t.Errorf("backoff = %s, want capped at %s", last, policy.Lockout)
+	}
+}
+
+func TestLoginThrottleIPLockout(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeRedis(t)
+	th := NewRedisLoginThrottle(client, testLoginPolicy)
+	const ip = "203.0.113.7"
+
+	// Muchos emails distintos desde la misma IP, ninguno llega a su bloqueo.
+	for i := 1; i <= int(testLoginPolicy.IPLockoutAttempts)+1; i++ {
+		f, err := th.Failure(ctx, fmt.Sprintf("user%d@example.com", i), ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.LockedOut {
+			t.Fatalf("failure %d locked an account after one attempt", i)
+		}
+		wantLocked := int64(i) == testLoginPolicy.IPLockoutAttempts
+		if f.IPAttempts != int64(i) || f.IPLockedOut != wantLocked {
+			t.Fatalf("failure %d = %+v, want ip attempts %d locked %v", i, f, i, wantLocked)
+		}
+		if int64(i) >= testLoginPolicy.IPLockoutAttempts && f.RetryAfter != testLoginPolicy.Lockout {
+			t.Fatalf("failure %d retry after %s, want the lockout", i, f.RetryAfter)
+		}
+	}
+
+	if wait, _ := th.Check(ctx, "new@example.com", ip); wait == 0 {
+		t.Error("locked IP can still try other accounts")
+	}
+	if wait, _ := th.Check(ctx, "new@example.com", "198.51.100.1"); wait != 0 {
+		t.Errorf("other IP is throttled for %s", wait)
+	}
+	// Acertar una contraseña no desbloquea la IP.
+	if err := th.Success(ctx, "user1@example.com", ip); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := th.Check(ctx, "user1@example.com", ip); wait == 0 {
+		t.Error("success lifted the IP lockout")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		trust   bool
+		proxies []string
+		remote  string
+		xff     []string
+		want    string
+	}{
+		{"sin proxy de confianza", false, nil, "203.0.113.7:4000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"sin cabecera", true, nil, "10.0.0.2:4000", nil, "10.0.0.2"},
+		{"un salto", true, nil, "10.0.0.2:4000", []string{"198.51.100.1"}, "198.51.100.1"},
+		// El cliente falsifica la entrada de la izquierda; el proxy añade su IP real.
+		{"cabecera falsificada", true, nil, "10.0.0.2:4000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"varias cabeceras", true, nil, "10.0.0.2:4000", []string{"1.2.3.4", "198.51.100.1"}, "198.51.100.1"},
+		{"proxies encadenados", true, []string{"10.0.0.0/8"}, "10.0.0.2:4000", []string{"1.2.3.4, 198.51.100.1, 10.0.0.9"}, "198.51.100.1"},
+		{"conexión directa sin pasar por el proxy", true, []string{"10.0.0.0/8"}, "203.0.113.7:4000", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"proxy por IP", true, []string{"10.0.0.2"}, "10.0.0.2:4000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"entrada mal formada", true, []string{"10.0.0.0/8"}, "10.0.0.2:4000", []string{"1.2.3.4, basura, 10.0.0.9"}, "10.0.0.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{Config: &config.Config{TrustProxyHeaders: tt.trust, TrustedProxies: tt.proxies}}
+			r := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := s.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -12,9 +13,14 @@ import (
 type userContextKey struct{}
 
 type AuthClaims struct {
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	AlchemistID uint   `json:"alchemist_id,omitempty"`
 	jwt.RegisteredClaims
+
+	// Solo para peticiones autenticadas con X-API-Key (no viajan en el JWT).
+	APIKeyID    uint     `json:"-"`
+	Permissions []string `json:"-"`
 }
 
 // AuthMiddleware valida el JWT y (opcionalmente) exige uno de los roles dados.
@@ -27,20 +33,60 @@ func (s *Server) AuthMiddleware(roles ...string) func(http.Handler) http.Handler
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			auth := r.Header.Get("Authorization")
-			if !strings.HasPrefix(auth, "Bearer ") {
-				s.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("missing bearer token"))
-				return
-			}
-			tokenString := strings.TrimPrefix(auth, "Bearer ")
+			var claims *AuthClaims
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				// Integraciones: la clave sustituye al JWT y trae sus propios permisos
+				c, err := s.apiKeyClaims(key)
+				if err != nil {
+					s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+					return
+				}
+				if c == nil {
+					s.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("invalid api key"))
+					return
+				}
+				claims = c
+			} else {
+				auth := r.Header.Get("Authorization")
+				if !strings.HasPrefix(auth, "Bearer ") {
+					s.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("missing bearer token"))
+					return
+				}
+				tokenString := strings.TrimPrefix(auth, "Bearer ")
 
-			claims := &AuthClaims{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-				return []byte(s.jwtSecret), nil
-			})
-			if err != nil || !token.Valid {
-				s.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("invalid token"))
-				return
+				claims = &AuthClaims{}
+				token, err := jwt.ParseWithClaims(tokenString, claims, s.keys.Keyfunc,
+					jwt.WithValidMethods(s.keys.Methods()))
+				if err != nil || !token.Valid {
+					s.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("invalid token"))
+					return
+				}
+
+				// Tokens revocados por logout o por reutilización de un token de refresco
+				if s.revocations != nil && claims.ID != "" {
+					revoked, err := s.revocations.IsRevoked(r.Context(), claims.ID)
+					if err != nil {
+						s.HandleError(w, http.StatusServiceUnavailable, r.URL.Path, errors.New("token revocation list unavailable"))
+						return
+					}
+					if revoked {
+						s.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("token revoked"))
+						return
+					}
+				}
+
+				// Cuentas desactivadas después de emitir el token
+				if s.userStatus != nil {
+					disabled, err := s.userStatus.Disabled(claims.Email)
+					if err != nil {
+						s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+						return
+					}
+					if disabled {
+						s.HandleError(w, http.StatusUnauthorized, r.URL.Path, errors.New("account disabled"))
+						return
+					}
+				}
 			}
 
 			// Si se especificaron roles, revisamos que el del token esté permitido
@@ -66,3 +112,12 @@ func GetAuthClaims(r *http.Request) *AuthClaims {
 	}
 	return nil
 }
+
+// currentTokenExtractor devuelve el jti y la caducidad del token de la petición.
+func currentTokenExtractor(r *http.Request) (string, time.Time) {
+	claims := GetAuthClaims(r)
+	if claims == nil || claims.ExpiresAt == nil {
+		return "", time.Time{}
+	}
+	return claims.ID, claims.ExpiresAt.Time
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+type cachedPermissions struct {
+	perms   map[string]bool
+	expires time.Time
+}
+
+// permissionCache guarda los permisos de cada rol para no consultar la base de
+// datos en cada petición. Se invalida cuando se modifican los roles y cada
+// entrada caduca a los authCacheTTL.
+type permissionCache struct {
+	repo  *repository.RoleRepository
+	mu    sync.RWMutex
+	roles map[string]cachedPermissions
+}
+
+func newPermissionCache(repo *repository.RoleRepository) *permissionCache {
+	return &permissionCache{repo: repo}
+}
+
+// Has indica si el rol concede el permiso. Sin repositorio se usan los roles
+// por defecto.
+func (c *permissionCache) Has(role, perm string) (bool, error) {
+	c.mu.RLock()
+	entry, ok := c.roles[role]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.perms[perm], nil
+	}
+
+	perms := map[string]bool{}
+	if c.repo == nil {
+		for _, p := range models.DefaultRoles[role] {
+			perms[p] = true
+		}
+	} else {
+		r, err := c.repo.FindByName(role)
+		if err != nil {
+			return false, err
+		}
+		if r != nil {
+			for _, p := range r.Permissions {
+				perms[p.Permission] = true
+			}
+		}
+	}
+
+	c.mu.Lock()
+	if c.roles == nil {
+		c.roles = map[string]cachedPermissions{}
+	}
+	c.roles[role] = cachedPermissions{perms: perms, expires: time.Now().Add(authCacheTTL)}
+	c.mu.Unlock()
+	return perms[perm], nil
+}
+
+// Invalidate descarta la caché tras cambiar un rol.
+func (c *permissionCache) Invalidate() {
+	c.mu.Lock()
+	c.roles = nil
+	c.mu.Unlock()
+}
+
+// allowed comprueba el permiso contra la clave de API o el rol del usuario.
+func (s *Server) allowed(claims *AuthClaims, perm string) (bool, error) {
+	if claims == nil {
+		return false, nil
+	}
+	if claims.APIKeyID != 0 {
+		return slices.Contains(claims.Permissions, perm), nil
+	}
+	return s.permissions.Has(claims.Role, perm)
+}
+
+// permissionChecker expone allowed a los handlers que deciden según permisos.
+func (s *Server) permissionChecker(r *http.Request, perm string) bool {
+	ok, err := s.allowed(GetAuthClaims(r), perm)
+	return err == nil && ok
+}
+
+// RequirePermission valida el JWT y exige que el rol del usuario conceda perm.
+func (s *Server) RequirePermission(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return s.AuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, err := s.allowed(GetAuthClaims(r), perm)
+			if err != nil {
+				s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
+				return
+			}
+			if !ok {
+				s.HandleError(w, http.StatusForbidden, r.URL.Path, errors.New("forbidden: missing permission "+perm))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"testing"
+	"time"
+)
+
+func TestAllowed(t *testing.T) {
+	s := &Server{permissions: newPermissionCache(nil)}
+	key := &AuthClaims{
+		Email:       "apikey:ci",
+		APIKeyID:    7,
+		Permissions: []string{models.PermMissionWrite},
+	}
+
+	tests := []struct {
+		name   string
+		claims *AuthClaims
+		perm   string
+		want   bool
+	}{
+		{"sin sesión", nil, models.PermMissionWrite, false},
+		{"supervisor", &AuthClaims{Role: "supervisor"}, models.PermUserManage, true},
+		{"alquimista con permiso propio", &AuthClaims{Role: "alchemist"}, models.PermTaskUpdate, true},
+		{"alquimista sin permiso", &AuthClaims{Role: "alchemist"}, models.PermTaskWrite, false},
+		{"rol inexistente", &AuthClaims{Role: "ghost"}, models.PermTaskUpdate, false},
+		{"clave de API con permiso", key, models.PermMissionWrite, true},
+		{"clave de API sin permiso", key, models.PermAuditRead, false},
+		// Las claves no heredan permisos por el rol aunque las claims lo traigan.
+		{"clave de API con rol", &AuthClaims{APIKeyID: 7, Role: "supervisor"}, models.PermAuditRead, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.allowed(tt.claims, tt.perm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("allowed(%s) = %v, want %v", tt.perm, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermissionCacheExpires(t *testing.T) {
+	db := newTestDB(t, &models.Role{}, &models.RolePermission{})
+	repo := repository.NewRoleRepository(db)
+	role := &models.Role{Name: "auditor", Permissions: []models.RolePermission{{Permission: models.PermAuditRead}}}
+	if _, err := repo.Save(role); err != nil {
+		t.Fatal(err)
+	}
+	c := newPermissionCache(repo)
+	if ok, err := c.Has("auditor", models.PermAuditRead); err != nil || !ok {
+		t.Fatalf("Has before change = %v, %v", ok, err)
+	}
+
+	// Otra réplica quita el permiso sin invalidar esta caché.
+	if err := db.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		expire bool
+		want   bool
+	}{
+		{"entrada vigente", false, true},
+		{"entrada caducada", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.expire {
+				entry := c.roles["auditor"]
+				entry.expires = time.Now().Add(-time.Second)
+				c.roles["auditor"] = entry
+			}
+			got, err := c.Has("auditor", models.PermAuditRead)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Has = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
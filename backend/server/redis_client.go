@@ -18,7 +18,8 @@ const redisQueueKey = "alchemy:tasks"
 
 // RedisClient is a minimal RESP client tailored for pushing and popping
 // messages from Redis without relying on external dependencies. It only
-// implements the handful of commands that the async pipeline requires.
+// implements the handful of commands that the async pipeline, the token
+// revocation list and the login throttle require.
 type RedisClient struct {
 	addr        string
 	dialTimeout time.Duration
@@ -117,11 +118,129 @@ func (c *RedisClient) BRPOP(ctx context.Context, key string) ([]byte, error) {
 	return payload, nil
 }
 
+// SetEX stores value under key with the given expiry.
+func (c *RedisClient) SetEX(ctx context.Context, key, value string, ttl time.Duration) error {
+	conn, reader, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Round up so the key never expires before what it tracks.
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	if err := writeCommand(conn, "SET", key, value, "EX", strconv.FormatInt(seconds, 10)); err != nil {
+		return err
+	}
+	_, err = parseRESP(ctx, reader)
+	return err
+}
+
+// Exists reports whether key is present.
+func (c *RedisClient) Exists(ctx context.Context, key string) (bool, error) {
+	conn, reader, err := c.dial()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if err := writeCommand(conn, "EXISTS", key); err != nil {
+		return false, err
+	}
+	resp, err := parseRESP(ctx, reader)
+	if err != nil {
+		return false, err
+	}
+	n, ok := resp.(int64)
+	if !ok {
+		return false, fmt.Errorf("unexpected EXISTS response: %v", resp)
+	}
+	return n > 0, nil
+}
+
+// Incr increments the counter stored at key and (re)sets its expiry, so the
+// counter lives for ttl after the last increment. Both commands are pipelined
+// on the same connection.
+func (c *RedisClient) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	conn, reader, err := c.dial()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	ms := strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)
+	if err := writeCommands(conn, []string{"INCR", key}, []string{"PEXPIRE", key, ms}); err != nil {
+		return 0, err
+	}
+	resp, err := parseRESP(ctx, reader)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := resp.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected INCR response: %v", resp)
+	}
+	if _, err := parseRESP(ctx, reader); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// TTL returns the remaining time to live of key, or zero if the key does not
+// exist or has no expiry.
+func (c *RedisClient) TTL(ctx context.Context, key string) (time.Duration, error) {
+	conn, reader, err := c.dial()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if err := writeCommand(conn, "PTTL", key); err != nil {
+		return 0, err
+	}
+	resp, err := parseRESP(ctx, reader)
+	if err != nil {
+		return 0, err
+	}
+	ms, ok := resp.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected PTTL response: %v", resp)
+	}
+	if ms < 0 {
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Del removes the given keys; missing keys are ignored.
+func (c *RedisClient) Del(ctx context.Context, keys ...string) error {
+	conn, reader, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := writeCommand(conn, append([]string{"DEL"}, keys...)...); err != nil {
+		return err
+	}
+	_, err = parseRESP(ctx, reader)
+	return err
+}
+
 func writeCommand(conn net.Conn, args ...string) error {
+	return writeCommands(conn, args)
+}
+
+// writeCommands sends several commands in a single write (pipelining).
+func writeCommands(conn net.Conn, cmds ...[]string) error {
 	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "*%d\r\n", len(args))
-	for _, arg := range args {
-		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	for _, args := range cmds {
+		fmt.Fprintf(&buf, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
 	}
 	_, err := conn.Write(buf.Bytes())
 	return err
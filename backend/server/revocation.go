@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// redisRevokedPrefix agrupa los jti de los tokens de acceso revocados.
+const redisRevokedPrefix = "alchemy:revoked:"
+
+// RevocationList guarda los identificadores (jti) de tokens de acceso
+// revocados antes de caducar.
+type RevocationList interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RedisRevocationList mantiene la lista en Redis. Cada entrada caduca a la vez
+// que el token, así que la lista no crece indefinidamente.
+type RedisRevocationList struct {
+	redis *RedisClient
+}
+
+func NewRedisRevocationList(client *RedisClient) *RedisRevocationList {
+	return &RedisRevocationList{redis: client}
+}
+
+func (l *RedisRevocationList) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return l.redis.SetEX(ctx, redisRevokedPrefix+jti, "1", ttl)
+}
+
+func (l *RedisRevocationList) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	return l.redis.Exists(ctx, redisRevokedPrefix+jti)
+}
@@ -1,8 +1,12 @@
 package server
 
 import (
+	"backend-avanzada/keyring"
+	"backend-avanzada/models"
 	"backend-avanzada/server/handlers"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -14,83 +18,536 @@ func (s *Server) router() http.Handler {
 	dispatcher := s.taskQueue
 	asyncReporter := s.asyncErrorReporter()
 	currentUser := currentUserExtractor
+	currentRole := currentRoleExtractor
+	currentAlchemist := currentAlchemistExtractor
+	s.permissions = newPermissionCache(s.RoleRepository)
+	if s.UserRepository != nil {
+		s.userStatus = newUserStatusCache(s.UserRepository)
+	}
+	if s.keys == nil {
+		s.keys = keyring.NewHMAC([]byte(s.jwtSecret))
+	}
+
+	// Alcance por división (nil = sin restricciones)
+	var divisionScope *handlers.DivisionScope
+	if s.DivisionRepository != nil {
+		divisionScope = handlers.NewDivisionScope(
+			s.DivisionRepository,
+			s.UserRepository,
+			s.AlchemistRepository,
+			currentUser,
+			currentRole,
+		)
+		divisionScope.HasPermission = s.permissionChecker
+	}
 
 	// ========== AUTH ==========
 	authHandler := handlers.NewAuthHandler(
 		s.GetJWTSecret(),
 		s.UserRepository,
+		s.AlchemistRepository,
 		s.HandleError,
 		s.logger.Info,
 	)
+	authHandler.Signer = s.keys
+	authHandler.InvitationRepository = s.InvitationRepository
+	authHandler.RefreshTokens = s.RefreshTokenRepository
+	if s.revocations != nil {
+		authHandler.Revoker = s.revocations
+	}
+	authHandler.CurrentUser = currentUser
+	authHandler.CurrentToken = currentTokenExtractor
+	if s.loginThrottle != nil {
+		authHandler.Throttle = s.loginThrottle
+	}
+	authHandler.ClientIP = s.clientIP
+	authHandler.Dispatcher = dispatcher
+	authHandler.ReportAsyncError = asyncReporter
+	if s.Config.AccessTokenMinutes > 0 {
+		authHandler.AccessTTL = time.Duration(s.Config.AccessTokenMinutes) * time.Minute
+	}
+	if s.Config.RefreshTokenDays > 0 {
+		authHandler.RefreshTTL = time.Duration(s.Config.RefreshTokenDays) * 24 * time.Hour
+	}
+	jwksHandler := handlers.NewJWKSHandler(s.keys, s.logger.Info)
+	router.HandleFunc("/.well-known/jwks.json", jwksHandler.Get).Methods(http.MethodGet)
 	router.HandleFunc("/auth/register", authHandler.Register).Methods(http.MethodPost)
 	router.HandleFunc("/auth/login", authHandler.Login).Methods(http.MethodPost)
+	if s.RefreshTokenRepository != nil {
+		router.HandleFunc("/auth/refresh", authHandler.Refresh).Methods(http.MethodPost)
+	}
+	router.Handle("/auth/logout",
+		s.AuthMiddleware()(http.HandlerFunc(authHandler.Logout)),
+	).Methods(http.MethodPost)
+
+	// Doble factor (TOTP)
+	if s.RecoveryCodeRepository != nil {
+		authHandler.RecoveryCodes = s.RecoveryCodeRepository
+		authHandler.RequireSupervisorTwoFactor = s.Config.RequireSupervisor2FA
+		router.HandleFunc("/auth/login/2fa", authHandler.LoginTwoFactor).Methods(http.MethodPost)
+		router.HandleFunc("/auth/login/2fa/setup", authHandler.LoginSetupTwoFactor).Methods(http.MethodPost)
+		router.HandleFunc("/auth/login/2fa/enable", authHandler.LoginEnableTwoFactor).Methods(http.MethodPost)
+		router.Handle("/auth/2fa",
+			s.AuthMiddleware()(http.HandlerFunc(authHandler.TwoFactorStatus)),
+		).Methods(http.MethodGet)
+		router.Handle("/auth/2fa/setup",
+			s.AuthMiddleware()(http.HandlerFunc(authHandler.SetupTwoFactor)),
+		).Methods(http.MethodPost)
+		router.Handle("/auth/2fa/enable",
+			s.AuthMiddleware()(http.HandlerFunc(authHandler.EnableTwoFactor)),
+		).Methods(http.MethodPost)
+		router.Handle("/auth/2fa/recovery-codes",
+			s.AuthMiddleware()(http.HandlerFunc(authHandler.RegenerateRecoveryCodes)),
+		).Methods(http.MethodPost)
+		router.Handle("/auth/2fa/disable",
+			s.AuthMiddleware()(http.HandlerFunc(authHandler.DisableTwoFactor)),
+		).Methods(http.MethodPost)
+	}
+
+	// Login con el proveedor de identidad (OpenID Connect + PKCE)
+	if s.OIDC != nil {
+		authHandler.OIDC = s.OIDC
+		authHandler.OIDCRoleClaim = s.Config.OIDCRoleClaim
+		if authHandler.OIDCRoleClaim == "" {
+			authHandler.OIDCRoleClaim = "groups"
+		}
+		for _, m := range s.Config.OIDCRoleMappings {
+			authHandler.OIDCRoleMappings = append(authHandler.OIDCRoleMappings, handlers.OIDCRoleMapping{Value: m.Value, Role: m.Role})
+		}
+		authHandler.OIDCDefaultRole = s.Config.OIDCDefaultRole
+		authHandler.RoleRepository = s.RoleRepository
+		authHandler.OIDCPostLoginURL = s.Config.OIDCPostLoginURL
+		router.HandleFunc("/auth/oidc/login", authHandler.OIDCLogin).Methods(http.MethodGet)
+		router.HandleFunc("/auth/oidc/callback", authHandler.OIDCCallback).Methods(http.MethodGet)
+	}
+
+	// Restablecer contraseña y verificar email (enlaces por correo)
+	authHandler.RequireVerifiedEmail = s.Config.RequireVerifiedEmail
+	var sendPasswordReset func(*http.Request, *models.User) error
+	if s.AccountTokenRepository != nil && s.Mailer != nil {
+		accountHandler := handlers.NewAccountHandler(
+			s.UserRepository,
+			s.AccountTokenRepository,
+			s.Mailer,
+			s.GetJWTSecret(),
+			asyncReporter,
+			s.HandleError,
+			s.logger.Info,
+		)
+		accountHandler.AppURL = s.Config.AppURL
+		if s.Config.PasswordResetMinutes > 0 {
+			accountHandler.ResetTTL = time.Duration(s.Config.PasswordResetMinutes) * time.Minute
+		}
+		if s.Config.EmailVerificationHours > 0 {
+			accountHandler.VerificationTTL = time.Duration(s.Config.EmailVerificationHours) * time.Hour
+		}
+		accountHandler.RevokeSessions = authHandler.RevokeUserSessions
+		authHandler.OnRegister = accountHandler.SendVerification
+		sendPasswordReset = accountHandler.SendForcedPasswordReset
+		router.HandleFunc("/auth/password/forgot", accountHandler.ForgotPassword).Methods(http.MethodPost)
+		router.HandleFunc("/auth/password/reset", accountHandler.ResetPassword).Methods(http.MethodPost)
+		router.HandleFunc("/auth/email/verification", accountHandler.RequestVerification).Methods(http.MethodPost)
+		router.HandleFunc("/auth/email/verify", accountHandler.VerifyEmail).Methods(http.MethodPost)
+	}
+
+	if s.InvitationRepository != nil {
+		invHandler := handlers.NewInvitationHandler(
+			s.InvitationRepository,
+			s.RoleRepository,
+			s.GetJWTSecret(),
+			dispatcher,
+			currentUser,
+			asyncReporter,
+			s.HandleError,
+			s.logger.Info,
+		)
+		router.Handle("/auth/invitations",
+			s.RequirePermission(models.PermInvitationManage)(http.HandlerFunc(invHandler.GetAll)),
+		).Methods(http.MethodGet)
+		router.Handle("/auth/invitations",
+			s.RequirePermission(models.PermInvitationManage)(http.HandlerFunc(invHandler.Create)),
+		).Methods(http.MethodPost)
+		router.Handle("/auth/invitations/{id}",
+			s.RequirePermission(models.PermInvitationManage)(http.HandlerFunc(invHandler.Delete)),
+		).Methods(http.MethodDelete)
+	}
+
+	// ========== ROLES ==========
+	if s.RoleRepository != nil {
+		roleHandler := handlers.NewRoleHandler(
+			s.RoleRepository,
+			dispatcher,
+			currentUser,
+			currentRole,
+			asyncReporter,
+			s.HandleError,
+			s.logger.Info,
+		)
+		roleHandler.OnChange = s.permissions.Invalidate
+		router.Handle("/permissions",
+			s.RequirePermission(models.PermRoleManage)(http.HandlerFunc(roleHandler.Permissions)),
+		).Methods(http.MethodGet)
+		router.Handle("/roles",
+			s.RequirePermission(models.PermRoleManage)(http.HandlerFunc(roleHandler.GetAll)),
+		).Methods(http.MethodGet)
+		router.Handle("/roles",
+			s.RequirePermission(models.PermRoleManage)(http.HandlerFunc(roleHandler.Create)),
+		).Methods(http.MethodPost)
+		router.Handle("/roles/{id}",
+			s.RequirePermission(models.PermRoleManage)(http.HandlerFunc(roleHandler.GetByID)),
+		).Methods(http.MethodGet)
+		router.Handle("/roles/{id}",
+			s.RequirePermission(models.PermRoleManage)(http.HandlerFunc(roleHandler.Edit)),
+		).Methods(http.MethodPut)
+		router.Handle("/roles/{id}",
+			s.RequirePermission(models.PermRoleManage)(http.HandlerFunc(roleHandler.Delete)),
+		).Methods(http.MethodDelete)
+	}
+
+	// ========== API KEYS ==========
+	if s.APIKeyRepository != nil {
+		keyHandler := handlers.NewAPIKeyHandler(
+			s.APIKeyRepository,
+			dispatcher,
+			currentUser,
+			asyncReporter,
+			s.HandleError,
+			s.logger.Info,
+		)
+		keyHandler.HasPermission = s.permissionChecker
+		router.Handle("/api-keys",
+			s.RequirePermission(models.PermAPIKeyManage)(http.HandlerFunc(keyHandler.GetAll)),
+		).Methods(http.MethodGet)
+		router.Handle("/api-keys",
+			s.RequirePermission(models.PermAPIKeyManage)(http.HandlerFunc(keyHandler.Create)),
+		).Methods(http.MethodPost)
+		router.Handle("/api-keys/{id}",
+			s.RequirePermission(models.PermAPIKeyManage)(http.HandlerFunc(keyHandler.Revoke)),
+		).Methods(http.MethodDelete)
+	}
+
+	// ========== USERS ==========
+	if s.UserRepository != nil {
+		userHandler := handlers.NewUserHandler(
+			s.UserRepository,
+			s.RoleRepository,
+			dispatcher,
+			currentUser,
+			asyncReporter,
+			s.HandleError,
+			s.logger.Info,
+		)
+		userHandler.RevokeSessions = authHandler.RevokeUserSessions
+		userHandler.SendPasswordReset = sendPasswordReset
+		userHandler.OnChange = s.userStatus.Invalidate
+		router.Handle("/users",
+			s.RequirePermission(models.PermUserManage)(http.HandlerFunc(userHandler.GetAll)),
+		).Methods(http.MethodGet)
+		router.Handle("/users/{id}",
+			s.RequirePermission(models.PermUserManage)(http.HandlerFunc(userHandler.GetByID)),
+		).Methods(http.MethodGet)
+		router.Handle("/users/{id}/role",
+			s.RequirePermission(models.PermUserManage)(http.HandlerFunc(userHandler.ChangeRole)),
+		).Methods(http.MethodPut)
+		router.Handle("/users/{id}/disable",
+			s.RequirePermission(models.PermUserManage)(http.HandlerFunc(userHandler.Disable)),
+		).Methods(http.MethodPost)
+		router.Handle("/users/{id}/enable",
+			s.RequirePermission(models.PermUserManage)(http.HandlerFunc(userHandler.Enable)),
+		).Methods(http.MethodPost)
+		router.Handle("/users/{id}/oidc-link",
+			s.RequirePermission(models.PermUserManage)(http.HandlerFunc(userHandler.ConfirmOIDCLink)),
+		).Methods(http.MethodPost)
+		router.Handle("/users/{id}/oidc-link",
+			s.RequirePermission(models.PermUserManage)(http.HandlerFunc(userHandler.RemoveOIDCLink)),
+		).Methods(http.MethodDelete)
+		// Requiere correo configurado para que el usuario reciba el enlace
+		if sendPasswordReset != nil {
+			router.Handle("/users/{id}/password-reset",
+				s.RequirePermission(models.PermUserManage)(http.HandlerFunc(userHandler.ForcePasswordReset)),
+			).Methods(http.MethodPost)
+		}
+	}
 
 	// ========== ALCHEMISTS ==========
 	// Se registran solo si el repo está disponible (tu mismo patrón)
 	if s.AlchemistRepository != nil {
 		alchHandler := handlers.NewAlchemistHandler(
 			s.AlchemistRepository,
+			s.UserRepository,
 			dispatcher,
 			currentUser,
 			asyncReporter,
 			s.HandleError,
 			s.logger.Info,
 		)
+		alchHandler.Scope = divisionScope
+		alchHandler.RevokeSessions = authHandler.RevokeUserSessions
 		// Lectura pública
 		router.HandleFunc("/alchemists", alchHandler.GetAll).Methods(http.MethodGet)
+
+		// Disponibilidad: /alchemists/available debe registrarse antes que
+		// /alchemists/{id} para que no lo capture la ruta parametrizada.
+		if s.AvailabilityRepository != nil {
+			availHandler := handlers.NewAvailabilityHandler(
+				s.AvailabilityRepository,
+				s.AlchemistRepository,
+				dispatcher,
+				currentUser,
+				currentRole,
+				currentAlchemist,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			availHandler.HasPermission = s.permissionChecker
+			router.HandleFunc("/alchemists/available", availHandler.Available).Methods(http.MethodGet)
+			router.HandleFunc("/alchemists/{id}/availability", availHandler.GetAll).Methods(http.MethodGet)
+			router.Handle("/alchemists/{id}/availability",
+				s.RequirePermission(models.PermAvailabilityWrite)(http.HandlerFunc(availHandler.Create)),
+			).Methods(http.MethodPost)
+			router.Handle("/alchemists/{id}/availability/{windowId}",
+				s.RequirePermission(models.PermAvailabilityWrite)(http.HandlerFunc(availHandler.Delete)),
+			).Methods(http.MethodDelete)
+		}
+
 		router.HandleFunc("/alchemists/{id}", alchHandler.GetByID).Methods(http.MethodGet)
 
 		// Mutaciones protegidas
 		router.Handle(
 			"/alchemists",
-			s.AuthMiddleware("supervisor")(http.HandlerFunc(alchHandler.Create)),
+			s.RequirePermission(models.PermAlchemistWrite)(http.HandlerFunc(alchHandler.Create)),
 		).Methods(http.MethodPost)
 		router.Handle(
 			"/alchemists/{id}",
-			s.AuthMiddleware("supervisor")(http.HandlerFunc(alchHandler.Edit)),
+			s.RequirePermission(models.PermAlchemistWrite)(http.HandlerFunc(alchHandler.Edit)),
 		).Methods(http.MethodPut)
 		router.Handle(
 			"/alchemists/{id}",
-			s.AuthMiddleware("supervisor")(http.HandlerFunc(alchHandler.Delete)),
+			s.RequirePermission(models.PermAlchemistWrite)(http.HandlerFunc(alchHandler.Delete)),
+		).Methods(http.MethodDelete)
+		router.Handle(
+			"/alchemists/{id}/user",
+			s.RequirePermission(models.PermAlchemistWrite)(http.HandlerFunc(alchHandler.LinkUser)),
+		).Methods(http.MethodPut)
+		router.Handle(
+			"/alchemists/{id}/user",
+			s.RequirePermission(models.PermAlchemistWrite)(http.HandlerFunc(alchHandler.UnlinkUser)),
 		).Methods(http.MethodDelete)
 
+		// Calendario iCalendar (protegido con token propio en la URL)
+		if s.CalendarFeedRepository != nil {
+			calHandler := handlers.NewCalendarHandler(
+				s.CalendarFeedRepository,
+				s.AlchemistRepository,
+				s.MissionRepository,
+				s.TransmutationRepository,
+				dispatcher,
+				currentUser,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			calHandler.CurrentRole = currentRole
+			calHandler.CurrentAlchemist = currentAlchemist
+			calHandler.HasPermission = s.permissionChecker
+			router.HandleFunc("/alchemists/{id}/calendar.ics", calHandler.Feed).Methods(http.MethodGet)
+			router.Handle("/alchemists/{id}/calendar-token",
+				s.AuthMiddleware()(http.HandlerFunc(calHandler.RotateToken)),
+			).Methods(http.MethodPost)
+		}
+
+		// Estadísticas de rendimiento
+		if s.StatsRepository != nil {
+			statsHandler := handlers.NewStatsHandler(
+				s.StatsRepository,
+				s.AlchemistRepository,
+				s.HandleError,
+				s.logger.Info,
+			)
+			router.Handle("/alchemists/{id}/stats",
+				s.RequirePermission(models.PermStatsRead)(http.HandlerFunc(statsHandler.Alchemist)),
+			).Methods(http.MethodGet)
+		}
+
+		// Licencias y certificaciones del alquimista
+		if s.CertificationRepository != nil {
+			certHandler := handlers.NewCertificationHandler(
+				s.CertificationRepository,
+				s.AlchemistRepository,
+				dispatcher,
+				currentUser,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			router.Handle("/alchemists/{id}/certifications",
+				s.RequirePermission(models.PermCertificationRead)(http.HandlerFunc(certHandler.GetAll)),
+			).Methods(http.MethodGet)
+			router.Handle("/alchemists/{id}/certifications",
+				s.RequirePermission(models.PermCertificationWrite)(http.HandlerFunc(certHandler.Create)),
+			).Methods(http.MethodPost)
+			router.Handle("/alchemists/{id}/certifications/{certId}",
+				s.RequirePermission(models.PermCertificationWrite)(http.HandlerFunc(certHandler.Edit)),
+			).Methods(http.MethodPut)
+			router.Handle("/alchemists/{id}/certifications/{certId}",
+				s.RequirePermission(models.PermCertificationWrite)(http.HandlerFunc(certHandler.Delete)),
+			).Methods(http.MethodDelete)
+			router.Handle("/alchemists/{id}/certifications/{certId}/revoke",
+				s.RequirePermission(models.PermCertificationWrite)(http.HandlerFunc(certHandler.Revoke)),
+			).Methods(http.MethodPost)
+		}
+
 		// ======== MISSIONS ========
 		if s.MissionRepository != nil {
 			mh := handlers.NewMissionHandler(
 				s.MissionRepository,
+				s.MissionTaskRepository,
+				s.SkillRepository,
+				s.AvailabilityRepository,
 				dispatcher,
 				currentUser,
 				asyncReporter,
 				s.HandleError,
 				s.logger.Info,
 			)
+			mh.Scope = divisionScope
+			mh.Store = s.BlobStore
 			router.HandleFunc("/missions", mh.GetAll).Methods(http.MethodGet)
 			router.HandleFunc("/missions/{id}", mh.GetByID).Methods(http.MethodGet)
 			router.Handle("/missions",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(mh.Create)),
+				s.RequirePermission(models.PermMissionWrite)(http.HandlerFunc(mh.Create)),
 			).Methods(http.MethodPost)
 			router.Handle("/missions/{id}",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(mh.Edit)), // <- NUEVO PUT
+				s.RequirePermission(models.PermMissionWrite)(http.HandlerFunc(mh.Edit)), // <- NUEVO PUT
 			).Methods(http.MethodPut)
 			router.Handle("/missions/{id}",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(mh.Delete)),
+				s.RequirePermission(models.PermMissionWrite)(http.HandlerFunc(mh.Delete)),
+			).Methods(http.MethodDelete)
+
+			// Subtareas de la misión
+			th := handlers.NewMissionTaskHandler(
+				s.MissionTaskRepository,
+				s.MissionRepository,
+				dispatcher,
+				currentUser,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			th.HasPermission = s.permissionChecker
+			th.CurrentAlchemist = currentAlchemist
+			router.HandleFunc("/missions/{id}/tasks", th.GetAll).Methods(http.MethodGet)
+			router.HandleFunc("/missions/{id}/tasks/{taskId}", th.GetByID).Methods(http.MethodGet)
+			router.Handle("/missions/{id}/tasks",
+				s.RequirePermission(models.PermTaskWrite)(http.HandlerFunc(th.Create)),
+			).Methods(http.MethodPost)
+			router.Handle("/missions/{id}/tasks/{taskId}",
+				s.RequirePermission(models.PermTaskUpdate)(http.HandlerFunc(th.Edit)),
+			).Methods(http.MethodPut)
+			router.Handle("/missions/{id}/tasks/{taskId}",
+				s.RequirePermission(models.PermTaskWrite)(http.HandlerFunc(th.Delete)),
+			).Methods(http.MethodDelete)
+
+			// Comentarios e historial de actividad
+			ch := handlers.NewMissionCommentHandler(
+				s.MissionCommentRepository,
+				s.MissionRepository,
+				s.MissionTaskRepository,
+				s.AuditRepository,
+				s.UserRepository,
+				dispatcher,
+				currentUser,
+				currentRole,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			ch.HasPermission = s.permissionChecker
+			router.Handle("/missions/{id}/comments",
+				s.AuthMiddleware()(http.HandlerFunc(ch.GetAll)),
+			).Methods(http.MethodGet)
+			router.Handle("/missions/{id}/timeline",
+				s.AuthMiddleware()(http.HandlerFunc(ch.Timeline)),
+			).Methods(http.MethodGet)
+			router.Handle("/missions/{id}/comments",
+				s.RequirePermission(models.PermCommentWrite)(http.HandlerFunc(ch.Create)),
+			).Methods(http.MethodPost)
+			router.Handle("/missions/{id}/comments/{commentId}",
+				s.RequirePermission(models.PermCommentWrite)(http.HandlerFunc(ch.Edit)),
+			).Methods(http.MethodPut)
+			router.Handle("/missions/{id}/comments/{commentId}",
+				s.RequirePermission(models.PermCommentWrite)(http.HandlerFunc(ch.Delete)),
+			).Methods(http.MethodDelete)
+
+			// Dependencias entre misiones
+			dh := handlers.NewMissionDependencyHandler(
+				s.MissionDependencyRepository,
+				s.MissionRepository,
+				dispatcher,
+				currentUser,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			router.HandleFunc("/missions/{id}/dependencies", dh.GetAll).Methods(http.MethodGet)
+			router.HandleFunc("/missions/{id}/graph", dh.Graph).Methods(http.MethodGet)
+			router.Handle("/missions/{id}/dependencies",
+				s.RequirePermission(models.PermMissionWrite)(http.HandlerFunc(dh.Create)),
+			).Methods(http.MethodPost)
+			router.Handle("/missions/{id}/dependencies/{dependsOnId}",
+				s.RequirePermission(models.PermMissionWrite)(http.HandlerFunc(dh.Delete)),
 			).Methods(http.MethodDelete)
 		}
 
+		// ======== MISSION TEMPLATES ========
+		if s.MissionTemplateRepository != nil {
+			tplHandler := handlers.NewMissionTemplateHandler(
+				s.MissionTemplateRepository,
+				dispatcher,
+				currentUser,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			router.HandleFunc("/mission-templates", tplHandler.GetAll).Methods(http.MethodGet)
+			router.HandleFunc("/mission-templates/{id}", tplHandler.GetByID).Methods(http.MethodGet)
+			router.Handle("/mission-templates",
+				s.RequirePermission(models.PermTemplateWrite)(http.HandlerFunc(tplHandler.Create)),
+			).Methods(http.MethodPost)
+			router.Handle("/mission-templates/{id}",
+				s.RequirePermission(models.PermTemplateWrite)(http.HandlerFunc(tplHandler.Edit)),
+			).Methods(http.MethodPut)
+			router.Handle("/mission-templates/{id}",
+				s.RequirePermission(models.PermTemplateWrite)(http.HandlerFunc(tplHandler.Delete)),
+			).Methods(http.MethodDelete)
+			router.Handle("/mission-templates/{id}/instantiate",
+				s.RequirePermission(models.PermTemplateWrite)(http.HandlerFunc(tplHandler.Instantiate)),
+			).Methods(http.MethodPost)
+		}
+
 		// ======== TRANSMUTATIONS ========
 		if s.TransmutationRepository != nil {
 			transHandler := handlers.NewTransmutationHandler(
 				s.TransmutationRepository,
+				s.CertificationRepository,
+				s.SkillRepository,
+				s.AvailabilityRepository,
 				dispatcher,
 				currentUser,
+				currentRole,
+				currentAlchemist,
 				asyncReporter,
 				s.HandleError,
 				s.logger.Info,
 			)
+			transHandler.RequireActiveLicense = s.Config.RequireActiveLicense
+			transHandler.Scope = divisionScope
+			transHandler.HasPermission = s.permissionChecker
 
 			router.Handle(
 				"/transmutations",
-				s.AuthMiddleware("alchemist", "supervisor")(http.HandlerFunc(transHandler.Create)),
+				s.RequirePermission(models.PermTransmutationCreate)(http.HandlerFunc(transHandler.Create)),
 			).Methods(http.MethodPost)
 
 			router.HandleFunc("/transmutations", transHandler.GetAll).Methods(http.MethodGet)
@@ -98,12 +555,12 @@ func (s *Server) router() http.Handler {
 
 			router.Handle(
 				"/transmutations/{id}",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(transHandler.Edit)), // ✅ nuevo PUT
+				s.RequirePermission(models.PermTransmutationWrite)(http.HandlerFunc(transHandler.Edit)), // ✅ nuevo PUT
 			).Methods(http.MethodPut)
 
 			router.Handle(
 				"/transmutations/{id}",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(transHandler.Delete)),
+				s.RequirePermission(models.PermTransmutationWrite)(http.HandlerFunc(transHandler.Delete)),
 			).Methods(http.MethodDelete)
 		}
 
@@ -120,16 +577,234 @@ func (s *Server) router() http.Handler {
 			router.HandleFunc("/materials", matHandler.GetAll).Methods(http.MethodGet)
 			router.HandleFunc("/materials/{id}", matHandler.GetByID).Methods(http.MethodGet)
 			router.Handle("/materials",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(matHandler.Create)),
+				s.RequirePermission(models.PermMaterialWrite)(http.HandlerFunc(matHandler.Create)),
 			).Methods(http.MethodPost)
 			router.Handle("/materials/{id}",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(matHandler.Edit)), // ✅ nuevo PUT
+				s.RequirePermission(models.PermMaterialWrite)(http.HandlerFunc(matHandler.Edit)), // ✅ nuevo PUT
 			).Methods(http.MethodPut)
 			router.Handle("/materials/{id}",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(matHandler.Delete)),
+				s.RequirePermission(models.PermMaterialWrite)(http.HandlerFunc(matHandler.Delete)),
+			).Methods(http.MethodDelete)
+		}
+
+		// ======== PROMOTIONS ========
+		if s.PromotionRepository != nil {
+			promoHandler := handlers.NewPromotionHandler(
+				s.PromotionRepository,
+				s.AlchemistRepository,
+				s.MissionRepository,
+				s.TransmutationRepository,
+				dispatcher,
+				currentUser,
+				currentRole,
+				currentAlchemist,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			promoHandler.HasPermission = s.permissionChecker
+			router.HandleFunc("/ranks", promoHandler.Ladder).Methods(http.MethodGet)
+			router.HandleFunc("/alchemists/{id}/eligibility", promoHandler.Eligibility).Methods(http.MethodGet)
+			router.HandleFunc("/alchemists/{id}/rank-history", promoHandler.History).Methods(http.MethodGet)
+			router.Handle("/alchemists/{id}/promotions",
+				s.RequirePermission(models.PermPromotionRequest)(http.HandlerFunc(promoHandler.GetByAlchemist)),
+			).Methods(http.MethodGet)
+			router.Handle("/alchemists/{id}/promotions",
+				s.RequirePermission(models.PermPromotionRequest)(http.HandlerFunc(promoHandler.Request)),
+			).Methods(http.MethodPost)
+			router.Handle("/promotions",
+				s.RequirePermission(models.PermPromotionReview)(http.HandlerFunc(promoHandler.GetAll)),
+			).Methods(http.MethodGet)
+			router.Handle("/promotions/suggestions",
+				s.RequirePermission(models.PermPromotionReview)(http.HandlerFunc(promoHandler.Suggestions)),
+			).Methods(http.MethodGet)
+			router.Handle("/promotions/{id}/approve",
+				s.RequirePermission(models.PermPromotionReview)(http.HandlerFunc(promoHandler.Approve)),
+			).Methods(http.MethodPost)
+			router.Handle("/promotions/{id}/reject",
+				s.RequirePermission(models.PermPromotionReview)(http.HandlerFunc(promoHandler.Reject)),
+			).Methods(http.MethodPost)
+		}
+
+		// ======== SKILLS ========
+		if s.SkillRepository != nil {
+			skillHandler := handlers.NewSkillHandler(
+				s.SkillRepository,
+				s.AlchemistRepository,
+				s.MissionRepository,
+				s.MaterialRepository,
+				dispatcher,
+				currentUser,
+				currentRole,
+				currentAlchemist,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			skillHandler.HasPermission = s.permissionChecker
+			router.HandleFunc("/skills", skillHandler.GetAll).Methods(http.MethodGet)
+			router.Handle("/skills",
+				s.RequirePermission(models.PermSkillWrite)(http.HandlerFunc(skillHandler.Create)),
+			).Methods(http.MethodPost)
+			router.Handle("/skills/{id}",
+				s.RequirePermission(models.PermSkillWrite)(http.HandlerFunc(skillHandler.Edit)),
+			).Methods(http.MethodPut)
+			router.Handle("/skills/{id}",
+				s.RequirePermission(models.PermSkillWrite)(http.HandlerFunc(skillHandler.Delete)),
+			).Methods(http.MethodDelete)
+
+			router.HandleFunc("/alchemists/{id}/skills", skillHandler.GetByAlchemist).Methods(http.MethodGet)
+			router.Handle("/alchemists/{id}/skills/{skillId}",
+				s.RequirePermission(models.PermSkillLevel)(http.HandlerFunc(skillHandler.SetLevel)),
+			).Methods(http.MethodPut)
+			router.Handle("/alchemists/{id}/skills/{skillId}",
+				s.RequirePermission(models.PermSkillWrite)(http.HandlerFunc(skillHandler.RemoveLevel)),
+			).Methods(http.MethodDelete)
+			router.Handle("/alchemists/{id}/skills/{skillId}/endorsements",
+				s.RequirePermission(models.PermSkillWrite)(http.HandlerFunc(skillHandler.Endorse)),
+			).Methods(http.MethodPost)
+
+			router.HandleFunc("/missions/{id}/skills", skillHandler.GetMissionRequirements).Methods(http.MethodGet)
+			router.Handle("/missions/{id}/skills",
+				s.RequirePermission(models.PermSkillWrite)(http.HandlerFunc(skillHandler.SetMissionRequirements)),
+			).Methods(http.MethodPut)
+			router.Handle("/missions/{id}/candidates",
+				s.RequirePermission(models.PermPlanningRead)(http.HandlerFunc(skillHandler.MissionCandidates)),
+			).Methods(http.MethodGet)
+			router.HandleFunc("/materials/{id}/skills", skillHandler.GetMaterialRequirements).Methods(http.MethodGet)
+			router.Handle("/materials/{id}/skills",
+				s.RequirePermission(models.PermSkillWrite)(http.HandlerFunc(skillHandler.SetMaterialRequirements)),
+			).Methods(http.MethodPut)
+		}
+
+		// ======== PLANNING ========
+		if s.MissionRepository != nil && s.TransmutationRepository != nil {
+			planHandler := handlers.NewPlanningHandler(
+				s.AlchemistRepository,
+				s.MissionRepository,
+				s.TransmutationRepository,
+				s.AvailabilityRepository,
+				divisionScope,
+				s.HandleError,
+				s.logger.Info,
+			)
+			if s.Config.WeeklyCapacityHours > 0 {
+				planHandler.WeeklyCapacityHours = s.Config.WeeklyCapacityHours
+			}
+			if len(s.Config.MissionHoursByDifficulty) > 0 {
+				planHandler.HoursByDifficulty = map[string]float64{}
+				for difficulty, hours := range s.Config.MissionHoursByDifficulty {
+					planHandler.HoursByDifficulty[strings.ToLower(difficulty)] = hours
+				}
+			}
+			if s.Config.DefaultMissionHours > 0 {
+				planHandler.MissionHours = s.Config.DefaultMissionHours
+			}
+			if s.Config.TransmutationHours > 0 {
+				planHandler.TransmutationHours = s.Config.TransmutationHours
+			}
+			router.Handle("/planning/capacity",
+				s.RequirePermission(models.PermPlanningRead)(http.HandlerFunc(planHandler.Capacity)),
+			).Methods(http.MethodGet)
+		}
+
+		// ======== DIVISIONS ========
+		if s.DivisionRepository != nil {
+			divHandler := handlers.NewDivisionHandler(
+				s.DivisionRepository,
+				s.UserRepository,
+				s.AlchemistRepository,
+				divisionScope,
+				dispatcher,
+				currentUser,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			divHandler.RoleHasPermission = func(role, perm string) bool {
+				ok, err := s.permissions.Has(role, perm)
+				return err == nil && ok
+			}
+			router.HandleFunc("/divisions", divHandler.GetAll).Methods(http.MethodGet)
+			router.HandleFunc("/divisions/{id}", divHandler.GetByID).Methods(http.MethodGet)
+			router.HandleFunc("/divisions/{id}/members", divHandler.Members).Methods(http.MethodGet)
+			router.HandleFunc("/alchemists/{id}/reporting-line", divHandler.ReportingLine).Methods(http.MethodGet)
+			router.Handle("/divisions",
+				s.RequirePermission(models.PermDivisionWrite)(http.HandlerFunc(divHandler.Create)),
+			).Methods(http.MethodPost)
+			router.Handle("/divisions/{id}",
+				s.RequirePermission(models.PermDivisionWrite)(http.HandlerFunc(divHandler.Edit)),
+			).Methods(http.MethodPut)
+			router.Handle("/divisions/{id}",
+				s.RequirePermission(models.PermDivisionWrite)(http.HandlerFunc(divHandler.Delete)),
+			).Methods(http.MethodDelete)
+			router.Handle("/divisions/{id}/members/{alchemistId}",
+				s.RequirePermission(models.PermDivisionWrite)(http.HandlerFunc(divHandler.AddMember)),
+			).Methods(http.MethodPut)
+			router.Handle("/divisions/{id}/members/{alchemistId}",
+				s.RequirePermission(models.PermDivisionWrite)(http.HandlerFunc(divHandler.RemoveMember)),
 			).Methods(http.MethodDelete)
 		}
 
+		// ======== ATTACHMENTS ========
+		if s.AttachmentRepository != nil && s.BlobStore != nil {
+			attHandler := handlers.NewAttachmentHandler(
+				s.AttachmentRepository,
+				s.BlobStore,
+				s.AlchemistRepository,
+				s.MissionRepository,
+				s.TransmutationRepository,
+				dispatcher,
+				currentUser,
+				currentRole,
+				currentAlchemist,
+				asyncReporter,
+				s.HandleError,
+				s.logger.Info,
+			)
+			attHandler.HasPermission = s.permissionChecker
+			if s.Config.MaxUploadMB > 0 {
+				attHandler.MaxBytes = int64(s.Config.MaxUploadMB) << 20
+			}
+
+			router.HandleFunc("/alchemists/{id}/photo", attHandler.GetPhoto).Methods(http.MethodGet)
+			router.Handle("/alchemists/{id}/photo",
+				s.RequirePermission(models.PermAttachmentWrite)(http.HandlerFunc(attHandler.UploadPhoto)),
+			).Methods(http.MethodPut)
+			router.Handle("/alchemists/{id}/photo",
+				s.RequirePermission(models.PermAttachmentWrite)(http.HandlerFunc(attHandler.DeletePhoto)),
+			).Methods(http.MethodDelete)
+
+			if s.MissionRepository != nil {
+				router.Handle("/missions/{id}/attachments",
+					s.RequirePermission(models.PermAttachmentRead)(http.HandlerFunc(attHandler.ListMission)),
+				).Methods(http.MethodGet)
+				router.Handle("/missions/{id}/attachments",
+					s.RequirePermission(models.PermAttachmentWrite)(http.HandlerFunc(attHandler.UploadMission)),
+				).Methods(http.MethodPost)
+				router.Handle("/missions/{id}/attachments/{attachmentId}",
+					s.RequirePermission(models.PermAttachmentRead)(http.HandlerFunc(attHandler.DownloadMission)),
+				).Methods(http.MethodGet)
+				router.Handle("/missions/{id}/attachments/{attachmentId}",
+					s.RequirePermission(models.PermAttachmentWrite)(http.HandlerFunc(attHandler.DeleteMission)),
+				).Methods(http.MethodDelete)
+			}
+			if s.TransmutationRepository != nil {
+				router.Handle("/transmutations/{id}/attachments",
+					s.RequirePermission(models.PermAttachmentRead)(http.HandlerFunc(attHandler.ListTransmutation)),
+				).Methods(http.MethodGet)
+				router.Handle("/transmutations/{id}/attachments",
+					s.RequirePermission(models.PermAttachmentWrite)(http.HandlerFunc(attHandler.UploadTransmutation)),
+				).Methods(http.MethodPost)
+				router.Handle("/transmutations/{id}/attachments/{attachmentId}",
+					s.RequirePermission(models.PermAttachmentRead)(http.HandlerFunc(attHandler.DownloadTransmutation)),
+				).Methods(http.MethodGet)
+				router.Handle("/transmutations/{id}/attachments/{attachmentId}",
+					s.RequirePermission(models.PermAttachmentWrite)(http.HandlerFunc(attHandler.DeleteTransmutation)),
+				).Methods(http.MethodDelete)
+			}
+		}
+
 		// ======== AUDITS ========
 		if s.AuditRepository != nil {
 			auditHandler := handlers.NewAuditHandler(
@@ -137,16 +812,20 @@ func (s *Server) router() http.Handler {
 				s.HandleError,
 				s.logger.Info,
 			)
-			router.HandleFunc("/audits", auditHandler.GetAll).Methods(http.MethodGet)
-			router.HandleFunc("/audits/{id}", auditHandler.GetByID).Methods(http.MethodGet)
 			router.Handle("/audits",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(auditHandler.Create)),
+				s.RequirePermission(models.PermAuditRead)(http.HandlerFunc(auditHandler.GetAll)),
+			).Methods(http.MethodGet)
+			router.Handle("/audits/{id}",
+				s.RequirePermission(models.PermAuditRead)(http.HandlerFunc(auditHandler.GetByID)),
+			).Methods(http.MethodGet)
+			router.Handle("/audits",
+				s.RequirePermission(models.PermAuditWrite)(http.HandlerFunc(auditHandler.Create)),
 			).Methods(http.MethodPost)
 			router.Handle("/audits/{id}",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(auditHandler.Edit)),
+				s.RequirePermission(models.PermAuditWrite)(http.HandlerFunc(auditHandler.Edit)),
 			).Methods(http.MethodPut)
 			router.Handle("/audits/{id}",
-				s.AuthMiddleware("supervisor")(http.HandlerFunc(auditHandler.Delete)),
+				s.RequirePermission(models.PermAuditWrite)(http.HandlerFunc(auditHandler.Delete)),
 			).Methods(http.MethodDelete)
 		}
 
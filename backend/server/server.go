@@ -2,9 +2,13 @@ package server
 
 import (
 	"backend-avanzada/config"
+	"backend-avanzada/keyring"
 	"backend-avanzada/logger"
+	"backend-avanzada/mail"
 	"backend-avanzada/models"
+	"backend-avanzada/oidc"
 	"backend-avanzada/repository"
+	"backend-avanzada/storage"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -19,18 +23,44 @@ import (
 
 // Server representa el servidor principal de la aplicación.
 type Server struct {
-	DB                      *gorm.DB
-	Config                  *config.Config
-	Handler                 http.Handler
-	UserRepository          repository.UserRepository
-	AlchemistRepository     *repository.AlchemistRepository
-	MissionRepository       *repository.MissionRepository       // ✅ CRUD Missions
-	MaterialRepository      *repository.MaterialRepository      // CRUD Materials
-	TransmutationRepository *repository.TransmutationRepository // CRUD Transmutations
-	AuditRepository         *repository.AuditRepository         // CRUD Audits
-	jwtSecret               string
-	logger                  *logger.Logger
-	taskQueue               *TaskQueue
+	DB                          *gorm.DB
+	Config                      *config.Config
+	Handler                     http.Handler
+	UserRepository              repository.UserRepository
+	AlchemistRepository         *repository.AlchemistRepository
+	MissionRepository           *repository.MissionRepository           // ✅ CRUD Missions
+	MissionTaskRepository       *repository.MissionTaskRepository       // Subtareas de misiones
+	MissionTemplateRepository   *repository.MissionTemplateRepository   // Plantillas y misiones recurrentes
+	MissionCommentRepository    *repository.MissionCommentRepository    // Comentarios de misiones
+	MissionDependencyRepository *repository.MissionDependencyRepository // Dependencias entre misiones
+	MaterialRepository          *repository.MaterialRepository          // CRUD Materials
+	TransmutationRepository     *repository.TransmutationRepository     // CRUD Transmutations
+	AuditRepository             *repository.AuditRepository             // CRUD Audits
+	CalendarFeedRepository      *repository.CalendarFeedRepository      // Feeds iCalendar
+	CertificationRepository     *repository.CertificationRepository     // Licencias y certificaciones
+	SkillRepository             *repository.SkillRepository             // Habilidades y requisitos
+	PromotionRepository         *repository.PromotionRepository         // Ascensos e historial de rangos
+	AvailabilityRepository      *repository.AvailabilityRepository      // Permisos, formación y despliegues
+	StatsRepository             *repository.StatsRepository             // Estadísticas de rendimiento
+	AttachmentRepository        *repository.AttachmentRepository        // Fotos y adjuntos
+	DivisionRepository          *repository.DivisionRepository          // Divisiones y líneas de reporte
+	InvitationRepository        *repository.InvitationRepository        // Códigos de invitación
+	RefreshTokenRepository      *repository.RefreshTokenRepository      // Sesiones de refresco
+	RoleRepository              *repository.RoleRepository              // Roles y permisos
+	APIKeyRepository            *repository.APIKeyRepository            // Claves de integración
+	AccountTokenRepository      *repository.AccountTokenRepository      // Enlaces de cuenta por correo
+	RecoveryCodeRepository      *repository.RecoveryCodeRepository      // Códigos de recuperación del doble factor
+	BlobStore                   storage.BlobStore                       // Almacenamiento de ficheros subidos
+	Mailer                      mail.Sender                             // Envío de correo
+	OIDC                        *oidc.Provider                          // Login con el proveedor de identidad (SSO)
+	jwtSecret                   string
+	keys                        *keyring.Keyring
+	revocations                 RevocationList
+	loginThrottle               *RedisLoginThrottle
+	permissions                 *permissionCache
+	userStatus                  *userStatusCache
+	logger                      *logger.Logger
+	taskQueue                   *TaskQueue
 }
 
 // NewServer inicializa la instancia del servidor.
@@ -46,6 +76,17 @@ func NewServer() *Server {
 	if err := json.Unmarshal(configFile, &cfg); err != nil {
 		s.logger.Fatal(err)
 	}
+	// CONFIG_OVERRIDE apunta a un fichero cuyas claves sustituyen a las de
+	// config.json (p. ej. config/config.dev.json en desarrollo).
+	if path := os.Getenv("CONFIG_OVERRIDE"); path != "" {
+		override, err := os.ReadFile(path)
+		if err != nil {
+			s.logger.Fatal(err)
+		}
+		if err := json.Unmarshal(override, &cfg); err != nil {
+			s.logger.Fatal(err)
+		}
+	}
 	s.Config = &cfg
 
 	// Cargar secreto JWT desde .env
@@ -54,14 +95,49 @@ func NewServer() *Server {
 		s.logger.Fatal(fmt.Errorf("JWT_SECRET is not set in environment"))
 	}
 	s.jwtSecret = secret
+	if err := s.initKeys(); err != nil {
+		s.logger.Fatal(err)
+	}
 
 	return s
 }
 
+// initKeys carga las claves de firma de los tokens de sesión. Sin
+// jwt_keys_dir se firma con HS256 y JWT_SECRET, como hasta ahora; el secreto
+// se sigue usando para los tokens internos (invitaciones, enlaces por correo).
+func (s *Server) initKeys() error {
+	if s.Config.JWTKeysDir == "" {
+		s.keys = keyring.NewHMAC([]byte(s.jwtSecret))
+		return nil
+	}
+	keys, err := keyring.LoadDir(s.Config.JWTKeysDir, s.Config.JWTActiveKeyID)
+	if err != nil {
+		return err
+	}
+	if s.Config.JWTAcceptHS256 {
+		keys.AcceptHMAC([]byte(s.jwtSecret))
+	}
+	fmt.Println("Firmando tokens con la clave", keys.ActiveID())
+	s.keys = keys
+	return nil
+}
+
 // StartServer arranca el servidor HTTP.
 func (s *Server) StartServer() {
 	fmt.Println("Inicializando base de datos...")
 	s.initDB()
+	if err := s.bootstrapAdmin(); err != nil {
+		s.logger.Fatal(err)
+	}
+	if err := s.initStorage(); err != nil {
+		s.logger.Fatal(err)
+	}
+	if err := s.initMail(); err != nil {
+		s.logger.Fatal(err)
+	}
+	if err := s.initOIDC(); err != nil {
+		s.logger.Fatal(err)
+	}
 	if err := s.initAsyncInfrastructure(); err != nil {
 		s.logger.Fatal(err)
 	}
@@ -70,7 +146,8 @@ func (s *Server) StartServer() {
 	corsObj := handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}),
+		handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", "X-API-Key"}),
+		handlers.ExposedHeaders([]string{"Retry-After"}),
 	)
 
 	fmt.Println("Inicializando mux...")
@@ -114,9 +191,38 @@ func (s *Server) initDB() {
 		&models.User{},
 		&models.Alchemist{},
 		&models.Mission{}, // ✅ Importante para CRUD Missions
+		&models.MissionTask{},
+		&models.MissionMaterial{},
+		&models.MissionTemplate{},
+		&models.MissionTemplateMaterial{},
+		&models.MissionTemplateTask{},
+		&models.MissionComment{},
+		&models.MissionCommentMention{},
+		&models.MissionStatusChange{},
+		&models.MissionDependency{},
 		&models.Material{},
 		&models.Transmutation{},
 		&models.Audit{},
+		&models.CalendarFeed{},
+		&models.Certification{},
+		&models.Skill{},
+		&models.AlchemistSkill{},
+		&models.SkillEndorsement{},
+		&models.SkillRequirement{},
+		&models.PromotionRequest{},
+		&models.RankChange{},
+		&models.AvailabilityWindow{},
+		&models.Attachment{},
+		&models.Division{},
+		&models.Invitation{},
+		&models.RefreshToken{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.KnownPermission{},
+		&models.APIKey{},
+		&models.APIKeyPermission{},
+		&models.AccountToken{},
+		&models.RecoveryCode{},
 	)
 	if err != nil {
 		s.logger.Fatal(err)
@@ -126,10 +232,116 @@ func (s *Server) initDB() {
 	s.UserRepository = repository.NewUserRepository(s.DB)
 	s.AlchemistRepository = repository.NewAlchemistRepository(s.DB)
 	s.MissionRepository = repository.NewMissionRepository(s.DB) // ✅
+	s.MissionTaskRepository = repository.NewMissionTaskRepository(s.DB)
+	s.MissionTemplateRepository = repository.NewMissionTemplateRepository(s.DB)
+	s.MissionCommentRepository = repository.NewMissionCommentRepository(s.DB)
+	s.MissionDependencyRepository = repository.NewMissionDependencyRepository(s.DB)
 	s.MaterialRepository = repository.NewMaterialRepository(s.DB)
 	s.TransmutationRepository = repository.NewTransmutationRepository(s.DB)
 	s.AuditRepository = repository.NewAuditRepository(s.DB)
+	s.CalendarFeedRepository = repository.NewCalendarFeedRepository(s.DB)
+	s.CertificationRepository = repository.NewCertificationRepository(s.DB)
+	s.SkillRepository = repository.NewSkillRepository(s.DB)
+	s.PromotionRepository = repository.NewPromotionRepository(s.DB)
+	s.AvailabilityRepository = repository.NewAvailabilityRepository(s.DB)
+	s.StatsRepository = repository.NewStatsRepository(s.DB)
+	s.AttachmentRepository = repository.NewAttachmentRepository(s.DB)
+	s.DivisionRepository = repository.NewDivisionRepository(s.DB)
+	s.InvitationRepository = repository.NewInvitationRepository(s.DB)
+	s.RefreshTokenRepository = repository.NewRefreshTokenRepository(s.DB)
+	s.RoleRepository = repository.NewRoleRepository(s.DB)
+	s.APIKeyRepository = repository.NewAPIKeyRepository(s.DB)
+	s.AccountTokenRepository = repository.NewAccountTokenRepository(s.DB)
+	s.RecoveryCodeRepository = repository.NewRecoveryCodeRepository(s.DB)
+
+	// 🔹 Roles de sistema
+	if err := s.RoleRepository.SeedDefaults(models.DefaultRoles, models.Permissions); err != nil {
+		s.logger.Fatal(err)
+	}
+}
+
+// initStorage prepara el almacén de ficheros subidos (fotos y adjuntos).
+func (s *Server) initStorage() error {
+	dir := s.Config.StorageDir
+	if dir == "" {
+		dir = "uploads"
+	}
+	store, err := storage.NewLocalStore(dir)
+	if err != nil {
+		return err
+	}
+	s.BlobStore = store
+	return nil
+}
+
+// initMail elige el emisor de correo según mail_driver. Las credenciales SMTP
+// se leen de SMTP_USERNAME y SMTP_PASSWORD.
+func (s *Server) initMail() error {
+	from := s.Config.MailFrom
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	switch s.Config.MailDriver {
+	case "smtp":
+		port := s.Config.SMTPPort
+		if port == 0 {
+			port = 587
+		}
+		s.Mailer = mail.NewSMTPSender(s.Config.SMTPHost, port, from,
+			os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	case "file":
+		dir := s.Config.MailDir
+		if dir == "" {
+			dir = "outbox"
+		}
+		sender, err := mail.NewFileSender(dir, from)
+		if err != nil {
+			return err
+		}
+		s.Mailer = sender
+	case "log", "":
+		s.Mailer = mail.NewLogSender(os.Stdout, from)
+	default:
+		return fmt.Errorf("unknown mail_driver %q", s.Config.MailDriver)
+	}
+	return nil
+}
+
+// initOIDC configura el login con el proveedor OpenID Connect si hay issuer.
+// El secreto del cliente se lee de OIDC_CLIENT_SECRET. Los roles de las reglas
+// y el rol por defecto tienen que existir.
+func (s *Server) initOIDC() error {
+	if s.Config.OIDCIssuer == "" {
+		return nil
+	}
+	roles := []string{s.Config.OIDCDefaultRole}
+	for _, m := range s.Config.OIDCRoleMappings {
+		roles = append(roles, m.Role)
+	}
+	for _, name := range roles {
+		if name == "" {
+			continue
+		}
+		role, err := s.RoleRepository.FindByName(name)
+		if err != nil {
+			return err
+		}
+		if role == nil {
+			return fmt.Errorf("oidc: role %q does not exist", name)
+		}
+	}
+	s.OIDC = oidc.New(oidc.Config{
+		Issuer:       s.Config.OIDCIssuer,
+		ClientID:     s.Config.OIDCClientID,
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  s.Config.OIDCRedirectURL,
+		Scopes:       s.Config.OIDCScopes,
+		AuthURL:      s.Config.OIDCAuthURL,
+	})
+	fmt.Println("Login SSO habilitado con", s.Config.OIDCIssuer)
+	return nil
 }
+
 func (s *Server) initAsyncInfrastructure() error {
 	redisAddr := s.Config.RedisAddress
 	if redisAddr == "" {
@@ -141,6 +353,8 @@ func (s *Server) initAsyncInfrastructure() error {
 		s.AuditRepository,
 		s.MissionRepository,
 		s.MaterialRepository,
+		s.MissionTemplateRepository,
+		s.CertificationRepository,
 	)
 
 	verificationInterval := time.Duration(s.Config.VerificationIntervalMinutes) * time.Minute
@@ -148,13 +362,37 @@ func (s *Server) initAsyncInfrastructure() error {
 	lowStock := s.Config.MaterialLowStockThreshold
 
 	s.taskQueue.ConfigureThresholds(verificationInterval, pendingHours, lowStock)
+	s.taskQueue.ConfigureRecurrence(time.Duration(s.Config.RecurringMissionsIntervalMinutes) * time.Minute)
+	s.taskQueue.ConfigureCertificationWarning(time.Duration(s.Config.CertificationExpiryWarningDays) * 24 * time.Hour)
 	if err := s.taskQueue.Start(); err != nil {
 		return err
 	}
 	s.taskQueue.ScheduleDailyVerification()
+	s.taskQueue.ScheduleRecurringMissions()
+	s.taskQueue.ScheduleTransmutations()
+	s.revocations = NewRedisRevocationList(NewRedisClient(redisAddr))
+	s.loginThrottle = NewRedisLoginThrottle(NewRedisClient(redisAddr), s.loginPolicy())
 	return nil
 }
 
+// loginPolicy aplica la configuración sobre DefaultLoginPolicy.
+func (s *Server) loginPolicy() LoginPolicy {
+	p := DefaultLoginPolicy
+	if s.Config.LoginFreeAttempts > 0 {
+		p.FreeAttempts = int64(s.Config.LoginFreeAttempts)
+	}
+	if s.Config.LoginLockoutAttempts > 0 {
+		p.LockoutAttempts = int64(s.Config.LoginLockoutAttempts)
+	}
+	if s.Config.LoginIPLockoutAttempts > 0 {
+		p.IPLockoutAttempts = int64(s.Config.LoginIPLockoutAttempts)
+	}
+	if s.Config.LoginLockoutMinutes > 0 {
+		p.Lockout = time.Duration(s.Config.LoginLockoutMinutes) * time.Minute
+	}
+	return p
+}
+
 // GetJWTSecret devuelve la clave secreta usada para firmar los tokens JWT.
 func (s *Server) GetJWTSecret() string {
 	return s.jwtSecret
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"backend-avanzada/config"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB abre una base SQLite en memoria propia del test con las tablas
+// de los modelos indicados.
+func newTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestInitOIDCValidatesRoles(t *testing.T) {
+	db := newTestDB(t, &models.Role{}, &models.RolePermission{}, &models.KnownPermission{})
+	roles := repository.NewRoleRepository(db)
+	if err := roles.SeedDefaults(models.DefaultRoles, models.Permissions); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		mappings []config.OIDCRoleMapping
+		fallback string
+		ok       bool
+	}{
+		{"roles existentes", []config.OIDCRoleMapping{{Value: "staff", Role: "supervisor"}}, "alchemist", true},
+		{"sin rol por defecto", []config.OIDCRoleMapping{{Value: "staff", Role: "supervisor"}}, "", true},
+		{"regla con rol inexistente", []config.OIDCRoleMapping{{Value: "staff", Role: "superviser"}}, "alchemist", false},
+		{"rol por defecto inexistente", nil, "ghost", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := &Server{
+				Config: &config.Config{
+					OIDCIssuer:       "https://idp.example.com",
+					OIDCRoleMappings: c.mappings,
+					OIDCDefaultRole:  c.fallback,
+				},
+				RoleRepository: roles,
+			}
+			err := s.initOIDC()
+			if (err == nil) != c.ok {
+				t.Fatalf("initOIDC error = %v, want ok=%v", err, c.ok)
+			}
+			if (s.OIDC != nil) != c.ok {
+				t.Errorf("provider configured = %v, want %v", s.OIDC != nil, c.ok)
+			}
+		})
+	}
+}
@@ -11,13 +11,19 @@ import (
 
 	"backend-avanzada/logger"
 	"backend-avanzada/models"
+	"backend-avanzada/recurrence"
 	"backend-avanzada/repository"
 )
 
 const (
-	taskTypeProcessTransmutation = "process_transmutation"
-	taskTypeRegisterAudit        = "register_audit"
-	taskTypeDailyVerification    = "daily_verification"
+	taskTypeProcessTransmutation    = "process_transmutation"
+	taskTypeRegisterAudit           = "register_audit"
+	taskTypeDailyVerification       = "daily_verification"
+	taskTypeRecurringMissions       = "recurring_missions"
+	taskTypeScheduledTransmutations = "scheduled_transmutations"
+
+	// Cada cuánto se liberan las transmutaciones programadas que ya vencieron.
+	scheduledTransmutationsEvery = time.Minute
 )
 
 type queueTask struct {
@@ -42,6 +48,14 @@ type dailyVerificationPayload struct {
 	ExecutedAt time.Time `json:"executed_at"`
 }
 
+type recurringMissionsPayload struct {
+	ExecutedAt time.Time `json:"executed_at"`
+}
+
+type scheduledTransmutationsPayload struct {
+	ExecutedAt time.Time `json:"executed_at"`
+}
+
 // TaskQueue orchestrates all background work for the application. It provides
 // helpers for HTTP handlers to enqueue jobs and executes them in a dedicated
 // worker that relies on Redis for coordination.
@@ -54,24 +68,32 @@ type TaskQueue struct {
 	auditRepo          *repository.AuditRepository
 	missionRepo        *repository.MissionRepository
 	materialRepo       *repository.MaterialRepository
+	templateRepo       *repository.MissionTemplateRepository
+	certRepo           *repository.CertificationRepository
 	verificationTicker *time.Ticker
 	verificationEvery  time.Duration
+	recurrenceTicker   *time.Ticker
+	recurrenceEvery    time.Duration
+	scheduleTicker     *time.Ticker
 	pendingThreshold   time.Duration
 	lowStockThreshold  float64
+	certificationAhead time.Duration
 	started            bool
 }
 
 func NewTaskQueue(redisAddr string, log *logger.Logger) *TaskQueue {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &TaskQueue{
-		redis:             NewRedisClient(redisAddr),
-		logger:            log,
-		ctx:               ctx,
-		cancel:            cancel,
-		started:           false,
-		lowStockThreshold: 5,
-		verificationEvery: 24 * time.Hour,
-		pendingThreshold:  24 * time.Hour,
+		redis:              NewRedisClient(redisAddr),
+		logger:             log,
+		ctx:                ctx,
+		cancel:             cancel,
+		started:            false,
+		lowStockThreshold:  5,
+		verificationEvery:  24 * time.Hour,
+		recurrenceEvery:    time.Hour,
+		pendingThreshold:   24 * time.Hour,
+		certificationAhead: 30 * 24 * time.Hour,
 	}
 }
 
@@ -80,11 +102,15 @@ func (q *TaskQueue) WithRepositories(
 	auditRepo *repository.AuditRepository,
 	missionRepo *repository.MissionRepository,
 	materialRepo *repository.MaterialRepository,
+	templateRepo *repository.MissionTemplateRepository,
+	certRepo *repository.CertificationRepository,
 ) {
 	q.transRepo = transRepo
 	q.auditRepo = auditRepo
 	q.missionRepo = missionRepo
 	q.materialRepo = materialRepo
+	q.templateRepo = templateRepo
+	q.certRepo = certRepo
 }
 
 func (q *TaskQueue) ConfigureThresholds(verificationEvery, pendingThreshold time.Duration, lowStockThreshold float64) {
@@ -99,6 +125,21 @@ func (q *TaskQueue) ConfigureThresholds(verificationEvery, pendingThreshold time
 	}
 }
 
+// ConfigureRecurrence sets how often due mission templates are checked.
+func (q *TaskQueue) ConfigureRecurrence(every time.Duration) {
+	if every > 0 {
+		q.recurrenceEvery = every
+	}
+}
+
+// ConfigureCertificationWarning sets how far ahead the daily verification
+// looks for certifications about to expire.
+func (q *TaskQueue) ConfigureCertificationWarning(ahead time.Duration) {
+	if ahead > 0 {
+		q.certificationAhead = ahead
+	}
+}
+
 // Start spins up the worker that consumes jobs from Redis.
 func (q *TaskQueue) Start() error {
 	if q.started {
@@ -118,6 +159,12 @@ func (q *TaskQueue) Stop() {
 	if q.verificationTicker != nil {
 		q.verificationTicker.Stop()
 	}
+	if q.recurrenceTicker != nil {
+		q.recurrenceTicker.Stop()
+	}
+	if q.scheduleTicker != nil {
+		q.scheduleTicker.Stop()
+	}
 }
 
 // ScheduleDailyVerification enqueues verification jobs at the configured interval.
@@ -145,6 +192,55 @@ func (q *TaskQueue) ScheduleDailyVerification() {
 	}()
 }
 
+// ScheduleRecurringMissions periodically enqueues the instantiation of mission
+// templates whose next run is due.
+func (q *TaskQueue) ScheduleRecurringMissions() {
+	if !q.started || q.templateRepo == nil {
+		return
+	}
+	q.logger.Printf("[async] revisando misiones recurrentes cada %s", q.recurrenceEvery)
+	q.recurrenceTicker = time.NewTicker(q.recurrenceEvery)
+	go func() {
+		if err := q.enqueueRecurringMissions(); err != nil {
+			q.logger.Printf("[async] no se pudo encolar revisión inicial de misiones recurrentes: %v", err)
+		}
+		for {
+			select {
+			case <-q.ctx.Done():
+				return
+			case <-q.recurrenceTicker.C:
+				if err := q.enqueueRecurringMissions(); err != nil {
+					q.logger.Printf("[async] error encolando misiones recurrentes: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// ScheduleTransmutations periodically releases scheduled transmutations whose
+// time has come so they get processed.
+func (q *TaskQueue) ScheduleTransmutations() {
+	if !q.started || q.transRepo == nil {
+		return
+	}
+	q.scheduleTicker = time.NewTicker(scheduledTransmutationsEvery)
+	go func() {
+		if err := q.enqueueScheduledTransmutations(); err != nil {
+			q.logger.Printf("[async] no se pudo encolar revisión inicial de transmutaciones programadas: %v", err)
+		}
+		for {
+			select {
+			case <-q.ctx.Done():
+				return
+			case <-q.scheduleTicker.C:
+				if err := q.enqueueScheduledTransmutations(); err != nil {
+					q.logger.Printf("[async] error encolando transmutaciones programadas: %v", err)
+				}
+			}
+		}
+	}()
+}
+
 // EnqueueTransmutationProcessing schedules the heavy processing of a transmutation.
 func (q *TaskQueue) EnqueueTransmutationProcessing(transmutationID uint, requestedBy string) error {
 	payload := processTransmutationPayload{TransmutationID: transmutationID, RequestedBy: requestedBy}
@@ -162,6 +258,16 @@ func (q *TaskQueue) enqueueDailyVerification() error {
 	return q.enqueue(taskTypeDailyVerification, payload)
 }
 
+func (q *TaskQueue) enqueueRecurringMissions() error {
+	payload := recurringMissionsPayload{ExecutedAt: time.Now().UTC()}
+	return q.enqueue(taskTypeRecurringMissions, payload)
+}
+
+func (q *TaskQueue) enqueueScheduledTransmutations() error {
+	payload := scheduledTransmutationsPayload{ExecutedAt: time.Now().UTC()}
+	return q.enqueue(taskTypeScheduledTransmutations, payload)
+}
+
 func (q *TaskQueue) enqueue(taskType string, payload interface{}) error {
 	if !q.started {
 		return errors.New("async queue has not been started")
@@ -221,6 +327,10 @@ func (q *TaskQueue) dispatch(task queueTask) error {
 		return q.handleAudit(payload)
 	case taskTypeDailyVerification:
 		return q.handleDailyVerification()
+	case taskTypeRecurringMissions:
+		return q.handleRecurringMissions()
+	case taskTypeScheduledTransmutations:
+		return q.handleScheduledTransmutations()
 	default:
 		return fmt.Errorf("tipo de tarea desconocido: %s", task.Type)
 	}
@@ -240,10 +350,17 @@ func (q *TaskQueue) handleTransmutation(payload processTransmutationPayload) err
 	if strings.EqualFold(transmutation.Status, "completada") {
 		return nil
 	}
+	// Las programadas se procesan cuando el planificador las libera.
+	if transmutation.Status == "programada" ||
+		transmutation.ScheduledAt != nil && transmutation.ScheduledAt.After(time.Now()) {
+		return nil
+	}
 
 	// Simula un trabajo costoso.
 	time.Sleep(3 * time.Second)
+	completedAt := time.Now()
 	transmutation.Status = "completada"
+	transmutation.CompletedAt = &completedAt
 	transmutation.Result = fmt.Sprintf("Transmutación %d procesada exitosamente", transmutation.ID)
 	if _, err := q.transRepo.Save(transmutation); err != nil {
 		return err
@@ -262,6 +379,32 @@ func (q *TaskQueue) handleTransmutation(payload processTransmutationPayload) err
 	return nil
 }
 
+// handleScheduledTransmutations libera las transmutaciones programadas que ya
+// vencieron y encola su procesamiento. La liberación es condicional, así que
+// dos pasadas simultáneas no procesan dos veces la misma.
+func (q *TaskQueue) handleScheduledTransmutations() error {
+	if q.transRepo == nil {
+		return errors.New("transmutation repository is not configured")
+	}
+	due, err := q.transRepo.FindScheduledDue(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, t := range due {
+		released, err := q.transRepo.Release(t)
+		if err != nil {
+			return err
+		}
+		if !released {
+			continue
+		}
+		if err := q.EnqueueTransmutationProcessing(t.ID, "system"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (q *TaskQueue) handleAudit(payload registerAuditPayload) error {
 	if q.auditRepo == nil {
 		return errors.New("audit repository is not configured")
@@ -315,6 +458,22 @@ func (q *TaskQueue) handleDailyVerification() error {
 		}
 	}
 
+	if q.certRepo != nil {
+		now := time.Now()
+		expiring, err := q.certRepo.FindExpiringBetween(now, now.Add(q.certificationAhead))
+		if err != nil {
+			return err
+		}
+		if len(expiring) > 0 {
+			ids := make([]string, 0, len(expiring))
+			for _, c := range expiring {
+				ids = append(ids, fmt.Sprintf("%d (alquimista %d)", c.ID, c.AlchemistID))
+			}
+			days := int(q.certificationAhead / (24 * time.Hour))
+			details = append(details, fmt.Sprintf("%d certificaciones vencen en los próximos %d días: %s", len(expiring), days, strings.Join(ids, ", ")))
+		}
+	}
+
 	if len(details) == 0 {
 		details = append(details, "Sin hallazgos críticos")
 	}
@@ -329,6 +488,66 @@ func (q *TaskQueue) handleDailyVerification() error {
 	return err
 }
 
+// handleRecurringMissions instancia una misión por cada plantilla vencida y
+// programa su siguiente ejecución. Si el servidor estuvo detenido varios
+// periodos solo se crea una misión y la programación salta al futuro. Las
+// plantillas cuya regla ya no se puede interpretar se desactivan para que no
+// fallen en cada pasada.
+func (q *TaskQueue) handleRecurringMissions() error {
+	if q.templateRepo == nil {
+		return errors.New("mission template repository is not configured")
+	}
+	now := time.Now()
+	due, err := q.templateRepo.FindDue(now)
+	if err != nil {
+		return err
+	}
+	for _, t := range due {
+		rule, err := recurrence.Parse(t.Recurrence)
+		if err != nil {
+			q.logger.Printf("[async] plantilla %d con recurrencia inválida, se desactiva: %v", t.ID, err)
+			if err := q.templateRepo.Deactivate(t); err != nil {
+				return err
+			}
+			if q.auditRepo != nil {
+				audit := registerAuditPayload{
+					Action:    "update",
+					Entity:    "mission_template",
+					EntityID:  t.ID,
+					UserEmail: "system",
+					Details:   fmt.Sprintf("Plantilla %d desactivada por recurrencia inválida (%q): %v", t.ID, t.Recurrence, err),
+				}
+				if err := q.handleAudit(audit); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		next := rule.After(t.StartsAt, now)
+		m, err := q.templateRepo.Instantiate(t, now, &next)
+		if errors.Is(err, repository.ErrTemplateAlreadyRun) {
+			// Otra instancia ya creó la misión de esta ejecución.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if q.auditRepo != nil {
+			audit := registerAuditPayload{
+				Action:    "create",
+				Entity:    "mission",
+				EntityID:  m.ID,
+				UserEmail: "system",
+				Details:   fmt.Sprintf("Misión recurrente creada desde la plantilla %d", t.ID),
+			}
+			if err := q.handleAudit(audit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // asyncErrorReporter creates a helper that handlers can use to report async issues.
 func (s *Server) asyncErrorReporter() func(path string, err error) {
 	return func(path string, err error) {
@@ -346,3 +565,19 @@ func currentUserExtractor(r *http.Request) string {
 	}
 	return ""
 }
+
+// currentRoleExtractor returns the role stored in the JWT claims.
+func currentRoleExtractor(r *http.Request) string {
+	if claims := GetAuthClaims(r); claims != nil {
+		return claims.Role
+	}
+	return ""
+}
+
+// currentAlchemistExtractor returns the alchemist profile linked to the JWT, or 0.
+func currentAlchemistExtractor(r *http.Request) uint {
+	if claims := GetAuthClaims(r); claims != nil {
+		return claims.AlchemistID
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"backend-avanzada/logger"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestHandleRecurringMissions(t *testing.T) {
+	db := newTestDB(t,
+		&models.MissionTemplate{}, &models.MissionTemplateMaterial{}, &models.MissionTemplateTask{},
+		&models.Mission{}, &models.MissionTask{}, &models.MissionMaterial{}, &models.Audit{},
+	)
+	lg := logger.NewLogger()
+	lg.SetOutput(io.Discard)
+	q := &TaskQueue{
+		logger:       lg,
+		templateRepo: repository.NewMissionTemplateRepository(db),
+		auditRepo:    repository.NewAuditRepository(db),
+	}
+
+	past := time.Now().Add(-time.Hour)
+	valid := &models.MissionTemplate{Title: "Inventario", Recurrence: "FREQ=DAILY", StartsAt: past.Add(-48 * time.Hour), NextRunAt: &past, Active: true}
+	broken := &models.MissionTemplate{Title: "Rota", Recurrence: "FREQ=HOURLY", StartsAt: past, NextRunAt: &past, Active: true}
+	for _, tpl := range []*models.MissionTemplate{valid, broken} {
+		if err := db.Create(tpl).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := q.handleRecurringMissions(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got models.MissionTemplate
+	if err := db.First(&got, valid.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !got.Active || got.NextRunAt == nil || !got.NextRunAt.After(time.Now()) {
+		t.Errorf("valid template: active=%v next=%v, want active and rescheduled", got.Active, got.NextRunAt)
+	}
+
+	var disabled models.MissionTemplate
+	if err := db.First(&disabled, broken.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if disabled.Active || disabled.NextRunAt != nil {
+		t.Errorf("broken template: active=%v next=%v, want deactivated without next run", disabled.Active, disabled.NextRunAt)
+	}
+	var audits int64
+	db.Model(&models.Audit{}).Where("entity = ? AND entity_id = ?", "mission_template", broken.ID).Count(&audits)
+	if audits != 1 {
+		t.Errorf("broken template audits = %d, want 1", audits)
+	}
+
+	var missions []models.Mission
+	if err := db.Find(&missions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(missions) != 1 || missions[0].TemplateID == nil || *missions[0].TemplateID != valid.ID {
+		t.Fatalf("missions = %+v, want one from template %d", missions, valid.ID)
+	}
+
+	// En la siguiente pasada ninguna plantilla está vencida: la rota ya no
+	// vuelve a fallar.
+	if err := q.handleRecurringMissions(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&models.Mission{}).Count(&count)
+	db.Model(&models.Audit{}).Where("entity = ?", "mission_template").Count(&audits)
+	if count != 1 || audits != 1 {
+		t.Errorf("second run: %d missions, %d template audits; want 1 and 1", count, audits)
+	}
+}
+
+func TestHandleScheduledTransmutations(t *testing.T) {
+	db := newTestDB(t, &models.Transmutation{})
+	client, fake := newFakeRedis(t)
+	lg := logger.NewLogger()
+	lg.SetOutput(io.Discard)
+	q := &TaskQueue{
+		redis:     client,
+		logger:    lg,
+		ctx:       context.Background(),
+		started:   true,
+		transRepo: repository.NewTransmutationRepository(db),
+	}
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	due := &models.Transmutation{AlchemistID: 1, MaterialID: 1, Status: "programada", ScheduledAt: &past}
+	later := &models.Transmutation{AlchemistID: 1, MaterialID: 1, Status: "programada", ScheduledAt: &future}
+	for _, tr := range []*models.Transmutation{due, later} {
+		if err := db.Create(tr).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Una programada para el futuro no se procesa aunque llegue a la cola.
+	if err := q.handleTransmutation(processTransmutationPayload{TransmutationID: later.ID}); err != nil {
+		t.Fatal(err)
+	}
+	status := func(id uint) string {
+		var got models.Transmutation
+		if err := db.First(&got, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return got.Status
+	}
+	if got := status(later.ID); got != "programada" {
+		t.Fatalf("future transmutation status = %q, want programada", got)
+	}
+
+	// Dos pasadas: la vencida se libera y se encola una sola vez.
+	for range 2 {
+		if err := q.handleScheduledTransmutations(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := status(due.ID); got != "en_proceso" {
+		t.Errorf("due transmutation status = %q, want en_proceso", got)
+	}
+	if got := status(later.ID); got != "programada" {
+		t.Errorf("future transmutation status = %q, want programada", got)
+	}
+	queued := fake.list(redisQueueKey)
+	if len(queued) != 1 {
+		t.Fatalf("queued tasks = %v, want one", queued)
+	}
+	var task queueTask
+	var payload processTransmutationPayload
+	if err := json.Unmarshal([]byte(queued[0]), &task); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if task.Type != taskTypeProcessTransmutation || payload.TransmutationID != due.ID {
+		t.Errorf("queued task = %s %+v, want processing of %d", task.Type, payload, due.ID)
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"backend-avanzada/repository"
+	"sync"
+	"time"
+)
+
+// authCacheTTL limita cuánto tiempo se reutiliza el estado de una cuenta o los
+// permisos de un rol. Invalidate solo afecta a la instancia que hizo el
+// cambio; con varias réplicas, las demás lo ven como tarde al caducar la
+// entrada.
+const authCacheTTL = 30 * time.Second
+
+type cachedStatus struct {
+	disabled bool
+	expires  time.Time
+}
+
+// userStatusCache recuerda qué cuentas están desactivadas para no consultar
+// la base de datos en cada petición. Se invalida al activar o desactivar una
+// y cada entrada caduca a los authCacheTTL.
+type userStatusCache struct {
+	repo     repository.UserRepository
+	mu       sync.RWMutex
+	disabled map[string]cachedStatus
+}
+
+func newUserStatusCache(repo repository.UserRepository) *userStatusCache {
+	return &userStatusCache{repo: repo, disabled: map[string]cachedStatus{}}
+}
+
+// Disabled indica si la cuenta del email está desactivada. Los emails sin
+// cuenta no se consideran desactivados.
+func (c *userStatusCache) Disabled(email string) (bool, error) {
+	c.mu.RLock()
+	entry, ok := c.disabled[email]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.disabled, nil
+	}
+
+	u, err := c.repo.FindByEmail(email)
+	if err != nil {
+		return false, err
+	}
+	disabled := u != nil && u.Disabled()
+
+	c.mu.Lock()
+	c.disabled[email] = cachedStatus{disabled: disabled, expires: time.Now().Add(authCacheTTL)}
+	c.mu.Unlock()
+	return disabled, nil
+}
+
+// Invalidate descarta el estado guardado de una cuenta tras cambiarlo.
+func (c *userStatusCache) Invalidate(email string) {
+	c.mu.Lock()
+	delete(c.disabled, email)
+	c.mu.Unlock()
+}
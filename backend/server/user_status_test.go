@@ -0,0 +1,50 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"testing"
+	"time"
+)
+
+func TestUserStatusCacheExpires(t *testing.T) {
+	db := newTestDB(t, &models.User{})
+	user := &models.User{Email: "ana@example.com", PasswordHash: "x", Role: "alchemist"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	c := newUserStatusCache(repository.NewUserRepository(db))
+	if disabled, err := c.Disabled(user.Email); err != nil || disabled {
+		t.Fatalf("Disabled before change = %v, %v", disabled, err)
+	}
+
+	// Otra réplica desactiva la cuenta sin invalidar esta caché.
+	if err := db.Model(user).Update("disabled_at", time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		expire bool
+		want   bool
+	}{
+		{"entrada vigente", false, false},
+		{"entrada caducada", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.expire {
+				entry := c.disabled[user.Email]
+				entry.expires = time.Now().Add(-time.Second)
+				c.disabled[user.Email] = entry
+			}
+			got, err := c.Disabled(user.Email)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Disabled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+// Package storage define el almacenamiento de ficheros (blobs) de la API y
+// una implementación sobre el sistema de ficheros local.
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound se devuelve cuando la clave no existe en el almacén.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore guarda contenido binario identificado por una clave con forma de
+// ruta relativa ("missions/4/abc.pdf"). Las implementaciones deben ser
+// seguras para uso concurrente.
+type BlobStore interface {
+	// Put guarda el contenido leído de r y devuelve los bytes escritos.
+	Put(key string, r io.Reader) (int64, error)
+	// Open abre el blob para lectura; devuelve ErrNotFound si no existe.
+	Open(key string) (io.ReadCloser, error)
+	// Delete elimina el blob; borrar una clave inexistente no es error.
+	Delete(key string) error
+}
@@ -0,0 +1,84 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// Secreto ASCII "12345678901234567890" de los vectores del RFC 6238.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// Los vectores del RFC son de 8 dígitos; con 6 quedan los últimos.
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, c := range cases {
+		got, err := Code(rfcSecret, Step(time.Unix(c.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("Code at %d = %s, want %s", c.unix, got, c.want)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseAndPadding(t *testing.T) {
+	got, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq==", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := Code(rfcSecret, 1)
+	if got != want {
+		t.Errorf("Code = %s, want %s", got, want)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	code := func(s int64) string {
+		c, err := Code(rfcSecret, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	cases := []struct {
+		name     string
+		code     string
+		ok       bool
+		wantStep int64
+	}{
+		{"paso actual", code(step), true, step},
+		{"paso anterior dentro del margen", code(step - 1), true, step - 1},
+		{"paso siguiente dentro del margen", code(step + 1), true, step + 1},
+		{"fuera del margen", code(step - 2), false, 0},
+		{"con espacios", " " + code(step)[:3] + " " + code(step)[3:] + " ", true, step},
+		{"longitud incorrecta", code(step)[:5], false, 0},
+		{"vacío", "", false, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, c.code, now, 1)
+			if ok != c.ok || got != c.wantStep {
+				t.Errorf("Validate = (%d, %v), want (%d, %v)", got, ok, c.wantStep, c.ok)
+			}
+		})
+	}
+	if _, ok := Validate(rfcSecret, code(step-1), now, 0); ok {
+		t.Error("skew 0 accepted the previous step")
+	}
+}